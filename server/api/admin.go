@@ -0,0 +1,211 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/charmbracelet/log"
+)
+
+// CacheFlushHandler drops the simulated cache so the next wave of requests
+// all miss at once and hit the backing dependency (thundering herd).
+// An optional ?step= limits the flush to one dependency.
+func (s *Server) CacheFlushHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID := r.Header.Get("X-Request-ID")
+	step := r.URL.Query().Get("step")
+
+	evicted := s.flushCaches(step)
+	log.Warnf("[%s] Cache flushed (step=%q), %d entries evicted", requestID, step, evicted)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.CacheFlushResponse{Step: step, Evicted: evicted})
+}
+
+// DegradeHandler reports the degradation modes on GET. POST with ?mode=
+// (memory, goroutines or cpu) and ?enabled=true|false toggles one of them.
+func (s *Server) DegradeHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get("X-Request-ID")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		mode := r.URL.Query().Get("mode")
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+			return
+		}
+		if err := s.setDegradeMode(mode, enabled); err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		log.Warnf("[%s] Degradation mode %s set to %v", requestID, mode, enabled)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.degradeStatus())
+}
+
+// DegradeResetHandler frees the memory and goroutines leaked so far
+func (s *Server) DegradeResetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID := r.Header.Get("X-Request-ID")
+	before := s.degradeStatus()
+	s.resetDegradation()
+	log.Warnf("[%s] Degradation reset, freed %d bytes and %d goroutines",
+		requestID, before.LeakedBytes, before.LeakedGoroutines)
+
+	writeJSON(w, http.StatusOK, s.degradeStatus())
+}
+
+// debugVars is the state dump served on the admin listener, in the spirit of
+// expvar's /debug/vars but scoped to one server
+type debugVars struct {
+	Cmdline    []string               `json:"cmdline"`
+	MemStats   runtime.MemStats       `json:"memstats"`
+	Goroutines int                    `json:"goroutines"`
+	Config     config.Config          `json:"config"`
+	Degrade    models.DegradeStatus   `json:"degrade"`
+	Jobs       debugJobs              `json:"jobs"`
+	Caches     map[string]int         `json:"caches"` // step -> live entries
+	Injected   []models.InjectedFault `json:"injected"`
+	Scenario   *models.ScenarioStatus `json:"scenario"`
+}
+
+type debugJobs struct {
+	Queued  int `json:"queued"`
+	Tracked int `json:"tracked"` // Queued, running and retained finished jobs
+}
+
+// DebugVarsHandler dumps the active config, fault schedule, degradation
+// modes, job queue and caches together with the runtime memory stats
+func (s *Server) DebugVarsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := debugVars{
+		Cmdline:    os.Args,
+		Goroutines: runtime.NumGoroutine(),
+		Config:     *s.config(r.Context()),
+		Degrade:    s.degradeStatus(),
+		Caches:     map[string]int{},
+		Injected:   s.injectedFaults(),
+		Scenario:   s.scenarioStatus(),
+	}
+	runtime.ReadMemStats(&vars.MemStats)
+	vars.Config = redacted(vars.Config)
+
+	s.jobsMu.Lock()
+	vars.Jobs = debugJobs{Queued: len(s.jobQueue), Tracked: len(s.jobs)}
+	s.jobsMu.Unlock()
+
+	s.cachesMu.Lock()
+	for step, c := range s.caches {
+		c.mu.Lock()
+		vars.Caches[step] = len(c.entries)
+		c.mu.Unlock()
+	}
+	s.cachesMu.Unlock()
+
+	writeJSON(w, http.StatusOK, vars)
+}
+
+// ConfigHandler returns the active config on GET. PATCH sets the fields of
+// a JSON object such as {"ErrorRate": 0.5, "Throttles": {...}} for every
+// following request, replacing maps and lists as a whole. Requests in
+// flight finish with the config they started with. Fields only read at
+// startup are rejected.
+func (s *Server) ConfigHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get("X-Request-ID")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		// Patches apply one after the other, so none overwrites another
+		s.configMu.Lock()
+		updated, fields, err := s.patchConfig(body)
+		if err == nil {
+			s.cfg.Store(updated)
+		}
+		s.configMu.Unlock()
+		if err != nil {
+			log.Errorf("[%s] Invalid config patch: %v", requestID, err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		log.Warnf("[%s] Config patched: %s", requestID, strings.Join(fields, ", "))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, redacted(*s.cfg.Load()))
+}
+
+// patchConfig returns a copy of the config with the fields of patch set,
+// and their names. Callers must hold configMu.
+func (s *Server) patchConfig(patch []byte) (*config.Config, []string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return nil, nil, models.NewAppError("Invalid request body", http.StatusBadRequest)
+	}
+
+	// A deep copy, so the maps requests in flight read stay untouched
+	updated := &config.Config{}
+	current, _ := json.Marshal(s.cfg.Load())
+	json.Unmarshal(current, updated)
+
+	target := reflect.ValueOf(updated).Elem()
+	var names []string
+	for name := range fields {
+		field := target.FieldByName(name)
+		switch {
+		case !field.IsValid():
+			return nil, nil, models.NewAppError(fmt.Sprintf("Unknown config field %q", name), http.StatusBadRequest)
+		case config.StartupOnly[name]:
+			return nil, nil, models.NewAppError(fmt.Sprintf("%s only applies at startup", name), http.StatusBadRequest)
+		}
+		field.SetZero()
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if err := json.Unmarshal(patch, updated); err != nil {
+		return nil, nil, models.NewAppError("Invalid config: "+err.Error(), http.StatusBadRequest)
+	}
+	if err := updated.Validate(); err != nil {
+		return nil, nil, models.NewAppError(err.Error(), http.StatusBadRequest)
+	}
+	return updated, names, nil
+}
+
+// redacted hides the fault headers secret of cfg
+func redacted(cfg config.Config) config.Config {
+	if cfg.FaultHeadersSecret != "" {
+		cfg.FaultHeadersSecret = "[redacted]"
+	}
+	return cfg
+}
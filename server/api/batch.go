@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/random"
+	"github.com/charmbracelet/log"
+)
+
+// BatchHandler runs the sub-requests of a batch concurrently, at most
+// BATCH_CONCURRENCY (or the request's concurrency) at a time, and reports
+// each one with the status its own endpoint would have returned. The batch
+// only finishes with its slowest sub-request, so its latency grows with the
+// fan-out width even when every dependency keeps the same distribution.
+func (s *Server) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := s.clk.Now()
+	requestID := r.Header.Get("X-Request-ID")
+
+	log.Infof("[%s] Processing POST /api/batch request", requestID)
+
+	var request models.BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		log.Errorf("[%s] Error parsing request body: %v", requestID, err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.validateBatch(r.Context(), request); err != nil {
+		log.Errorf("[%s] Invalid batch request: %v", requestID, err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	concurrency := s.config(r.Context()).BatchConcurrency
+	if request.Concurrency > 0 {
+		concurrency = request.Concurrency
+	}
+	if concurrency <= 0 || concurrency > len(request.Requests) {
+		concurrency = len(request.Requests)
+	}
+
+	response := s.runBatch(r.Context(), requestID, request.Requests, concurrency)
+	response.DurationMs = s.clk.Since(startTime).Milliseconds()
+
+	width := len(request.Requests)
+	s.metrics.BatchFanoutWidth.Observe(float64(width))
+	s.metrics.BatchDuration.WithLabelValues(fanoutLabel(width)).Observe(float64(response.DurationMs))
+
+	log.Infof("[%s] POST /api/batch completed in %v, %d sub-requests %d at a time, %d failed",
+		requestID, s.clk.Since(startTime), width, concurrency, response.Failed)
+
+	status := http.StatusOK
+	switch {
+	case response.Failed == 0:
+	case response.Succeeded > 0:
+		status = http.StatusMultiStatus
+	default:
+		status = http.StatusBadGateway
+	}
+	writeJSON(w, status, response)
+}
+
+// validateBatch rejects empty or oversized batches and unknown operations
+// before anything runs
+func (s *Server) validateBatch(ctx context.Context, request models.BatchRequest) error {
+	maxSize := s.config(ctx).BatchMaxSize
+	if len(request.Requests) == 0 {
+		return models.NewAppError("requests must not be empty", http.StatusBadRequest)
+	}
+	if maxSize > 0 && len(request.Requests) > maxSize {
+		return models.NewAppError(
+			fmt.Sprintf("Too many sub-requests: %d, at most %d allowed", len(request.Requests), maxSize),
+			http.StatusRequestEntityTooLarge)
+	}
+	if request.Concurrency < 0 {
+		return models.NewAppError("concurrency must be a non-negative integer", http.StatusBadRequest)
+	}
+	for i, item := range request.Requests {
+		switch item.Op {
+		case models.BatchUser, models.BatchUsers, models.BatchData:
+		default:
+			return models.NewAppError(
+				fmt.Sprintf("requests[%d]: op must be user, users or data", i), http.StatusBadRequest)
+		}
+	}
+	return nil
+}
+
+// runBatch runs items with at most concurrency of them in flight. Every
+// sub-request gets its own random source, derived in order from the
+// request's, so a seeded batch replays the same way whatever the scheduling.
+func (s *Server) runBatch(ctx context.Context, requestID string, items []models.BatchItem, concurrency int) models.BatchResponse {
+	response := models.BatchResponse{
+		Concurrency: concurrency,
+		Results:     make([]models.BatchResult, len(items)),
+	}
+
+	rng := s.randFor(ctx)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		itemCtx := random.WithRand(ctx, random.New(rng.Int63()))
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			response.Results[i] = s.runBatchItem(itemCtx, fmt.Sprintf("%s/%d", requestID, i), i, item)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range response.Results {
+		if result.Error == "" {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+		response.SlowestMs = max(response.SlowestMs, result.DurationMs)
+	}
+	return response
+}
+
+// runBatchItem runs one sub-request through the pipeline of its endpoint
+func (s *Server) runBatchItem(ctx context.Context, requestID string, index int, item models.BatchItem) models.BatchResult {
+	startTime := s.clk.Now()
+	result := models.BatchResult{Index: index, Op: item.Op, Status: http.StatusOK}
+
+	var body interface{}
+	var err error
+	switch item.Op {
+	case models.BatchUser:
+		path := "/api/users/" + strconv.Itoa(item.ID)
+		if item.ID <= 0 {
+			err = models.NewAppError("Invalid user id", http.StatusBadRequest)
+			break
+		}
+		body, err = s.getUser(ctx, requestID, item.ID, path)
+	case models.BatchUsers, models.BatchData:
+		path := "/api/" + item.Op
+		params := url.Values{}
+		for name, value := range item.Params {
+			params.Set(name, value)
+		}
+		query, qerr := s.listQuery(ctx, params)
+		if qerr != nil {
+			err = qerr
+			break
+		}
+		cacheKey := path + "?" + params.Encode()
+		if item.Op == models.BatchUsers {
+			body, err = s.listUsers(ctx, requestID, query, path, cacheKey)
+		} else {
+			body, err = s.listData(ctx, requestID, query, path, cacheKey)
+		}
+	}
+
+	duration := s.clk.Since(startTime)
+	s.metrics.BatchItemDuration.WithLabelValues(item.Op).Observe(float64(duration.Milliseconds()))
+	result.DurationMs = duration.Milliseconds()
+	if err != nil {
+		result.Status = errorStatus(err)
+		result.Error = err.Error()
+		return result
+	}
+	result.Body = body
+	return result
+}
+
+// fanoutLabel buckets a fan-out width for the batch_duration_ms label
+func fanoutLabel(width int) string {
+	switch {
+	case width <= 1:
+		return "1"
+	case width <= 5:
+		return "2-5"
+	case width <= 10:
+		return "6-10"
+	case width <= 50:
+		return "11-50"
+	default:
+		return "51+"
+	}
+}
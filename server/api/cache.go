@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// simCache is a simulated cache that sits in front of one dependency.
+// Entries only track their expiry; whether a lookup hits is decided by the
+// configured hit ratio, scaled down while the cache is still warming up.
+type simCache struct {
+	srv       *Server
+	mu        sync.Mutex
+	step      string
+	entries   map[string]time.Time
+	warmStart time.Time
+}
+
+func (s *Server) newSimCache(step string) *simCache {
+	return &simCache{
+		srv:       s,
+		step:      step,
+		entries:   map[string]time.Time{},
+		warmStart: s.clk.Now(),
+	}
+}
+
+// cacheFor returns the cache fronting a dependency, creating it on first use
+func (s *Server) cacheFor(step string) *simCache {
+	s.cachesMu.Lock()
+	defer s.cachesMu.Unlock()
+
+	c, ok := s.caches[step]
+	if !ok {
+		c = s.newSimCache(step)
+		s.caches[step] = c
+	}
+	return c
+}
+
+// warmFactor ramps linearly from 0 to 1 over the configured warm-up period
+func (c *simCache) warmFactor(now time.Time) float64 {
+	if c.srv.cfg.Load().CacheWarmup <= 0 {
+		return 1
+	}
+	elapsed := now.Sub(c.warmStart)
+	warmup := time.Duration(c.srv.cfg.Load().CacheWarmup) * time.Millisecond
+	if elapsed >= warmup {
+		return 1
+	}
+	return float64(elapsed) / float64(warmup)
+}
+
+// lookup reports whether key is served from the cache
+func (c *simCache) lookup(ctx context.Context, key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.srv.clk.Now()
+	expiry, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	if now.After(expiry) {
+		delete(c.entries, key)
+		c.srv.metrics.CacheEvictions.WithLabelValues(c.step).Inc()
+		return false
+	}
+	return c.srv.randFloat64(ctx) < c.srv.config(ctx).CacheHitRatio*c.warmFactor(now)
+}
+
+// store (re)populates key after a miss has been served by the dependency
+func (c *simCache) store(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = c.srv.clk.Now().Add(time.Duration(c.srv.cfg.Load().CacheTTL) * time.Millisecond)
+}
+
+// flush drops every entry and restarts the warm-up curve
+func (c *simCache) flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	evicted := len(c.entries)
+	c.entries = map[string]time.Time{}
+	c.warmStart = c.srv.clk.Now()
+	c.srv.metrics.CacheEvictions.WithLabelValues(c.step).Add(float64(evicted))
+	return evicted
+}
+
+// invalidate drops every entry under prefix, e.g. after the backing record
+// was written. List pages are keyed by path and query, so a prefix is needed
+// to catch all of them.
+func (c *simCache) invalidate(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+			c.srv.metrics.CacheEvictions.WithLabelValues(c.step).Inc()
+		}
+	}
+}
+
+// invalidateCache drops the entries under prefix from the cache of step if that step is cached
+func (s *Server) invalidateCache(step, prefix string) {
+	if !s.cfg.Load().CachesStep(step) {
+		return
+	}
+	s.cacheFor(step).invalidate(prefix)
+}
+
+// flushCaches flushes the cache of one dependency, or all of them if step is empty
+func (s *Server) flushCaches(step string) int {
+	s.cachesMu.Lock()
+	targets := make([]*simCache, 0, len(s.caches))
+	for name, c := range s.caches {
+		if step == "" || name == step {
+			targets = append(targets, c)
+		}
+	}
+	s.cachesMu.Unlock()
+
+	evicted := 0
+	for _, c := range targets {
+		evicted += c.flush()
+	}
+	return evicted
+}
+
+// cachedStep runs a simulated dependency call behind the cache when the
+// cache is enabled for that step. Hits skip the dependency entirely, misses
+// pay the lookup cost and then the full dependency delay.
+func (s *Server) cachedStep(ctx context.Context, step, key string, call func(context.Context) (bool, error)) (bool, error) {
+	if !s.config(ctx).CachesStep(step) {
+		return call(ctx)
+	}
+
+	c := s.cacheFor(step)
+	if c.lookup(ctx, key) {
+		s.simulateDelay(ctx, "cache", s.config(ctx).CacheHitDelay/2, s.config(ctx).CacheHitDelay)
+		s.metrics.CacheHits.WithLabelValues(step).Inc()
+		return true, nil
+	}
+
+	s.simulateDelay(ctx, "cache", s.config(ctx).CacheMissDelay/2, s.config(ctx).CacheMissDelay)
+	s.metrics.CacheMisses.WithLabelValues(step).Inc()
+
+	inflight := s.metrics.CacheInflightMisses.WithLabelValues(step)
+	inflight.Inc()
+	defer inflight.Dec()
+
+	ok, err := call(ctx)
+	if err == nil {
+		c.store(key)
+	}
+	return ok, err
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/models"
+)
+
+// Degradation modes that can be toggled at runtime
+const (
+	modeMemory     = "memory"
+	modeGoroutines = "goroutines"
+	modeCPU        = "cpu"
+)
+
+// degrader really consumes memory, goroutines and CPU on every request while
+// a mode is on, so that runtime metrics and pprof show the same picture as a
+// genuine leak or saturation would
+type degrader struct {
+	mu          sync.Mutex
+	modes       map[string]bool
+	leaked      [][]byte
+	leakedBytes int
+	parked      int
+	release     chan struct{} // Closed on reset to let the parked goroutines exit
+	burning     int
+}
+
+func newDegrader(cfg *config.Config) *degrader {
+	return &degrader{
+		modes: map[string]bool{
+			modeMemory:     cfg.DegradeMemory,
+			modeGoroutines: cfg.DegradeGoroutines,
+			modeCPU:        cfg.DegradeCPU,
+		},
+		release: make(chan struct{}),
+	}
+}
+
+// degraded applies the enabled degradation modes before every call of next
+func (s *Server) degraded(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.deg.mu.Lock()
+		memory, goroutines, cpu := s.deg.modes[modeMemory], s.deg.modes[modeGoroutines], s.deg.modes[modeCPU]
+		s.deg.mu.Unlock()
+
+		cfg := s.config(r.Context())
+		if memory {
+			s.leakMemory(cfg)
+		}
+		if goroutines {
+			s.leakGoroutines(cfg)
+		}
+		if cpu {
+			s.burnCPU(cfg)
+		}
+		next(w, r)
+	}
+}
+
+// leakMemory retains LeakBytes more, up to LeakMaxBytes
+func (s *Server) leakMemory(cfg *config.Config) {
+	d := s.deg
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := cfg.LeakBytes
+	if cfg.LeakMaxBytes > 0 && d.leakedBytes+n > cfg.LeakMaxBytes {
+		n = cfg.LeakMaxBytes - d.leakedBytes
+	}
+	if n <= 0 {
+		return
+	}
+
+	buf := make([]byte, n)
+	for i := 0; i < len(buf); i += 4096 { // Touch every page so it is really resident
+		buf[i] = 1
+	}
+	d.leaked = append(d.leaked, buf)
+	d.leakedBytes += n
+	s.metrics.LeakedBytes.Set(float64(d.leakedBytes))
+}
+
+// leakGoroutines parks LeakGoroutines more goroutines, up to LeakMaxGoroutines
+func (s *Server) leakGoroutines(cfg *config.Config) {
+	d := s.deg
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := cfg.LeakGoroutines
+	if cfg.LeakMaxGoroutines > 0 && d.parked+n > cfg.LeakMaxGoroutines {
+		n = cfg.LeakMaxGoroutines - d.parked
+	}
+	for i := 0; i < n; i++ {
+		go func(release <-chan struct{}) {
+			<-release
+		}(d.release)
+	}
+	if n > 0 {
+		d.parked += n
+		s.metrics.LeakedGoroutines.Set(float64(d.parked))
+	}
+}
+
+// burnCPU spins for CPUBurn ms unless CPUBurnMaxConcurrent requests already are
+func (s *Server) burnCPU(cfg *config.Config) {
+	d := s.deg
+	d.mu.Lock()
+	if cfg.CPUBurnMaxConcurrent > 0 && d.burning >= cfg.CPUBurnMaxConcurrent {
+		d.mu.Unlock()
+		return
+	}
+	d.burning++
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		d.burning--
+		d.mu.Unlock()
+	}()
+
+	// Real time on purpose: unlike the simulated delays the work is real
+	burn := time.Duration(cfg.CPUBurn) * time.Millisecond
+	deadline := time.Now().Add(burn)
+	x := uint64(1)
+	for time.Now().Before(deadline) {
+		for i := 0; i < 1000; i++ {
+			x = x*6364136223846793005 + 1442695040888963407
+		}
+	}
+	runtime.KeepAlive(x)
+	s.metrics.CPUBurnMs.Add(float64(burn.Milliseconds()))
+}
+
+// setDegradeMode turns one mode on or off
+func (s *Server) setDegradeMode(mode string, enabled bool) error {
+	d := s.deg
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.modes[mode]; !ok {
+		return models.NewAppError(fmt.Sprintf("Unknown degradation mode %q", mode), http.StatusBadRequest)
+	}
+	d.modes[mode] = enabled
+	return nil
+}
+
+// resetDegradation frees the leaked memory and goroutines. Modes stay as they are.
+func (s *Server) resetDegradation() {
+	d := s.deg
+	d.mu.Lock()
+	d.leaked = nil
+	d.leakedBytes = 0
+	close(d.release)
+	d.release = make(chan struct{})
+	d.parked = 0
+	d.mu.Unlock()
+
+	s.metrics.LeakedBytes.Set(0)
+	s.metrics.LeakedGoroutines.Set(0)
+
+	// Hand the memory back to the OS right away so RSS drops as well as the heap
+	debug.FreeOSMemory()
+}
+
+func (s *Server) degradeStatus() models.DegradeStatus {
+	d := s.deg
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return models.DegradeStatus{
+		Memory:           d.modes[modeMemory],
+		Goroutines:       d.modes[modeGoroutines],
+		CPU:              d.modes[modeCPU],
+		LeakedBytes:      d.leakedBytes,
+		LeakedGoroutines: d.parked,
+		BurningRequests:  d.burning,
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/trace"
+	"github.com/charmbracelet/log"
+)
+
+// callDownstream makes the GET to target that stands in for step, carrying
+// on the request's trace context and request ID. A failure downstream fails
+// the step: 429 and 503 stay 503 so that callers can tell overload from
+// breakage, a 504 or a call outlasting DOWNSTREAM_TIMEOUT becomes 504, and
+// any other error status, a refused connection or a broken body becomes 502.
+func (s *Server) callDownstream(ctx context.Context, step, target string) error {
+	tc, ok := trace.FromContext(ctx)
+	if !ok {
+		tc = trace.FromHeaders("", "")
+	}
+	requestID := tc.RequestID
+
+	callCtx, cancel := context.WithTimeout(ctx, time.Duration(s.config(ctx).DownstreamTimeout)*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(callCtx, http.MethodGet, target, nil)
+	if err != nil {
+		log.Errorf("[%s] Invalid %s downstream URL %s: %v", requestID, step, target, err)
+		return models.NewAppError("Invalid "+step+" downstream", http.StatusBadGateway)
+	}
+	req.Header.Set(trace.Header, tc.Child().Traceparent())
+	if requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+
+	startTime := s.clk.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	s.metrics.DownstreamDuration.WithLabelValues(step).Observe(float64(s.clk.Since(startTime).Milliseconds()))
+
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	s.metrics.DownstreamCalls.WithLabelValues(step, strconv.Itoa(status)).Inc()
+
+	switch {
+	case ctx.Err() != nil:
+		return contextError(ctx.Err())
+	case callCtx.Err() != nil:
+		log.Warnf("[%s] %s downstream %s timed out", requestID, step, target)
+		return models.NewAppError(step+" downstream timed out", http.StatusGatewayTimeout)
+	case err != nil:
+		log.Warnf("[%s] %s downstream %s failed: %v", requestID, step, target, err)
+		return models.NewAppError(step+" downstream failed", http.StatusBadGateway)
+	}
+
+	if status >= 400 {
+		log.Warnf("[%s] %s downstream %s returned %d", requestID, step, target, status)
+	}
+	switch {
+	case status < 400:
+		return nil
+	case status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable:
+		return models.NewAppError(step+" downstream unavailable", http.StatusServiceUnavailable)
+	case status == http.StatusGatewayTimeout:
+		return models.NewAppError(step+" downstream timed out", http.StatusGatewayTimeout)
+	default:
+		return models.NewAppError(step+" downstream returned "+strconv.Itoa(status), http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/Unic-X/slow-server/faults"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/trace"
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+)
+
+// faultID is what a client may name a fault. slowctl sends the same ID to
+// every target, so that it addresses the same fault on every replica.
+var faultID = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// FaultsHandler lists the active one-off faults on GET. POST injects one
+// from a models.FaultRequest, under the body's "id" or a random one; an id
+// already in use is refused with 409. DELETE removes the one with ?id=,
+// 404 if there is none, or all of them.
+func (s *Server) FaultsHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get("X-Request-ID")
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.injectedFaults())
+	case http.MethodPost:
+		var request models.InjectedFault
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			log.Errorf("[%s] Error parsing request body: %v", requestID, err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if request.ID == "" {
+			request.ID = uuid.NewString()[:8]
+		}
+		if !faultID.MatchString(request.ID) {
+			http.Error(w, "id must be up to 64 letters, digits, '.', '_' or '-'", http.StatusBadRequest)
+			return
+		}
+		if err := validateFault(request.FaultRequest); err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		fault, err := s.injectFault(request.ID, request.FaultRequest)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		log.Warnf("[%s] Injected %s fault %s into %s at %.0f%% until %s",
+			requestID, fault.Kind, fault.ID, fault.Step, fault.Rate*100, fault.Until.Format(time.RFC3339))
+		writeJSON(w, http.StatusCreated, fault)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		removed := s.removeFaults(id)
+		if id != "" && removed == 0 {
+			http.Error(w, "No one-off fault with id "+id, http.StatusNotFound)
+			return
+		}
+		log.Warnf("[%s] Removed %d injected faults", requestID, removed)
+		writeJSON(w, http.StatusOK, s.injectedFaults())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func validateFault(request models.FaultRequest) error {
+	switch {
+	case request.Kind != models.FaultFail && request.Kind != models.FaultDelay:
+		return models.NewAppError("kind must be fail or delay", http.StatusBadRequest)
+	case request.Kind == models.FaultFail && !faults.CanFail(request.Step):
+		return models.NewAppError(fmt.Sprintf("Unknown step %q to fail", request.Step), http.StatusBadRequest)
+	case request.Kind == models.FaultDelay && !faults.CanDelay(request.Step):
+		return models.NewAppError(fmt.Sprintf("Unknown step %q to delay", request.Step), http.StatusBadRequest)
+	case request.Rate <= 0 || request.Rate > 1:
+		return models.NewAppError("rate must be above 0 and at most 1", http.StatusBadRequest)
+	case request.DurationMs <= 0:
+		return models.NewAppError("duration_ms must be positive", http.StatusBadRequest)
+	case request.Status != 0 && (request.Status < 400 || request.Status > 599):
+		return models.NewAppError("status must be between 400 and 599", http.StatusBadRequest)
+	case request.Kind == models.FaultDelay && request.DelayMs <= 0:
+		return models.NewAppError("delay_ms must be positive", http.StatusBadRequest)
+	}
+	return nil
+}
+
+func (s *Server) injectFault(id string, request models.FaultRequest) (models.InjectedFault, error) {
+	now := s.clk.Now()
+
+	s.faultsMu.Lock()
+	defer s.faultsMu.Unlock()
+	for _, fault := range s.injected {
+		if fault.ID == id && now.Before(fault.Until) {
+			return models.InjectedFault{}, models.NewAppError("A one-off fault with id "+id+" is already active", http.StatusConflict)
+		}
+	}
+
+	fault := models.InjectedFault{
+		ID:           id,
+		FaultRequest: request,
+		Until:        now.Add(time.Duration(request.DurationMs) * time.Millisecond),
+	}
+	s.injected = append(s.injected, fault)
+	return fault, nil
+}
+
+// removeFaults drops the fault with id, or every fault without one, and
+// returns how many went
+func (s *Server) removeFaults(id string) int {
+	s.faultsMu.Lock()
+	defer s.faultsMu.Unlock()
+
+	kept := s.injected[:0]
+	for _, fault := range s.injected {
+		if id != "" && fault.ID != id {
+			kept = append(kept, fault)
+		}
+	}
+	removed := len(s.injected) - len(kept)
+	s.injected = kept
+	return removed
+}
+
+// injectedFaults returns the faults that have not expired yet, dropping
+// the others
+func (s *Server) injectedFaults() []models.InjectedFault {
+	now := s.clk.Now()
+
+	s.faultsMu.Lock()
+	defer s.faultsMu.Unlock()
+	kept := s.injected[:0]
+	for _, fault := range s.injected {
+		if now.Before(fault.Until) {
+			kept = append(kept, fault)
+		}
+	}
+	s.injected = kept
+	return append([]models.InjectedFault{}, kept...)
+}
+
+// activeFault returns the most recently injected fault of kind on step,
+// one-off faults before those of the running scenario's phase
+func (s *Server) activeFault(kind, step string) (models.InjectedFault, bool) {
+	var active []models.InjectedFault
+	if scenario := s.scenarioStatus(); scenario != nil {
+		active = scenario.Faults
+	}
+	active = append(active, s.injectedFaults()...)
+	for i := len(active) - 1; i >= 0; i-- {
+		if active[i].Kind == kind && active[i].Step == step {
+			return active[i], true
+		}
+	}
+	return models.InjectedFault{}, false
+}
+
+// injectedFailure rolls an injected failure of step; status is the code
+// to fail with, or 0 for the step's usual one
+func (s *Server) injectedFailure(ctx context.Context, step string) (failed bool, status int) {
+	fault, ok := s.activeFault(models.FaultFail, step)
+	if !ok || s.randFloat64(ctx) >= fault.Rate {
+		return false, 0
+	}
+	tc, _ := trace.FromContext(ctx)
+	log.Warnf("[%s] Failing %s step per injected fault %s", tc.RequestID, step, fault.ID)
+	return true, fault.Status
+}
+
+// injectedDelay rolls an injected delay of step
+func (s *Server) injectedDelay(ctx context.Context, step string) time.Duration {
+	fault, ok := s.activeFault(models.FaultDelay, step)
+	if !ok || s.randFloat64(ctx) >= fault.Rate {
+		return 0
+	}
+	return time.Duration(fault.DelayMs) * time.Millisecond
+}
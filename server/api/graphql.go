@@ -0,0 +1,281 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/store"
+	"github.com/charmbracelet/log"
+	"github.com/graphql-go/graphql"
+)
+
+// GraphQL resolver modes
+const (
+	graphQLNPlusOne   = "n+1"
+	graphQLDataloader = "dataloader"
+)
+
+// graphQLRequest is a GraphQL query sent as a JSON body or in the query string
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// GraphQLHandler serves a GraphQL API over the users and data items. Every
+// field with a resolver costs its configured latency and can fail on its
+// own, in which case the response carries the rest of the data plus an
+// errors entry for that field. DataItem.owner is resolved per item in "n+1"
+// mode and batched per query level in "dataloader" mode; ?mode= overrides
+// GRAPHQL_MODE for one request.
+func (s *Server) GraphQLHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get("X-Request-ID")
+
+	var req graphQLRequest
+	switch r.Method {
+	case http.MethodGet:
+		params := r.URL.Query()
+		req.Query = params.Get("query")
+		req.OperationName = params.Get("operationName")
+		if v := params.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				http.Error(w, "Invalid variables", http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Errorf("[%s] Error parsing request body: %v", requestID, err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mode := s.config(r.Context()).GraphQLMode
+	if m := r.URL.Query().Get("mode"); m != "" {
+		if m != graphQLNPlusOne && m != graphQLDataloader {
+			http.Error(w, "mode must be n+1 or dataloader", http.StatusBadRequest)
+			return
+		}
+		mode = m
+	}
+
+	ctx := r.Context()
+	if mode == graphQLDataloader {
+		ctx = context.WithValue(ctx, ownerLoaderKey{}, newOwnerLoader(s, ctx))
+	}
+
+	startTime := s.clk.Now()
+	result := graphql.Do(graphql.Params{
+		Schema:         s.graphQLSchema(),
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		Context:        ctx,
+	})
+	log.Infof("[%s] GraphQL query resolved in %s mode in %v with %d errors",
+		requestID, mode, s.clk.Since(startTime), len(result.Errors))
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// graphQLSchema builds the schema on first use. Its resolvers close over s.
+func (s *Server) graphQLSchema() graphql.Schema {
+	s.schemaOnce.Do(func() {
+		schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: s.graphQLQueryType()})
+		if err != nil {
+			panic("invalid GraphQL schema: " + err.Error())
+		}
+		s.schema = schema
+	})
+	return s.schema
+}
+
+func (s *Server) graphQLQueryType() *graphql.Object {
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"email": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	dataItemType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "DataItem",
+		Fields: graphql.Fields{
+			"id":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"value": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"owner": &graphql.Field{
+				Type:    userType,
+				Resolve: s.resolveOwner,
+			},
+		},
+	})
+
+	pageArgs := graphql.FieldConfigArgument{
+		"limit":  &graphql.ArgumentConfig{Type: graphql.Int},
+		"offset": &graphql.ArgumentConfig{Type: graphql.Int},
+	}
+
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"users": &graphql.Field{
+				Type: graphql.NewList(graphql.NewNonNull(userType)),
+				Args: pageArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := s.simulateResolver(p.Context, "Query.users"); err != nil {
+						return nil, err
+					}
+					page, err := s.db.QueryUsers(s.graphQLPage(p))
+					return page.Items, err
+				},
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := s.simulateResolver(p.Context, "Query.user"); err != nil {
+						return nil, err
+					}
+					return s.lookupUser(p.Args["id"].(int)), nil
+				},
+			},
+			"data": &graphql.Field{
+				Type: graphql.NewList(graphql.NewNonNull(dataItemType)),
+				Args: pageArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := s.simulateResolver(p.Context, "Query.data"); err != nil {
+						return nil, err
+					}
+					page, err := s.db.QueryData(s.graphQLPage(p))
+					return page.Items, err
+				},
+			},
+		},
+	})
+}
+
+// graphQLPage reads the limit and offset arguments of a list field
+func (s *Server) graphQLPage(p graphql.ResolveParams) store.Query {
+	cfg := s.config(p.Context)
+	query := store.Query{Limit: cfg.DefaultPageSize}
+	if limit, ok := p.Args["limit"].(int); ok && limit >= 0 {
+		query.Limit = limit
+	}
+	if cfg.MaxPageSize > 0 && query.Limit > cfg.MaxPageSize {
+		query.Limit = cfg.MaxPageSize
+	}
+	if offset, ok := p.Args["offset"].(int); ok && offset >= 0 {
+		query.Offset = offset
+	}
+	return query
+}
+
+// resolveOwner resolves DataItem.owner: the user at the item's position in
+// the initial data set, or null once that user is deleted
+func (s *Server) resolveOwner(p graphql.ResolveParams) (interface{}, error) {
+	item := p.Source.(models.DataItem)
+	id := (item.ID-1)%s.config(p.Context).StoreSize + 1
+
+	if loader, ok := p.Context.Value(ownerLoaderKey{}).(*ownerLoader); ok {
+		return loader.load(id), nil
+	}
+	if err := s.simulateResolver(p.Context, "DataItem.owner"); err != nil {
+		return nil, err
+	}
+	return s.lookupUser(id), nil
+}
+
+// lookupUser returns the user with id, or an untyped nil for GraphQL's null
+func (s *Server) lookupUser(id int) interface{} {
+	user, err := s.db.GetUser(id)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// simulateResolver runs one call of the resolver of field with its
+// configured latency and failure rate
+func (s *Server) simulateResolver(ctx context.Context, field string) error {
+	sim := s.config(ctx).ResolverFor(field)
+	startTime := s.clk.Now()
+	delay := sim.Delay / 2
+	if sim.Delay > delay {
+		delay += s.randIntn(ctx, sim.Delay-delay)
+	}
+	s.sleep(ctx, time.Duration(delay)*time.Millisecond)
+	duration := s.clk.Since(startTime)
+	s.metrics.GraphQLResolverDuration.WithLabelValues(field).Observe(float64(duration.Milliseconds()))
+
+	if err := ctx.Err(); err != nil {
+		s.live.RecordDependency("graphql "+field, true, duration)
+		return contextError(err)
+	}
+	failed := sim.ErrorRate > 0 && s.randFloat64(ctx) < sim.ErrorRate
+	s.live.RecordDependency("graphql "+field, failed, duration)
+	if failed {
+		s.metrics.GraphQLResolverErrors.WithLabelValues(field).Inc()
+		return models.NewAppError(field+" resolver failed", http.StatusInternalServerError)
+	}
+	return nil
+}
+
+type ownerLoaderKey struct{}
+
+// ownerLoader batches the DataItem.owner lookups of one query level into a
+// single resolver call, like a dataloader. graphql-go resolves the thunks
+// returned by load only after every field of a level has been visited.
+type ownerLoader struct {
+	s       *Server
+	ctx     context.Context
+	pending []int
+	users   map[int]interface{}
+	errs    map[int]error
+}
+
+func newOwnerLoader(s *Server, ctx context.Context) *ownerLoader {
+	return &ownerLoader{s: s, ctx: ctx, users: map[int]interface{}{}, errs: map[int]error{}}
+}
+
+func (l *ownerLoader) load(id int) func() (interface{}, error) {
+	if _, done := l.users[id]; !done {
+		l.pending = append(l.pending, id)
+	}
+	return func() (interface{}, error) {
+		if _, done := l.users[id]; !done {
+			l.flush()
+		}
+		return l.users[id], l.errs[id]
+	}
+}
+
+// flush loads every pending key with one resolver call
+func (l *ownerLoader) flush() {
+	ids := map[int]bool{}
+	for _, id := range l.pending {
+		ids[id] = true
+	}
+	l.pending = nil
+	l.s.metrics.GraphQLBatchSize.WithLabelValues("DataItem.owner").Observe(float64(len(ids)))
+
+	err := l.s.simulateResolver(l.ctx, "DataItem.owner")
+	for id := range ids {
+		if err != nil {
+			l.users[id], l.errs[id] = nil, err
+			continue
+		}
+		l.users[id] = l.s.lookupUser(id)
+	}
+}
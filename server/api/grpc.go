@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/slowpb"
+	"github.com/Unic-X/slow-server/store"
+	"github.com/charmbracelet/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcService exposes the REST handlers' pipelines over gRPC
+type grpcService struct {
+	slowpb.UnimplementedSlowServerServer
+	s *Server
+}
+
+// RegisterGRPC serves the slowpb.SlowServer API of s on gs
+func (s *Server) RegisterGRPC(gs grpc.ServiceRegistrar) {
+	slowpb.RegisterSlowServerServer(gs, &grpcService{s: s})
+}
+
+func (g *grpcService) GetData(ctx context.Context, req *slowpb.ListRequest) (*slowpb.DataResponse, error) {
+	method, _ := grpc.Method(ctx)
+	query, err := g.s.grpcListQuery(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	response, err := g.s.listData(ctx, grpcRequestID(ctx), query, method, listCacheKey(method, req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	out := &slowpb.DataResponse{
+		Count:      int32(response.Count),
+		Total:      int32(response.Total),
+		NextCursor: response.NextCursor,
+	}
+	for _, item := range response.Data {
+		out.Data = append(out.Data, &slowpb.DataItem{Id: int64(item.ID), Name: item.Name, Value: item.Value})
+	}
+	return out, nil
+}
+
+func (g *grpcService) GetUsers(ctx context.Context, req *slowpb.ListRequest) (*slowpb.UsersResponse, error) {
+	method, _ := grpc.Method(ctx)
+	query, err := g.s.grpcListQuery(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	response, err := g.s.listUsers(ctx, grpcRequestID(ctx), query, method, listCacheKey(method, req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	out := &slowpb.UsersResponse{
+		Count:      int32(response.Count),
+		Total:      int32(response.Total),
+		NextCursor: response.NextCursor,
+	}
+	for _, user := range response.Users {
+		out.Users = append(out.Users, &slowpb.User{Id: int64(user.ID), Name: user.Name, Email: user.Email})
+	}
+	return out, nil
+}
+
+func (g *grpcService) ProcessData(ctx context.Context, req *slowpb.ProcessRequest) (*slowpb.ProcessResponse, error) {
+	requestID := grpcRequestID(ctx)
+	request := models.ProcessRequest{Items: req.Items, Args: req.Args}
+	args, err := g.s.parseProcessArgs(ctx, request)
+	if err != nil {
+		log.Errorf("[%s] Invalid process request: %v", requestID, err)
+		return nil, grpcError(err)
+	}
+
+	response, err := g.s.runProcess(ctx, requestID, request, args, nil, nil)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	out := &slowpb.ProcessResponse{
+		Success:   response.Success,
+		Message:   response.Message,
+		Processed: int32(response.Processed),
+		Failed:    int32(response.Failed),
+	}
+	for _, result := range response.Results {
+		out.Results = append(out.Results, itemResultProto(result))
+	}
+	return out, nil
+}
+
+func (g *grpcService) StreamProcessData(req *slowpb.ProcessRequest, stream grpc.ServerStreamingServer[slowpb.ItemResult]) error {
+	ctx := stream.Context()
+	requestID := grpcRequestID(ctx)
+	request := models.ProcessRequest{Items: req.Items, Args: req.Args}
+	args, err := g.s.parseProcessArgs(ctx, request)
+	if err != nil {
+		log.Errorf("[%s] Invalid process request: %v", requestID, err)
+		return grpcError(err)
+	}
+
+	var sendErr error
+	_, err = g.s.runProcess(ctx, requestID, request, args, nil, func(result models.ItemResult) {
+		if sendErr == nil {
+			sendErr = stream.Send(itemResultProto(result))
+		}
+	})
+	if err != nil {
+		return grpcError(err)
+	}
+	return sendErr
+}
+
+// grpcListQuery is parseListQuery for a gRPC ListRequest
+func (s *Server) grpcListQuery(ctx context.Context, req *slowpb.ListRequest) (store.Query, error) {
+	cfg := s.config(ctx)
+	query := store.Query{
+		Filters: map[string]string{},
+		Sort:    req.Sort,
+		Cursor:  req.Cursor,
+		Limit:   cfg.DefaultPageSize,
+		Offset:  int(req.Offset),
+	}
+
+	if req.Limit < 0 {
+		return query, models.NewAppError("limit must be a non-negative integer", http.StatusBadRequest)
+	}
+	if req.Limit > 0 {
+		query.Limit = int(req.Limit)
+	}
+	if cfg.MaxPageSize > 0 && query.Limit > cfg.MaxPageSize {
+		query.Limit = cfg.MaxPageSize
+	}
+	if req.Offset < 0 {
+		return query, models.NewAppError("offset must be a non-negative integer", http.StatusBadRequest)
+	}
+
+	for name, value := range req.Filters {
+		if !store.IsFilter(name) {
+			return query, models.NewAppError("Unknown filter "+name, http.StatusBadRequest)
+		}
+		query.Filters[name] = value
+	}
+
+	return query, nil
+}
+
+// listCacheKey identifies a list request in the caches, like the request
+// URI does for REST
+func listCacheKey(method string, req *slowpb.ListRequest) string {
+	params := url.Values{}
+	for name, value := range req.Filters {
+		params.Set(name, value)
+	}
+	params.Set("limit", strconv.Itoa(int(req.Limit)))
+	params.Set("offset", strconv.Itoa(int(req.Offset)))
+	params.Set("cursor", req.Cursor)
+	params.Set("sort", req.Sort)
+	return method + "?" + params.Encode()
+}
+
+func itemResultProto(result models.ItemResult) *slowpb.ItemResult {
+	return &slowpb.ItemResult{
+		Index:      int32(result.Index),
+		Item:       result.Item,
+		Status:     result.Status,
+		Error:      result.Error,
+		DurationMs: result.DurationMs,
+	}
+}
+
+// grpcRequestID returns the x-request-id metadata of the call
+func grpcRequestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get("x-request-id"); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
+
+// grpcError maps the HTTP status of an AppError onto the matching gRPC code
+func grpcError(err error) error {
+	code := codes.Internal
+	var appErr *models.AppError
+	if errors.As(err, &appErr) {
+		switch appErr.StatusCode {
+		case http.StatusBadRequest, http.StatusUnprocessableEntity:
+			code = codes.InvalidArgument
+		case http.StatusNotFound:
+			code = codes.NotFound
+		case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+			code = codes.ResourceExhausted
+		case 499:
+			code = codes.Canceled
+		case http.StatusBadGateway, http.StatusServiceUnavailable:
+			code = codes.Unavailable
+		case http.StatusGatewayTimeout:
+			code = codes.DeadlineExceeded
+		}
+	}
+	return status.Error(code, err.Error())
+}
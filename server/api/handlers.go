@@ -1,220 +1,400 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/charmbracelet/log"
-	"math/rand"
 	"net/http"
-	"github.com/Unic-X/slow-server/config"
-	"github.com/Unic-X/slow-server/metrics"
+	"github.com/Unic-X/slow-server/faults"
 	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/random"
+	"github.com/Unic-X/slow-server/store"
 	"time"
 )
 
-var cfg *config.Config
+// simulateDelay sleeps for a random time between min and max ms, unless
+// the request forces an exact delay for step
+func (s *Server) simulateDelay(ctx context.Context, step string, min, max int) {
+	if forced, ok := faults.FromContext(ctx).Delay(step); ok {
+		s.recordOverride(ctx, "delay", step)
+		s.sleep(ctx, forced)
+		return
+	}
+
+	delay := min
+	if max > min {
+		delay = min + s.randIntn(ctx, max-min)
+	}
+	s.sleep(ctx, time.Duration(delay)*time.Millisecond+s.injectedDelay(ctx, step))
+}
 
-func init() {
-	cfg = config.LoadConfig()
-	rand.NewSource(time.Now().UnixNano())
+// simulateDependency takes the time of step: a real call to its downstream
+// instance if it has one, after any injected delay, otherwise a simulated
+// delay. A delay or failure forced by the request's fault headers or body
+// skips the downstream, so it applies the same with or without one.
+func (s *Server) simulateDependency(ctx context.Context, step string, min, max int) (served bool, err error) {
+	overrides := faults.FromContext(ctx)
+	_, delayed := overrides.Delay(step)
+	_, failing := overrides.Failure(step)
+	_, asked := faults.ArgFailure(ctx, step)
+	if target, ok := s.config(ctx).Downstreams[step]; ok && !delayed && !failing && !asked {
+		if injected := s.injectedDelay(ctx, step); injected > 0 {
+			s.sleep(ctx, injected)
+		}
+		return true, s.callDownstream(ctx, step, target)
+	}
+	s.simulateDelay(ctx, step, min, max)
+	return false, nil
 }
 
-func SetConfig(c *config.Config) {
-	cfg = c
+// sleep waits d on the clock, or until ctx is done
+func (s *Server) sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-s.clk.After(d):
+	}
 }
 
-func simulateDelay(min, max int) {
-	delay := min
-	if max > min {
-		delay = min + rand.Intn(max-min)
+// contextError turns the end of a request's context into an AppError: 504
+// once its deadline, e.g. a gRPC timeout, has passed and 499 once the client
+// has gone away
+func contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return models.NewAppError("Deadline exceeded", http.StatusGatewayTimeout)
+	}
+	return models.NewAppError("Client closed request", 499)
+}
+
+// simulateError decides whether a step fails. Failures asked for in the
+// request body or forced by its headers win over injected faults, which
+// win over the configured error rate; status is the code the caller should
+// fail with, or 0 for the step's usual one. A step served by a downstream
+// skips the error rate, since the downstream's answer already decided it.
+func (s *Server) simulateError(ctx context.Context, step string, served bool) (failed bool, status int) {
+	if status, ok := faults.ArgFailure(ctx, step); ok {
+		return true, status
+	}
+	if status, ok := faults.FromContext(ctx).Failure(step); ok {
+		s.recordOverride(ctx, "fail", step)
+		return true, status
+	}
+	if failed, status := s.injectedFailure(ctx, step); failed {
+		return true, status
+	}
+	cfg := s.config(ctx)
+	if !cfg.SimulateErrors || served {
+		return false, 0
+	}
+	return s.randFloat64(ctx) < cfg.ErrorRate, 0
+}
+
+// recordOverride logs and counts a per-request override that took effect
+func (s *Server) recordOverride(ctx context.Context, kind, step string) {
+	o := faults.FromContext(ctx)
+	log.Warnf("[%s] Forced %s on %s step", o.RequestID, kind, step)
+	s.metrics.FaultOverrides.WithLabelValues(kind, step).Inc()
+}
+
+// randFor returns the request's seeded source, falling back to the run-wide one
+func (s *Server) randFor(ctx context.Context) *random.Rand {
+	if r := random.FromContext(ctx); r != nil {
+		return r
 	}
-	time.Sleep(time.Duration(delay) * time.Millisecond)
+	return s.rng
+}
+
+func (s *Server) randFloat64(ctx context.Context) float64 {
+	return s.randFor(ctx).Float64()
 }
 
-func simulateError() bool {
-	if !cfg.SimulateErrors {
-		return false
+func (s *Server) randIntn(ctx context.Context, n int) int {
+	return s.randFor(ctx).Intn(n)
+}
+
+// statusOr returns status unless it is unset
+func statusOr(status, fallback int) int {
+	if status == 0 {
+		return fallback
 	}
-	return rand.Float64() < cfg.ErrorRate
+	return status
 }
 
-func simulateDBQuery() (bool, error) {
-	startTime := time.Now()
-	simulateDelay(cfg.DBQueryDelay/2, cfg.DBQueryDelay)
-	duration := time.Since(startTime)
+func (s *Server) simulateDBQuery(ctx context.Context) (ok bool, err error) {
+	startTime := s.clk.Now()
+	defer func() { s.live.RecordDependency("db", err != nil, s.clk.Since(startTime)) }()
+	delay := s.config(ctx).DBQueryDelay
+	served, downErr := s.simulateDependency(ctx, "db", delay/2, delay)
+	duration := s.clk.Since(startTime)
+	
+	s.metrics.DBQueryDuration.Observe(float64(duration.Milliseconds()))
+	s.metrics.DBQueriesTotal.Inc()
 	
-	metrics.DBQueryDuration.Observe(float64(duration.Milliseconds()))
-	metrics.DBQueriesTotal.Inc()
+	if err := ctx.Err(); err != nil {
+		return false, contextError(err)
+	}
+	
+	if downErr != nil {
+		log.Errorf("Database query failed downstream after %v: %v", duration, downErr)
+		s.metrics.DBQueryErrors.Inc()
+		return false, downErr
+	}
 	
-	if simulateError() {
+	if failed, status := s.simulateError(ctx, "db", served); failed {
 		log.Errorf("Database query failed after %v", duration)
-		metrics.DBQueryErrors.Inc()
-		return false, models.NewAppError("Database query failed", http.StatusInternalServerError)
+		s.metrics.DBQueryErrors.Inc()
+		return false, models.NewAppError("Database query failed", statusOr(status, http.StatusInternalServerError))
 	}
 	
 	return true, nil
 }
 
-func simulateExternalAPICall() (bool, error) {
-	startTime := time.Now()
-	simulateDelay(cfg.APICallDelay/2, cfg.APICallDelay)
-	duration := time.Since(startTime)
+func (s *Server) simulateExternalAPICall(ctx context.Context) (ok bool, err error) {
+	startTime := s.clk.Now()
+	defer func() { s.live.RecordDependency("external", err != nil, s.clk.Since(startTime)) }()
+	delay := s.config(ctx).APICallDelay
+	served, downErr := s.simulateDependency(ctx, "external", delay/2, delay)
+	duration := s.clk.Since(startTime)
 	
-	metrics.ExternalAPICallDuration.Observe(float64(duration.Milliseconds()))
-	metrics.ExternalAPICallsTotal.Inc()
+	s.metrics.ExternalAPICallDuration.Observe(float64(duration.Milliseconds()))
+	s.metrics.ExternalAPICallsTotal.Inc()
 	
-	if simulateError() {
+	if err := ctx.Err(); err != nil {
+		return false, contextError(err)
+	}
+	
+	if downErr != nil {
+		log.Errorf("External API call failed downstream after %v: %v", duration, downErr)
+		s.metrics.ExternalAPICallErrors.Inc()
+		return false, downErr
+	}
+	
+	if failed, status := s.simulateError(ctx, "external", served); failed {
 		log.Errorf("External API call failed after %v", duration)
-		metrics.ExternalAPICallErrors.Inc()
-		return false, models.NewAppError("External API call failed", http.StatusBadGateway)
+		s.metrics.ExternalAPICallErrors.Inc()
+		return false, models.NewAppError("External API call failed", statusOr(status, http.StatusBadGateway))
 	}
 	
 	return true, nil
 }
 
-func simulateProcessing() (bool, error) {
-	startTime := time.Now()
-	simulateDelay(cfg.ProcessDelay/2, cfg.ProcessDelay)
-	duration := time.Since(startTime)
+func (s *Server) simulateProcessing(ctx context.Context) (ok bool, err error) {
+	startTime := s.clk.Now()
+	defer func() { s.live.RecordDependency("process", err != nil, s.clk.Since(startTime)) }()
+	delay := s.config(ctx).ProcessDelay
+	served, downErr := s.simulateDependency(ctx, "process", delay/2, delay)
+	duration := s.clk.Since(startTime)
+	
+	s.metrics.ProcessingDuration.Observe(float64(duration.Milliseconds()))
+	
+	if err := ctx.Err(); err != nil {
+		return false, contextError(err)
+	}
 	
-	metrics.ProcessingDuration.Observe(float64(duration.Milliseconds()))
+	if downErr != nil {
+		log.Errorf("Processing failed downstream after %v: %v", duration, downErr)
+		s.metrics.ProcessingErrors.Inc()
+		return false, downErr
+	}
 	
-	if simulateError() {
+	if failed, status := s.simulateError(ctx, "process", served); failed {
 		log.Warnf("Processing failed after %v", duration)
-		metrics.ProcessingErrors.Inc()
-		return false, models.NewAppError("Processing failed", http.StatusInternalServerError)
+		s.metrics.ProcessingErrors.Inc()
+		return false, models.NewAppError("Processing failed", statusOr(status, http.StatusInternalServerError))
 	}
 	
 	return true, nil
 }
 
-func GetDataHandler(w http.ResponseWriter, r *http.Request) {
-	startTime := time.Now()
+func (s *Server) GetDataHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	
+	startTime := s.clk.Now()
 	requestID := r.Header.Get("X-Request-ID")
 	
 	log.Infof("[%s] Processing GET /api/data request", requestID)
 	
-	_, err := simulateDBQuery()
+	query, err := s.parseListQuery(r)
 	if err != nil {
-		log.Infof("[%s] Error in DB query: %v", requestID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 	
-	_, err = simulateProcessing()
+	response, err := s.listData(r.Context(), requestID, query, r.URL.Path, r.URL.RequestURI())
 	if err != nil {
-		log.Errorf("[%s] Error in processing: %v", requestID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 	
-	response := models.DataResponse{ //Sample Response
-		Data: []models.DataItem{
-			{ID: 1, Name: "Item 1", Value: rand.Float64() * 100},
-			{ID: 2, Name: "Item 2", Value: rand.Float64() * 100},
-			{ID: 3, Name: "Item 3", Value: rand.Float64() * 100},
-		},
-		Count: 3,
-	}
-	
-	duration := time.Since(startTime)
+	duration := s.clk.Since(startTime)
 	log.Infof("[%s] GET /api/data completed in %v", requestID, duration)
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func GetUsersHandler(w http.ResponseWriter, r *http.Request) {
-	startTime := time.Now()
+func (s *Server) GetUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	
+	startTime := s.clk.Now()
 	requestID := r.Header.Get("X-Request-ID")
 	
 	log.Infof("[%s] Processing GET /api/users request", requestID)
 	
-	_, err := simulateDBQuery()
+	query, err := s.parseListQuery(r)
 	if err != nil {
-		log.Errorf("[%s] Error in DB query: %v", requestID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 	
-	_, err = simulateExternalAPICall()
+	response, err := s.listUsers(r.Context(), requestID, query, r.URL.Path, r.URL.RequestURI())
 	if err != nil {
-		log.Errorf("[%s] Error in external API call: %v", requestID, err)
-		http.Error(w, err.Error(), http.StatusBadGateway)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 	
-	response := models.UsersResponse{ //Sample response
-		Users: []models.User{
-			{ID: 1, Name: "User 1", Email: "user1@example.com"},
-			{ID: 2, Name: "User 2", Email: "user2@example.com"},
-			{ID: 3, Name: "User 3", Email: "user3@example.com"},
-		},
-		Count: 3,
-	}
-	
-	duration := time.Since(startTime)
+	duration := s.clk.Since(startTime)
 	log.Infof("[%s] GET /api/users completed in %v", requestID, duration)
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func ProcessDataHandler(w http.ResponseWriter, r *http.Request) {
+// listData runs the simulated pipeline of a data list request, for REST and
+// gRPC alike. path labels the metrics and cacheKey identifies the request in
+// the caches.
+func (s *Server) listData(ctx context.Context, requestID string, query store.Query, path, cacheKey string) (models.DataResponse, error) {
+	page, err := s.db.QueryData(query)
+	if err != nil {
+		return models.DataResponse{}, err
+	}
+	
+	_, err = s.cachedStep(ctx, "db", cacheKey, func(ctx context.Context) (bool, error) {
+		return s.simulateScan(ctx, path, page.Scanned)
+	})
+	if err != nil {
+		log.Infof("[%s] Error in DB query: %v", requestID, err)
+		return models.DataResponse{}, err
+	}
+	
+	_, err = s.simulateProcessing(ctx)
+	if err != nil {
+		log.Errorf("[%s] Error in processing: %v", requestID, err)
+		return models.DataResponse{}, err
+	}
+	
+	s.metrics.PageSize.WithLabelValues(path).Observe(float64(len(page.Items)))
+	return models.DataResponse{
+		Data:       page.Items,
+		Count:      len(page.Items),
+		Total:      page.Total,
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+// listUsers runs the simulated pipeline of a user list request, see listData
+func (s *Server) listUsers(ctx context.Context, requestID string, query store.Query, path, cacheKey string) (models.UsersResponse, error) {
+	page, err := s.db.QueryUsers(query)
+	if err != nil {
+		return models.UsersResponse{}, err
+	}
+	
+	_, err = s.cachedStep(ctx, "db", cacheKey, func(ctx context.Context) (bool, error) {
+		return s.simulateScan(ctx, path, page.Scanned)
+	})
+	if err != nil {
+		log.Errorf("[%s] Error in DB query: %v", requestID, err)
+		return models.UsersResponse{}, err
+	}
+	
+	_, err = s.cachedStep(ctx, "external", cacheKey, s.simulateExternalAPICall)
+	if err != nil {
+		log.Errorf("[%s] Error in external API call: %v", requestID, err)
+		return models.UsersResponse{}, err
+	}
+	
+	s.metrics.PageSize.WithLabelValues(path).Observe(float64(len(page.Items)))
+	return models.UsersResponse{
+		Users:      page.Items,
+		Count:      len(page.Items),
+		Total:      page.Total,
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+func (s *Server) ProcessDataHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	
-	startTime := time.Now()
+	startTime := s.clk.Now()
 	requestID := r.Header.Get("X-Request-ID")
 	
 	log.Infof("[%s] Processing POST /api/process request", requestID)
 	
+	if maxBytes := s.config(r.Context()).MaxProcessBytes; maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	}
 	var request models.ProcessRequest
 	err := json.NewDecoder(r.Body).Decode(&request)
-	if err != nil {
-		log.Errorf("[%s] Error parsing request body: %v", requestID, err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		log.Errorf("[%s] Request body over %d bytes", requestID, tooLarge.Limit)
+		http.Error(w, fmt.Sprintf("Request body too large, at most %d bytes allowed", tooLarge.Limit), http.StatusRequestEntityTooLarge)
 		return
 	}
-	
-	_, err = simulateDBQuery()
 	if err != nil {
-		log.Errorf("[%s] Error in DB query: %v", requestID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Errorf("[%s] Error parsing request body: %v", requestID, err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 	
-	_, err = simulateProcessing()
+	args, err := s.parseProcessArgs(r.Context(), request)
 	if err != nil {
-		log.Errorf("[%s] Error in processing: %v", requestID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Errorf("[%s] Invalid process request: %v", requestID, err)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 	
-	_, err = simulateProcessing()
-	if err != nil {
-		log.Errorf("[%s] Error in secondary processing: %v", requestID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if r.URL.Query().Get("async") == "true" {
+		if request.CallbackURL != "" {
+			if err := checkCallbackURL(s.config(r.Context()), request.CallbackURL); err != nil {
+				log.Warnf("[%s] Rejecting async job: %v", requestID, err)
+				http.Error(w, err.Error(), errorStatus(err))
+				return
+			}
+		}
+		job, err := s.submitJob(r.Context(), requestID, request, args)
+		if err != nil {
+			log.Warnf("[%s] Rejecting async job: %v", requestID, err)
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		
+		log.Infof("[%s] POST /api/process queued job %s", requestID, job.ID)
+		w.Header().Set("Location", "/api/jobs/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
 		return
 	}
 	
-	_, err = simulateExternalAPICall()
+	response, err := s.runProcess(r.Context(), requestID, request, args, nil, nil)
 	if err != nil {
-		log.Errorf("[%s] Error in external API call: %v", requestID, err)
-		http.Error(w, err.Error(), http.StatusBadGateway)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 	
-	response := models.ProcessResponse{
-		Success:   true,
-		ProcessID: rand.Intn(10000),
-		Message:   "Data processed successfully",
-	}
-	
-	duration := time.Since(startTime)
-	log.Printf("[%s] POST /api/process completed in %v", requestID, duration)
+	duration := s.clk.Since(startTime)
+	log.Printf("[%s] POST /api/process completed in %v, %d items processed, %d failed",
+		requestID, duration, response.Processed, response.Failed)
 	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, processStatus(response), response)
 }
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+)
+
+// jobTask is a queued async /api/process request
+type jobTask struct {
+	job       *models.Job
+	requestID string
+	request   models.ProcessRequest
+	args      processArgs
+	ctx       context.Context // Values of the submitting request, without its cancellation
+}
+
+// submitJob queues request on the bounded worker pool. The workers are
+// started on first use; a full queue is reported as 503 so clients back off.
+func (s *Server) submitJob(ctx context.Context, requestID string, request models.ProcessRequest, args processArgs) (models.Job, error) {
+	s.startWorkers.Do(func() {
+		cfg := s.cfg.Load()
+		for i := 0; i < cfg.JobWorkers; i++ {
+			go s.jobWorker()
+		}
+		log.Infof("Started %d job workers with a queue of %d", cfg.JobWorkers, cfg.JobQueueSize)
+	})
+
+	job := &models.Job{
+		ID:        uuid.New().String(),
+		Status:    models.JobQueued,
+		CreatedAt: s.clk.Now(),
+	}
+	task := &jobTask{
+		job:       job,
+		requestID: requestID,
+		request:   request,
+		args:      args,
+		ctx:       context.WithoutCancel(ctx),
+	}
+
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	select {
+	case <-s.stop:
+		return models.Job{}, models.NewAppError("Server is shutting down", http.StatusServiceUnavailable)
+	default:
+	}
+
+	s.pruneJobs()
+
+	select {
+	case s.jobQueue <- task:
+	default:
+		return models.Job{}, models.NewAppError("Job queue is full", http.StatusServiceUnavailable)
+	}
+	s.jobs[job.ID] = job
+	s.metrics.JobQueueDepth.Inc()
+
+	return *job, nil
+}
+
+// getJob returns a snapshot of the job with the given ID
+func (s *Server) getJob(id string) (models.Job, bool) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return models.Job{}, false
+	}
+	return *job, true
+}
+
+// pruneJobs forgets finished jobs older than the retention period.
+// Callers must hold jobsMu.
+func (s *Server) pruneJobs() {
+	cutoff := s.clk.Now().Add(-time.Duration(s.cfg.Load().JobRetention) * time.Millisecond)
+	for id, job := range s.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// dropQueuedJobs fails the jobs still queued once the server stops, so
+// neither their status nor the queue depth waits for a worker that never
+// comes
+func (s *Server) dropQueuedJobs() {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	for {
+		select {
+		case task := <-s.jobQueue:
+			s.metrics.JobQueueDepth.Dec()
+			finishTime := s.clk.Now()
+			task.job.Status = models.JobFailed
+			task.job.Error = "Server shut down before the job ran"
+			task.job.FinishedAt = &finishTime
+			log.Warnf("[%s] Job %s dropped on shutdown", task.requestID, task.job.ID)
+		default:
+			return
+		}
+	}
+}
+
+func (s *Server) jobWorker() {
+	for {
+		select {
+		case task := <-s.jobQueue:
+			s.runJob(task)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *Server) runJob(task *jobTask) {
+	s.metrics.JobQueueDepth.Dec()
+	s.metrics.JobsInProgress.Inc()
+	defer s.metrics.JobsInProgress.Dec()
+
+	startTime := s.clk.Now()
+	s.jobsMu.Lock()
+	task.job.Status = models.JobRunning
+	task.job.StartedAt = &startTime
+	s.metrics.JobQueueWait.Observe(float64(startTime.Sub(task.job.CreatedAt).Milliseconds()))
+	s.jobsMu.Unlock()
+
+	log.Infof("[%s] Job %s started", task.requestID, task.job.ID)
+
+	response, err := s.runProcess(task.ctx, task.requestID, task.request, task.args, func(done, total int) {
+		s.jobsMu.Lock()
+		task.job.Progress = float64(done) / float64(total)
+		s.jobsMu.Unlock()
+	}, nil)
+
+	finishTime := s.clk.Now()
+	s.jobsMu.Lock()
+	task.job.FinishedAt = &finishTime
+	if err != nil {
+		task.job.Status = models.JobFailed
+		task.job.Error = err.Error()
+	} else {
+		task.job.Status = models.JobSucceeded
+		task.job.Result = &response
+	}
+	snapshot := *task.job
+	s.jobsMu.Unlock()
+
+	duration := finishTime.Sub(startTime)
+	s.metrics.JobDuration.Observe(float64(duration.Milliseconds()))
+	s.metrics.JobsTotal.WithLabelValues(snapshot.Status).Inc()
+	log.Infof("[%s] Job %s %s in %v", task.requestID, snapshot.ID, snapshot.Status, duration)
+
+	if task.request.CallbackURL != "" {
+		s.notifyJobCallback(task.requestID, task.request.CallbackURL, snapshot)
+	}
+}
+
+// callbackClient sends job webhooks. Redirects are not followed, so an
+// allowed host cannot bounce the POST on to one that is not.
+var callbackClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// checkCallbackURL accepts only http(s) webhooks to a host in
+// JOB_CALLBACK_HOSTS, so the public listener cannot be used to relay
+// requests anywhere else
+func checkCallbackURL(cfg *config.Config, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return models.NewAppError("callback_url must be an http or https URL", http.StatusBadRequest)
+	}
+	if !slices.Contains(cfg.CallbackHosts, u.Host) && !slices.Contains(cfg.CallbackHosts, u.Hostname()) {
+		return models.NewAppError("callback_url host "+u.Host+" is not allowed", http.StatusBadRequest)
+	}
+	return nil
+}
+
+// notifyJobCallback POSTs the finished job to the client's webhook, as
+// long as its host is still allowed
+func (s *Server) notifyJobCallback(requestID, url string, job models.Job) {
+	cfg := s.cfg.Load()
+	if err := checkCallbackURL(cfg, url); err != nil {
+		log.Warnf("[%s] Not calling back for job %s: %v", requestID, job.ID, err)
+		s.metrics.JobCallbacks.WithLabelValues("error").Inc()
+		return
+	}
+
+	body, err := json.Marshal(job)
+	if err != nil {
+		log.Errorf("[%s] Failed to encode job %s for callback: %v", requestID, job.ID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.CallbackTimeout)*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.Errorf("[%s] Invalid callback URL for job %s: %v", requestID, job.ID, err)
+		s.metrics.JobCallbacks.WithLabelValues("error").Inc()
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", requestID)
+
+	resp, err := callbackClient.Do(req)
+	if err != nil {
+		log.Warnf("[%s] Callback for job %s failed: %v", requestID, job.ID, err)
+		s.metrics.JobCallbacks.WithLabelValues("error").Inc()
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Warnf("[%s] Callback for job %s returned %d", requestID, job.ID, resp.StatusCode)
+		s.metrics.JobCallbacks.WithLabelValues("rejected").Inc()
+		return
+	}
+	s.metrics.JobCallbacks.WithLabelValues("delivered").Inc()
+}
+
+func (s *Server) GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get("X-Request-ID")
+	id := r.PathValue("id")
+
+	job, ok := s.getJob(id)
+	if !ok {
+		log.Infof("[%s] Job %s not found", requestID, id)
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/store"
+)
+
+// parseListQuery reads limit, offset, cursor, sort and field filters from
+// the query string of a list request
+func (s *Server) parseListQuery(r *http.Request) (store.Query, error) {
+	return s.listQuery(r.Context(), r.URL.Query())
+}
+
+// listQuery is parseListQuery for already parsed parameters
+func (s *Server) listQuery(ctx context.Context, params url.Values) (store.Query, error) {
+	cfg := s.config(ctx)
+	query := store.Query{
+		Filters: map[string]string{},
+		Sort:    params.Get("sort"),
+		Cursor:  params.Get("cursor"),
+		Limit:   cfg.DefaultPageSize,
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 0 {
+			return query, models.NewAppError("limit must be a non-negative integer", http.StatusBadRequest)
+		}
+		query.Limit = l
+	}
+	if cfg.MaxPageSize > 0 && query.Limit > cfg.MaxPageSize {
+		query.Limit = cfg.MaxPageSize
+	}
+
+	if offset := params.Get("offset"); offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil || o < 0 {
+			return query, models.NewAppError("offset must be a non-negative integer", http.StatusBadRequest)
+		}
+		query.Offset = o
+	}
+
+	for name := range params {
+		if store.IsFilter(name) {
+			query.Filters[name] = params.Get(name)
+		}
+	}
+
+	return query, nil
+}
+
+// simulateScan runs the simulated DB query for a list request and adds the
+// cost of every row the query had to examine on top of the fixed query delay
+func (s *Server) simulateScan(ctx context.Context, path string, rows int) (bool, error) {
+	s.metrics.DBRowsScanned.WithLabelValues(path).Observe(float64(rows))
+	s.sleep(ctx, time.Duration(rows*s.config(ctx).DBRowCost)*time.Microsecond)
+	return s.simulateDBQuery(ctx)
+}
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Unic-X/slow-server/faults"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/charmbracelet/log"
+)
+
+// Reserved ProcessRequest.Args keys that steer the simulation. Any other
+// key is passed through untouched.
+const (
+	argFailStep      = "fail_step"       // "db", "process:503,external" - force steps to fail
+	argFailItems     = "fail_items"      // "0,2" - indices of items that must fail
+	argItemErrorRate = "item_error_rate" // 0 to 1, overrides ITEM_ERROR_RATE
+	argItemDelay     = "item_delay_ms"   // overrides ITEM_DELAY
+)
+
+// processArgs is the parsed form of the reserved Args keys
+type processArgs struct {
+	failSteps     map[string]int
+	failItems     map[int]bool
+	itemErrorRate float64 // Negative means use the config
+	itemDelay     int     // Negative means use the config
+}
+
+// processStep is one stage of the /api/process pipeline
+type processStep struct {
+	name  string // Step name used by fail_step
+	label string
+	run   func(s *Server, ctx context.Context, run *processRun) error
+}
+
+// processRun carries the state of one pipeline execution
+type processRun struct {
+	requestID string
+	request   models.ProcessRequest
+	args      processArgs
+	results   []models.ItemResult
+	progress  func()
+	onResult  func(models.ItemResult) // Called as soon as an item is done, may be nil
+}
+
+var processPipeline = []processStep{
+	{"db", "DB query", func(s *Server, ctx context.Context, _ *processRun) error {
+		_, err := s.simulateDBQuery(ctx)
+		return err
+	}},
+	{"process", "processing", func(s *Server, ctx context.Context, _ *processRun) error {
+		_, err := s.simulateProcessing(ctx)
+		return err
+	}},
+	{"process", "secondary processing", func(s *Server, ctx context.Context, _ *processRun) error {
+		_, err := s.simulateProcessing(ctx)
+		return err
+	}},
+	{"items", "item processing", (*Server).processItems},
+	{"external", "external API call", func(s *Server, ctx context.Context, _ *processRun) error {
+		_, err := s.simulateExternalAPICall(ctx)
+		return err
+	}},
+}
+
+// parseProcessArgs validates the request and its reserved Args keys
+func (s *Server) parseProcessArgs(ctx context.Context, request models.ProcessRequest) (processArgs, error) {
+	args := processArgs{
+		failSteps:     map[string]int{},
+		failItems:     map[int]bool{},
+		itemErrorRate: -1,
+		itemDelay:     -1,
+	}
+
+	cfg := s.config(ctx)
+	if cfg.MaxItems > 0 && len(request.Items) > cfg.MaxItems {
+		return args, models.NewAppError(
+			fmt.Sprintf("Too many items: %d, at most %d allowed", len(request.Items), cfg.MaxItems),
+			http.StatusRequestEntityTooLarge)
+	}
+	// Every item byte is held ItemMemoryFactor times over, so the body
+	// limit alone would not cover gRPC
+	itemBytes := 0
+	for _, item := range request.Items {
+		itemBytes += len(item)
+	}
+	if cfg.MaxProcessBytes > 0 && itemBytes > cfg.MaxProcessBytes {
+		return args, models.NewAppError(
+			fmt.Sprintf("Items too large: %d bytes, at most %d allowed", itemBytes, cfg.MaxProcessBytes),
+			http.StatusRequestEntityTooLarge)
+	}
+
+	if v, ok := request.Args[argFailStep]; ok {
+		for _, entry := range strings.Split(v, ",") {
+			step, code, hasCode := strings.Cut(strings.TrimSpace(entry), ":")
+			if step != "db" && step != "process" && step != "external" {
+				return args, badArg(argFailStep, "must name db, process or external")
+			}
+			status := 0
+			if hasCode {
+				n, err := strconv.Atoi(code)
+				if err != nil || n < 400 || n > 599 {
+					return args, badArg(argFailStep, "status must be between 400 and 599")
+				}
+				status = n
+			}
+			args.failSteps[step] = status
+		}
+	}
+
+	if v, ok := request.Args[argFailItems]; ok {
+		for _, entry := range strings.Split(v, ",") {
+			i, err := strconv.Atoi(strings.TrimSpace(entry))
+			if err != nil || i < 0 || i >= len(request.Items) {
+				return args, badArg(argFailItems, "must list valid item indices")
+			}
+			args.failItems[i] = true
+		}
+	}
+
+	if v, ok := request.Args[argItemErrorRate]; ok {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return args, badArg(argItemErrorRate, "must be between 0 and 1")
+		}
+		args.itemErrorRate = rate
+	}
+
+	if v, ok := request.Args[argItemDelay]; ok {
+		delay, err := strconv.Atoi(v)
+		if err != nil || delay < 0 {
+			return args, badArg(argItemDelay, "must be a non-negative integer")
+		}
+		args.itemDelay = delay
+	}
+
+	return args, nil
+}
+
+func badArg(key, reason string) error {
+	return models.NewAppError(fmt.Sprintf("Invalid args.%s: %s", key, reason), http.StatusBadRequest)
+}
+
+// runProcess executes the processing pipeline, reporting progress after each
+// completed step and item and every item's result through onResult. It is
+// shared by the synchronous handler, the async job workers and gRPC.
+func (s *Server) runProcess(ctx context.Context, requestID string, request models.ProcessRequest, args processArgs, progress func(done, total int), onResult func(models.ItemResult)) (models.ProcessResponse, error) {
+	ctx = faults.WithArgFailures(ctx, args.failSteps)
+
+	done, total := 0, len(processPipeline)-1+len(request.Items)
+	run := &processRun{
+		requestID: requestID,
+		request:   request,
+		args:      args,
+		onResult:  onResult,
+		progress: func() {
+			done++
+			if progress != nil {
+				progress(done, total)
+			}
+		},
+	}
+
+	for _, step := range processPipeline {
+		if err := step.run(s, ctx, run); err != nil {
+			log.Errorf("[%s] Error in %s: %v", requestID, step.label, err)
+			return models.ProcessResponse{}, err
+		}
+		if step.name != "items" {
+			run.progress()
+		}
+	}
+
+	response := models.ProcessResponse{Results: run.results}
+	for _, result := range run.results {
+		if result.Status == models.ItemOK {
+			response.Processed++
+		} else {
+			response.Failed++
+		}
+	}
+	response.Success = response.Failed == 0
+
+	switch {
+	case response.Success:
+		response.Message = "Data processed successfully"
+	case response.Processed > 0:
+		response.Message = "Data partially processed"
+	default:
+		response.Message = "No items could be processed"
+	}
+
+	return response, nil
+}
+
+// processItems handles each item in turn. Processing time grows with the
+// item size and each item's memory stays allocated until the whole batch is
+// done, so large batches show up in the heap as well as in latency.
+func (s *Server) processItems(ctx context.Context, run *processRun) error {
+	items := run.request.Items
+	s.metrics.ProcessItems.Observe(float64(len(items)))
+
+	cfg := s.config(ctx)
+	itemDelay := cfg.ItemDelay
+	if run.args.itemDelay >= 0 {
+		itemDelay = run.args.itemDelay
+	}
+	errorRate := 0.0
+	if cfg.SimulateErrors {
+		errorRate = cfg.ItemErrorRate
+	}
+	if run.args.itemErrorRate >= 0 {
+		errorRate = run.args.itemErrorRate
+	}
+
+	held := make([][]byte, 0, len(items))
+	heldBytes := 0
+
+	for i, item := range items {
+		startTime := s.clk.Now()
+
+		buf := make([]byte, len(item)*cfg.ItemMemoryFactor)
+		for j := 0; j < len(buf); j += 4096 { // Touch every page so it is really resident
+			buf[j] = 1
+		}
+		held = append(held, buf)
+		heldBytes += len(buf)
+
+		delay := time.Duration(itemDelay)*time.Millisecond + time.Duration(len(item)*cfg.ItemByteCost)*time.Microsecond
+		s.sleep(ctx, delay)
+		if err := ctx.Err(); err != nil {
+			return contextError(err)
+		}
+
+		duration := s.clk.Since(startTime)
+		s.metrics.ProcessItemDuration.Observe(float64(duration.Milliseconds()))
+
+		result := models.ItemResult{
+			Index:      i,
+			Item:       item,
+			Status:     models.ItemOK,
+			DurationMs: duration.Milliseconds(),
+		}
+		if run.args.failItems[i] || s.randFloat64(ctx) < errorRate {
+			log.Warnf("[%s] Item %d failed after %v", run.requestID, i, duration)
+			s.metrics.ProcessItemErrors.Inc()
+			result.Status = models.ItemFailed
+			result.Error = "Item processing failed"
+		}
+		run.results = append(run.results, result)
+		if run.onResult != nil {
+			run.onResult(result)
+		}
+		run.progress()
+	}
+
+	s.metrics.ProcessMemoryBytes.Observe(float64(heldBytes))
+	runtime.KeepAlive(held)
+	return nil
+}
+
+// processStatus is 200 when every item succeeded, 207 when only some did and
+// 422 when none did
+func processStatus(response models.ProcessResponse) int {
+	switch {
+	case response.Failed == 0:
+		return http.StatusOK
+	case response.Processed > 0:
+		return http.StatusMultiStatus
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
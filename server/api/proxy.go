@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/Unic-X/slow-server/faults"
+	"github.com/Unic-X/slow-server/trace"
+	"github.com/charmbracelet/log"
+)
+
+// Outcomes of a proxied request
+const (
+	proxyForwarded     = "forwarded"
+	proxyInjectedError = "injected_error"
+	proxyUpstreamError = "upstream_error"
+)
+
+type proxyRuleKey struct{}
+
+// ProxyHandler forwards a request to PROXY_UPSTREAM after the delay of the
+// first matching proxy rule, or fails it with the rule's status instead.
+// The "upstream" step of X-Slow-Delay and X-Slow-Fail forces either; the
+// one-off faults and the running scenario's add to the delay and fail the
+// request before the rule's error rate is rolled.
+func (s *Server) ProxyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	requestID := r.Header.Get("X-Request-ID")
+	rule := s.config(ctx).ProxyRuleFor(r.Method, r.URL.Path)
+	label := rule.String()
+
+	startTime := s.clk.Now()
+	s.simulateDelay(ctx, "upstream", rule.MinDelay, rule.MaxDelay)
+	injected := s.clk.Since(startTime)
+	s.metrics.ProxyInjectedDelay.WithLabelValues(label).Observe(float64(injected.Milliseconds()))
+
+	if err := ctx.Err(); err != nil {
+		err = contextError(err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	failed, status := false, 0
+	if forced, ok := faults.FromContext(ctx).Failure("upstream"); ok {
+		s.recordOverride(ctx, "fail", "upstream")
+		failed, status = true, forced
+	} else if injected, injectedStatus := s.injectedFailure(ctx, "upstream"); injected {
+		failed, status = true, injectedStatus
+	} else if rule.ErrorRate > 0 {
+		failed = s.randFloat64(ctx) < rule.ErrorRate
+	}
+	if failed {
+		status = statusOr(status, rule.ErrorStatus)
+		log.Warnf("[%s] Failing proxied %s %s with %d per rule %s", requestID, r.Method, r.URL.Path, status, label)
+		s.metrics.ProxyRequests.WithLabelValues(label, proxyInjectedError).Inc()
+		http.Error(w, "Injected upstream failure", status)
+		return
+	}
+
+	log.Infof("[%s] Forwarding %s %s after %v per rule %s", requestID, r.Method, r.URL.Path, injected, label)
+	s.proxy.ServeHTTP(w, r.WithContext(context.WithValue(ctx, proxyRuleKey{}, label)))
+}
+
+// newProxy builds the reverse proxy to PROXY_UPSTREAM. The upstream is read
+// per request, like the rest of the config.
+func (s *Server) newProxy() *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			upstream, _ := url.Parse(s.config(pr.In.Context()).ProxyUpstream)
+			pr.SetURL(upstream)
+			pr.SetXForwarded()
+
+			// Override headers are meant for the proxy, not the upstream
+			for name := range pr.Out.Header {
+				if strings.HasPrefix(name, "X-Slow-") {
+					pr.Out.Header.Del(name)
+				}
+			}
+			if tc, ok := trace.FromContext(pr.In.Context()); ok {
+				pr.Out.Header.Set(trace.Header, tc.Child().Traceparent())
+			}
+		},
+		Transport: &timedTransport{s: s, next: http.DefaultTransport},
+		ModifyResponse: func(resp *http.Response) error {
+			label, _ := resp.Request.Context().Value(proxyRuleKey{}).(string)
+			s.metrics.ProxyRequests.WithLabelValues(label, proxyForwarded).Inc()
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			label, _ := r.Context().Value(proxyRuleKey{}).(string)
+			log.Errorf("[%s] Upstream %s failed: %v", r.Header.Get("X-Request-ID"), s.config(r.Context()).ProxyUpstream, err)
+			s.metrics.ProxyRequests.WithLabelValues(label, proxyUpstreamError).Inc()
+			http.Error(w, "Upstream unavailable", http.StatusBadGateway)
+		},
+	}
+}
+
+// timedTransport records how long the upstream takes to answer, apart
+// from the delay the proxy injected before
+type timedTransport struct {
+	s    *Server
+	next http.RoundTripper
+}
+
+func (t *timedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	startTime := t.s.clk.Now()
+	resp, err := t.next.RoundTrip(req)
+	duration := t.s.clk.Since(startTime)
+	label, _ := req.Context().Value(proxyRuleKey{}).(string)
+	t.s.metrics.ProxyUpstreamDuration.WithLabelValues(label).Observe(float64(duration.Milliseconds()))
+	t.s.live.RecordDependency("upstream", err != nil || resp.StatusCode >= 500, duration)
+	return resp, err
+}
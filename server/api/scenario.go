@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/charmbracelet/log"
+)
+
+// ScenarioHandler shows the running scenario on GET, null without one.
+// POST starts the models.Scenario in the body in place of the running one,
+// DELETE stops it. One-off faults are left alone either way.
+func (s *Server) ScenarioHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get("X-Request-ID")
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.scenarioStatus())
+	case http.MethodPost:
+		var scenario models.Scenario
+		if err := json.NewDecoder(r.Body).Decode(&scenario); err != nil {
+			log.Errorf("[%s] Error parsing request body: %v", requestID, err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := validateScenario(&scenario); err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		s.startScenario(scenario)
+		log.Warnf("[%s] Started scenario %s with %d phases", requestID, scenario.Name, len(scenario.Phases))
+		writeJSON(w, http.StatusCreated, s.scenarioStatus())
+	case http.MethodDelete:
+		if name := s.stopScenario(); name != "" {
+			log.Warnf("[%s] Stopped scenario %s", requestID, name)
+		}
+		writeJSON(w, http.StatusOK, s.scenarioStatus())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// validateScenario checks every phase and gives its faults the phase's
+// duration
+func validateScenario(scenario *models.Scenario) error {
+	switch {
+	case scenario.Name == "":
+		return models.NewAppError("name is required", http.StatusBadRequest)
+	case len(scenario.Phases) == 0:
+		return models.NewAppError("phases are required", http.StatusBadRequest)
+	}
+	for i := range scenario.Phases {
+		phase := &scenario.Phases[i]
+		if phase.DurationMs <= 0 {
+			return models.NewAppError(fmt.Sprintf("phase %d: duration_ms must be positive", i+1), http.StatusBadRequest)
+		}
+		for j := range phase.Faults {
+			phase.Faults[j].DurationMs = phase.DurationMs
+			if err := validateFault(phase.Faults[j]); err != nil {
+				return models.NewAppError(fmt.Sprintf("phase %d: %v", i+1, err), errorStatus(err))
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) startScenario(scenario models.Scenario) {
+	now := s.clk.Now()
+
+	s.faultsMu.Lock()
+	defer s.faultsMu.Unlock()
+	s.scenario = &scenario
+	s.scenarioAt = now
+}
+
+// stopScenario stops the running scenario and returns its name, or "" if
+// none was running
+func (s *Server) stopScenario() string {
+	s.faultsMu.Lock()
+	defer s.faultsMu.Unlock()
+
+	name := ""
+	if s.scenario != nil {
+		name = s.scenario.Name
+	}
+	s.scenario = nil
+	return name
+}
+
+// scenarioStatus returns the phase the running scenario is in, or nil
+// without one. Phases are worked out from the start time on every call, so
+// a scenario past its last phase is only dropped here.
+func (s *Server) scenarioStatus() *models.ScenarioStatus {
+	now := s.clk.Now()
+
+	s.faultsMu.Lock()
+	defer s.faultsMu.Unlock()
+	if s.scenario == nil {
+		return nil
+	}
+
+	var cycle time.Duration
+	for _, phase := range s.scenario.Phases {
+		cycle += time.Duration(phase.DurationMs) * time.Millisecond
+	}
+	elapsed := now.Sub(s.scenarioAt)
+	if elapsed >= cycle && !s.scenario.Repeat {
+		s.scenario = nil
+		return nil
+	}
+
+	phaseStart := s.scenarioAt.Add(elapsed / cycle * cycle)
+	for i, phase := range s.scenario.Phases {
+		phaseUntil := phaseStart.Add(time.Duration(phase.DurationMs) * time.Millisecond)
+		if now.Before(phaseUntil) {
+			status := &models.ScenarioStatus{
+				Name:       s.scenario.Name,
+				Phase:      i + 1,
+				Phases:     len(s.scenario.Phases),
+				PhaseName:  phase.Name,
+				Started:    s.scenarioAt,
+				PhaseUntil: phaseUntil,
+				Repeat:     s.scenario.Repeat,
+			}
+			for j, fault := range phase.Faults {
+				status.Faults = append(status.Faults, models.InjectedFault{
+					ID:           fmt.Sprintf("%s/%d.%d", s.scenario.Name, i+1, j+1),
+					FaultRequest: fault,
+					Until:        phaseUntil,
+				})
+			}
+			return status
+		}
+		phaseStart = phaseUntil
+	}
+	return nil
+}
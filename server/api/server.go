@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"time"
+
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/live"
+	"github.com/Unic-X/slow-server/metrics"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/random"
+	"github.com/Unic-X/slow-server/slo"
+	"github.com/Unic-X/slow-server/store"
+	"github.com/Unic-X/slow-server/traffic"
+	"github.com/graphql-go/graphql"
+)
+
+// Server is one simulated service: its config, data set, random source,
+// clock, metrics, caches and async jobs. Handlers are methods on it, so
+// several servers can run side by side in one process without sharing state.
+type Server struct {
+	cfg      *config.Current
+	configMu sync.Mutex // Serializes /admin/config patches
+	db       *store.Store
+	rng      *random.Rand // Used by code running outside a request with its own source
+	clk      clock.Clock
+
+	metrics *metrics.Metrics
+	deg     *degrader
+
+	cachesMu sync.Mutex
+	caches   map[string]*simCache
+
+	jobsMu       sync.Mutex
+	jobs         map[string]*models.Job
+	jobQueue     chan *jobTask
+	startWorkers sync.Once
+	stop         chan struct{}
+	stopOnce     sync.Once
+
+	faultsMu   sync.Mutex
+	injected   []models.InjectedFault // One-off faults from /admin/faults
+	scenario   *models.Scenario       // Running scenario from /admin/scenario
+	scenarioAt time.Time              // and when it started
+
+	schemaOnce sync.Once
+	schema     graphql.Schema
+
+	proxy   *httputil.ReverseProxy
+	slo     *slo.Tracker
+	live    *live.Stats
+	traffic *traffic.Recorder
+}
+
+// Option customizes a Server
+type Option func(*Server)
+
+// WithClock injects the clock every simulated delay sleeps on
+func WithClock(c clock.Clock) Option {
+	return func(s *Server) {
+		s.clk = c
+	}
+}
+
+// WithRand injects the run-wide random source, e.g. the one the seed
+// middleware derives per-request seeds from
+func WithRand(r *random.Rand) Option {
+	return func(s *Server) {
+		s.rng = r
+	}
+}
+
+// WithMetrics records into m, e.g. metrics registered on the registry
+// that /metrics is served from
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *Server) {
+		s.metrics = m
+	}
+}
+
+// NewServer creates a server for cfg with its data store and random source
+// seeded from it. cfg is read on every request, so later changes apply
+// until /admin/config swaps in a patched copy.
+// Without WithMetrics the server records into unregistered metrics.
+func NewServer(cfg *config.Config, opts ...Option) *Server {
+	s := &Server{
+		cfg:      config.NewCurrent(cfg),
+		db:       store.New(cfg.StoreSize, cfg.StoreSeed),
+		rng:      random.New(cfg.Seed),
+		clk:      clock.Real,
+		metrics:  metrics.New(nil),
+		deg:      newDegrader(cfg),
+		caches:   map[string]*simCache{},
+		jobs:     map[string]*models.Job{},
+		jobQueue: make(chan *jobTask, cfg.JobQueueSize),
+		stop:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.proxy = s.newProxy()
+	s.slo = slo.NewTracker(cfg.SLOs, s.metrics, s.clk)
+	s.live = live.New(s.clk)
+	s.traffic = traffic.New(cfg.TrafficRecordSize)
+	return s
+}
+
+// Config holds the config the server runs with, for the middleware to read
+// the same snapshots
+func (s *Server) Config() *config.Current {
+	return s.cfg
+}
+
+// config returns the config snapshot of the request ctx belongs to
+func (s *Server) config(ctx context.Context) *config.Config {
+	return s.cfg.For(ctx)
+}
+
+// Metrics returns the metrics the server records into
+func (s *Server) Metrics() *metrics.Metrics {
+	return s.metrics
+}
+
+// Router is what Routes registers handlers on: an *http.ServeMux, or
+// anything else that wants to know the route table
+type Router interface {
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
+// Routes registers every API handler and the SLO reports on mux. In proxy
+// mode the API is replaced by the reverse proxy to PROXY_UPSTREAM, while
+// the SLO reports stay local.
+func (s *Server) Routes(mux Router) {
+	mux.HandleFunc("/admin/slo", s.SLOHandler)
+	mux.HandleFunc("/admin/slo/rules", s.SLORulesHandler)
+	if s.cfg.Load().ProxyUpstream != "" {
+		mux.HandleFunc("/", s.degraded(s.ProxyHandler))
+		mux.HandleFunc("/admin/", http.NotFound) // Never forwarded, see config.Proxies
+		return
+	}
+
+	mux.HandleFunc("/api/data", s.degraded(s.GetDataHandler))
+	mux.HandleFunc("/api/users", s.degraded(s.GetUsersHandler))
+	mux.HandleFunc("POST /api/users", s.degraded(s.CreateUserHandler))
+	mux.HandleFunc("GET /api/users/{id}", s.degraded(s.GetUserHandler))
+	mux.HandleFunc("PUT /api/users/{id}", s.degraded(s.UpdateUserHandler))
+	mux.HandleFunc("DELETE /api/users/{id}", s.degraded(s.DeleteUserHandler))
+	mux.HandleFunc("/api/process", s.degraded(s.ProcessDataHandler))
+	mux.HandleFunc("GET /api/jobs/{id}", s.degraded(s.GetJobHandler))
+	mux.HandleFunc("POST /api/batch", s.degraded(s.BatchHandler))
+	mux.HandleFunc("GET /api/stream", s.degraded(s.StreamHandler))
+	mux.HandleFunc("GET /ws", s.degraded(s.WebSocketHandler))
+	mux.HandleFunc("/graphql", s.degraded(s.GraphQLHandler))
+}
+
+// AdminRoutes registers the admin handlers that change the server or show
+// its live state. Anyone reaching them can rewrite the config, so they
+// belong on the cluster-internal admin listener, never on mux of Routes.
+func (s *Server) AdminRoutes(mux Router) {
+	mux.HandleFunc("/admin/cache/flush", s.CacheFlushHandler)
+	mux.HandleFunc("/admin/degrade", s.DegradeHandler)
+	mux.HandleFunc("/admin/degrade/reset", s.DegradeResetHandler)
+	mux.HandleFunc("/admin/stats", s.StatsHandler)
+	mux.HandleFunc("/admin/ui", s.UIHandler)
+	mux.HandleFunc("/admin/faults", s.FaultsHandler)
+	mux.HandleFunc("/admin/scenario", s.ScenarioHandler)
+	mux.HandleFunc("/admin/traffic", s.TrafficHandler)
+	mux.HandleFunc("/admin/config", s.ConfigHandler)
+}
+
+// Close stops the job workers and frees what the degradation modes leaked.
+// Jobs still queued are dropped as failed.
+func (s *Server) Close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		s.dropQueuedJobs()
+		s.resetDegradation()
+	})
+}
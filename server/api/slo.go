@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/slo"
+)
+
+// SLOTracker returns the tracker of the configured SLOs, which the SLO
+// middleware records into and which exports their SLIs and burn rates
+func (s *Server) SLOTracker() *slo.Tracker {
+	return s.slo
+}
+
+// SLOHandler reports every SLO over every window, as a page for browsers
+// and as JSON otherwise
+func (s *Server) SLOHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	statuses := s.slo.Status()
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		writeJSON(w, http.StatusOK, statuses)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	sloPage.Execute(w, statuses)
+}
+
+// SLORulesHandler serves the Prometheus recording and alerting rules of
+// the configured SLOs
+func (s *Server) SLORulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write([]byte(slo.Rules(s.config(r.Context()).SLOs)))
+}
+
+var sloPage = template.Must(template.New("slo").Funcs(template.FuncMap{
+	"percent": func(v float64) string {
+		return fmt.Sprintf("%.2f%%", v*100)
+	},
+	"sli": func(v *float64) string {
+		if v == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f%%", *v*100)
+	},
+	"burn": func(v *float64) string {
+		if v == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f", *v)
+	},
+	"burning": func(w models.SLOWindow) bool {
+		return w.BurnRate != nil && *w.BurnRate > 1
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="10">
+<title>SLOs</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.burning { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>SLOs</h1>
+{{range .}}
+<h2>{{.Name}}</h2>
+<p>{{if .Method}}{{.Method}} {{end}}{{.Path}}: {{percent .Objective}} of requests succeed{{if .LatencyMs}} within {{.LatencyMs}}ms{{end}}</p>
+<table>
+<tr><th>Window</th><th>Good</th><th>Total</th><th>SLI</th><th>Burn rate</th></tr>
+{{range .Windows}}<tr{{if burning .}} class="burning"{{end}}><td>{{.Window}}</td><td>{{.Good}}</td><td>{{.Total}}</td><td>{{sli .SLI}}</td><td>{{burn .BurnRate}}</td></tr>
+{{end}}</table>
+{{else}}
+<p>No SLOs configured, see SLOS.</p>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/charmbracelet/log"
+)
+
+// streamArgs are the options of one /api/stream connection
+type streamArgs struct {
+	lastID          int // Last event the client saw, from Last-Event-ID
+	count           int // Events to send before ending the stream, 0 for no end
+	disconnectAfter int // Drop the connection after this many events, 0 never
+}
+
+func parseStreamArgs(r *http.Request) (streamArgs, error) {
+	var args streamArgs
+
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		n, err := strconv.Atoi(id)
+		if err != nil || n < 0 {
+			return args, models.NewAppError("Last-Event-ID must be a non-negative integer", http.StatusBadRequest)
+		}
+		args.lastID = n
+	}
+
+	params := r.URL.Query()
+	for name, dst := range map[string]*int{"count": &args.count, "disconnect_after": &args.disconnectAfter} {
+		if v := params.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return args, models.NewAppError(name+" must be a non-negative integer", http.StatusBadRequest)
+			}
+			*dst = n
+		}
+	}
+
+	return args, nil
+}
+
+// StreamHandler sends DataItem updates as Server-Sent Events at jittered
+// intervals, with heartbeat comments during long gaps. Event IDs count up
+// across reconnects: a client sending Last-Event-ID resumes after that event.
+// Streams are dropped mid-way at StreamDisconnectRate, or after
+// ?disconnect_after events, to exercise client reconnect logic.
+func (s *Server) StreamHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get("X-Request-ID")
+
+	args, err := parseStreamArgs(r)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	items := s.db.ListData()
+	if len(items) == 0 {
+		http.Error(w, "No data to stream", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		log.Errorf("[%s] Streaming unsupported: %v", requestID, err)
+		return
+	}
+
+	s.metrics.ActiveStreams.Inc()
+	defer s.metrics.ActiveStreams.Dec()
+	log.Infof("[%s] Streaming events from id %d", requestID, args.lastID+1)
+
+	ctx := r.Context()
+	heartbeat := time.Duration(s.config(ctx).StreamHeartbeat) * time.Millisecond
+	due := s.clk.Now().Add(s.streamInterval(ctx))
+	for sent := 0; args.count == 0 || sent < args.count; {
+		wait := due.Sub(s.clk.Now())
+		beat := heartbeat > 0 && wait > heartbeat
+		if beat {
+			wait = heartbeat
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Infof("[%s] Client left the stream after %d events", requestID, sent)
+			return
+		case <-s.stop:
+			return
+		case <-s.clk.After(wait):
+		}
+
+		if beat {
+			fmt.Fprint(w, ": heartbeat\n\n")
+			if err := rc.Flush(); err != nil {
+				return
+			}
+			continue
+		}
+
+		id := args.lastID + sent + 1
+		item := items[(id-1)%len(items)]
+		item.Value = math.Round(s.randFloat64(ctx)*10000) / 100
+		data, _ := json.Marshal(item)
+		fmt.Fprintf(w, "id: %d\nevent: update\ndata: %s\n\n", id, data)
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		sent++
+		s.metrics.StreamEvents.Inc()
+		s.metrics.StreamEventLag.Observe(float64(s.clk.Since(due).Milliseconds()))
+
+		if rate := s.config(ctx).StreamDisconnectRate; sent == args.disconnectAfter || rate > 0 && s.randFloat64(ctx) < rate {
+			log.Warnf("[%s] Dropping the stream after event %d", requestID, id)
+			s.metrics.StreamDisconnects.Inc()
+			dropConn(w)
+			return
+		}
+		due = due.Add(s.streamInterval(ctx))
+	}
+}
+
+// streamInterval returns the time until the next event, StreamInterval
+// moved by up to StreamJitter either way
+func (s *Server) streamInterval(ctx context.Context) time.Duration {
+	cfg := s.config(ctx)
+	return s.jittered(ctx, cfg.StreamInterval, cfg.StreamJitter)
+}
+
+// jittered returns base ms moved by up to jitter ms either way, never below 0
+func (s *Server) jittered(ctx context.Context, base, jitter int) time.Duration {
+	if jitter > 0 {
+		base += s.randIntn(ctx, 2*jitter+1) - jitter
+	}
+	if base < 0 {
+		base = 0
+	}
+	return time.Duration(base) * time.Millisecond
+}
+
+// dropConn closes the client connection without ending the response, the
+// way a crashing server or an idle-timeout on a proxy would
+func dropConn(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
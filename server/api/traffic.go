@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Unic-X/slow-server/traffic"
+)
+
+// Traffic returns the recorder the traffic middleware records API requests
+// into
+func (s *Server) Traffic() *traffic.Recorder {
+	return s.traffic
+}
+
+// TrafficHandler lists the recorded requests, oldest first. ?limit= keeps
+// only the most recent ones.
+func (s *Server) TrafficHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, s.traffic.Snapshot(limit))
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/Unic-X/slow-server/faults"
+	"github.com/Unic-X/slow-server/live"
+	"github.com/Unic-X/slow-server/models"
+)
+
+// LiveStats returns the sliding-window statistics /admin/ui shows, which
+// the live stats middleware records routes into
+func (s *Server) LiveStats() *live.Stats {
+	return s.live
+}
+
+// StatsHandler reports the rate, error rate and latency percentiles of every
+// route and dependency over the last minute, and the faults in effect
+func (s *Server) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats := models.LiveStats{
+		WindowSeconds: int(live.Window.Seconds()),
+		Faults:        s.activeFaults(r.Context()),
+	}
+	stats.Routes, stats.Dependencies = s.live.Snapshot()
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// activeFaults collects the faults the config and the degradation modes
+// currently inject
+func (s *Server) activeFaults(ctx context.Context) models.ActiveFaults {
+	cfg := s.config(ctx)
+	active := models.ActiveFaults{
+		Degrade:       s.degradeStatus(),
+		Downstreams:   cfg.Downstreams,
+		ProxyUpstream: cfg.ProxyUpstream,
+		Injected:      s.injectedFaults(),
+		Scenario:      s.scenarioStatus(),
+	}
+	if cfg.SimulateErrors {
+		active.ErrorRate = cfg.ErrorRate
+	}
+	for _, kind := range faults.NetworkFaults {
+		if rate := cfg.NetworkFaultRate(kind); rate > 0 {
+			if active.Network == nil {
+				active.Network = map[string]float64{}
+			}
+			active.Network[kind] = rate
+		}
+	}
+	for route := range cfg.Throttles {
+		active.Throttled = append(active.Throttled, route)
+	}
+	sort.Strings(active.Throttled)
+	return active
+}
+
+// UIHandler serves a self-contained status page polling /admin/stats, with
+// buttons for the degradation modes, the cache flush, removing the
+// injected faults and stopping the scenario
+func (s *Server) UIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(uiPage))
+}
+
+const uiPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Slow Server</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.failing { background: #fdd; }
+.on { background: #fdd; }
+button { margin: 0 4px 4px 0; }
+#error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>Slow Server</h1>
+<p>Last <span id="window">60</span>s, refreshed every 2s. <span id="error"></span></p>
+
+<h2>Routes</h2>
+<table id="routes"></table>
+
+<h2>Dependencies</h2>
+<table id="dependencies"></table>
+
+<h2>Faults</h2>
+<div id="degrade"></div>
+<p>
+<button onclick="post('degrade/reset')">Free leaked memory and goroutines</button>
+<button onclick="post('cache/flush')">Flush caches</button>
+<button onclick="post('faults', 'DELETE')">Remove injected faults</button>
+<button onclick="post('scenario', 'DELETE')">Stop scenario</button>
+</p>
+<table id="faults"></table>
+
+<script>
+var modes = ["memory", "goroutines", "cpu"];
+
+function cell(row, tag, text, cls) {
+	var c = document.createElement(tag);
+	c.textContent = text;
+	if (cls) c.className = cls;
+	row.appendChild(c);
+}
+
+function ms(v) { return v.toFixed(1) + "ms"; }
+
+function renderStats(id, stats) {
+	var table = document.getElementById(id);
+	table.textContent = "";
+	var head = table.insertRow();
+	["Name", "Req/s", "Errors", "p50", "p95", "p99"].forEach(function (h) { cell(head, "th", h); });
+	if (stats.length === 0) {
+		cell(table.insertRow(), "td", "Nothing in the window");
+		return;
+	}
+	stats.forEach(function (s) {
+		var row = table.insertRow();
+		if (s.error_rate > 0) row.className = "failing";
+		cell(row, "td", s.name);
+		cell(row, "td", s.rate.toFixed(2));
+		cell(row, "td", (s.error_rate * 100).toFixed(1) + "%");
+		cell(row, "td", ms(s.p50_ms));
+		cell(row, "td", ms(s.p95_ms));
+		cell(row, "td", ms(s.p99_ms));
+	});
+}
+
+function renderFaults(f) {
+	var degrade = document.getElementById("degrade");
+	degrade.textContent = "";
+	modes.forEach(function (mode) {
+		var b = document.createElement("button");
+		b.textContent = mode + (f.degrade[mode] ? ": on" : ": off");
+		b.className = f.degrade[mode] ? "on" : "";
+		b.onclick = function () { post("degrade?mode=" + mode + "&enabled=" + !f.degrade[mode]); };
+		degrade.appendChild(b);
+	});
+
+	var rows = [
+		["Error rate", (f.error_rate * 100).toFixed(1) + "%"],
+		["Leaked", f.degrade.leaked_bytes + " bytes, " + f.degrade.leaked_goroutines + " goroutines"],
+	];
+	Object.keys(f.network || {}).forEach(function (k) { rows.push(["Network " + k, (f.network[k] * 100).toFixed(1) + "%"]); });
+	(f.throttled || []).forEach(function (r) { rows.push(["Throttled", r]); });
+	Object.keys(f.downstreams || {}).forEach(function (k) { rows.push(["Downstream " + k, f.downstreams[k]]); });
+	if (f.proxy_upstream) rows.push(["Proxying to", f.proxy_upstream]);
+	function injected(label, i) {
+		var what = i.kind === "delay" ? "+" + i.delay_ms + "ms" : "fail" + (i.status ? " with " + i.status : "");
+		rows.push([label + " " + i.step, what + " at " + (i.rate * 100).toFixed(0) + "% until " + new Date(i.until).toLocaleTimeString()]);
+	}
+	(f.injected || []).forEach(function (i) { injected("Injected into", i); });
+	if (f.scenario) {
+		var sc = f.scenario;
+		rows.push(["Scenario " + sc.name, "phase " + sc.phase + " of " + sc.phases + (sc.phase_name ? " (" + sc.phase_name + ")" : "") +
+			" until " + new Date(sc.phase_until).toLocaleTimeString() + (sc.repeat ? ", repeating" : "")]);
+		(sc.faults || []).forEach(function (i) { injected("Scenario fault in", i); });
+	}
+
+	var table = document.getElementById("faults");
+	table.textContent = "";
+	rows.forEach(function (r) {
+		var row = table.insertRow();
+		cell(row, "td", r[0]);
+		cell(row, "td", r[1]);
+	});
+}
+
+function refresh() {
+	fetch("stats").then(function (resp) {
+		if (!resp.ok) throw new Error(resp.status + " " + resp.statusText);
+		return resp.json();
+	}).then(function (stats) {
+		document.getElementById("error").textContent = "";
+		document.getElementById("window").textContent = stats.window_seconds;
+		renderStats("routes", stats.routes);
+		renderStats("dependencies", stats.dependencies);
+		renderFaults(stats.faults);
+	}).catch(function (err) {
+		document.getElementById("error").textContent = "Refresh failed: " + err.message;
+	});
+}
+
+function post(path, method) {
+	fetch(path, {method: method || "POST"}).then(function (resp) {
+		if (!resp.ok) return resp.text().then(function (t) { throw new Error(t); });
+	}).catch(function (err) {
+		document.getElementById("error").textContent = path + " failed: " + err.message;
+	}).then(refresh);
+}
+
+refresh();
+setInterval(refresh, 2000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/charmbracelet/log"
+)
+
+// usersCacheKey prefixes the cache entries GetUsersHandler reads; writes invalidate them
+const usersCacheKey = "/api/users"
+
+// errorStatus maps an error to its HTTP status, defaulting to 500
+func errorStatus(err error) int {
+	var appErr *models.AppError
+	if errors.As(err, &appErr) && appErr.StatusCode != 0 {
+		return appErr.StatusCode
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func userID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		return 0, models.NewAppError("Invalid user id", http.StatusBadRequest)
+	}
+	return id, nil
+}
+
+func (s *Server) GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := s.clk.Now()
+	requestID := r.Header.Get("X-Request-ID")
+
+	log.Infof("[%s] Processing GET /api/users/{id} request", requestID)
+
+	id, err := userID(r)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	user, err := s.getUser(r.Context(), requestID, id, r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	log.Infof("[%s] GET /api/users/%d completed in %v", requestID, id, s.clk.Since(startTime))
+	writeJSON(w, http.StatusOK, user)
+}
+
+// getUser runs the simulated DB query of a user lookup, cached under cacheKey
+func (s *Server) getUser(ctx context.Context, requestID string, id int, cacheKey string) (models.User, error) {
+	_, err := s.cachedStep(ctx, "db", cacheKey, s.simulateDBQuery)
+	if err != nil {
+		log.Errorf("[%s] Error in DB query: %v", requestID, err)
+		return models.User{}, err
+	}
+	return s.db.GetUser(id)
+}
+
+func (s *Server) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := s.clk.Now()
+	requestID := r.Header.Get("X-Request-ID")
+
+	log.Infof("[%s] Processing POST /api/users request", requestID)
+
+	var user models.User
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		log.Errorf("[%s] Error parsing request body: %v", requestID, err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	_, err := s.simulateDBQuery(r.Context())
+	if err != nil {
+		log.Errorf("[%s] Error in DB query: %v", requestID, err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	user, err = s.db.CreateUser(user)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	s.invalidateCache("db", usersCacheKey)
+
+	log.Infof("[%s] POST /api/users created user %d in %v", requestID, user.ID, s.clk.Since(startTime))
+	w.Header().Set("Location", "/api/users/"+strconv.Itoa(user.ID))
+	writeJSON(w, http.StatusCreated, user)
+}
+
+func (s *Server) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := s.clk.Now()
+	requestID := r.Header.Get("X-Request-ID")
+
+	log.Infof("[%s] Processing PUT /api/users/{id} request", requestID)
+
+	id, err := userID(r)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	var user models.User
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		log.Errorf("[%s] Error parsing request body: %v", requestID, err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	_, err = s.simulateDBQuery(r.Context())
+	if err != nil {
+		log.Errorf("[%s] Error in DB query: %v", requestID, err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	user, err = s.db.UpdateUser(id, user)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	s.invalidateCache("db", usersCacheKey)
+
+	log.Infof("[%s] PUT /api/users/%d completed in %v", requestID, id, s.clk.Since(startTime))
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := s.clk.Now()
+	requestID := r.Header.Get("X-Request-ID")
+
+	log.Infof("[%s] Processing DELETE /api/users/{id} request", requestID)
+
+	id, err := userID(r)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	_, err = s.simulateDBQuery(r.Context())
+	if err != nil {
+		log.Errorf("[%s] Error in DB query: %v", requestID, err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	if err := s.db.DeleteUser(id); err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	s.invalidateCache("db", usersCacheKey)
+
+	log.Infof("[%s] DELETE /api/users/%d completed in %v", requestID, id, s.clk.Since(startTime))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/charmbracelet/log"
+	"github.com/gorilla/websocket"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	// The server is a test peer, any page may connect
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// wsArgs are the options of one /ws connection
+type wsArgs struct {
+	push       bool // Push data items instead of echoing
+	closeAfter int  // Close after this many sent messages, 0 never
+	closeCode  int
+}
+
+func (s *Server) parseWSArgs(r *http.Request) (wsArgs, error) {
+	args := wsArgs{closeCode: s.config(r.Context()).WSCloseCode}
+	params := r.URL.Query()
+
+	switch mode := params.Get("mode"); mode {
+	case "", "echo":
+	case "push":
+		args.push = true
+	default:
+		return args, models.NewAppError("mode must be echo or push", http.StatusBadRequest)
+	}
+
+	if v := params.Get("close_after"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return args, models.NewAppError("close_after must be a non-negative integer", http.StatusBadRequest)
+		}
+		args.closeAfter = n
+	}
+
+	if v := params.Get("close_code"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1000 || n > 4999 {
+			return args, models.NewAppError("close_code must be between 1000 and 4999", http.StatusBadRequest)
+		}
+		args.closeCode = n
+	}
+
+	return args, nil
+}
+
+// WebSocketHandler is a slow WebSocket peer. It echoes every message back,
+// or with ?mode=push sends a DataItem update every WSPushInterval. Either
+// way messages are delayed, lost and reordered at the configured rates, and
+// the connection is closed with WSCloseCode at WSCloseRate or after
+// ?close_after messages.
+func (s *Server) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get("X-Request-ID")
+
+	args, err := s.parseWSArgs(r)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	items := s.db.ListData()
+	if args.push && len(items) == 0 {
+		http.Error(w, "No data to push", http.StatusNotFound)
+		return
+	}
+
+	// Upgrade answers the client itself when it fails
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Errorf("[%s] WebSocket upgrade failed: %v", requestID, err)
+		return
+	}
+	defer conn.Close()
+
+	mode := "echo"
+	if args.push {
+		mode = "push"
+	}
+	s.metrics.WSConnections.WithLabelValues(mode).Inc()
+	s.metrics.WSActiveConnections.Inc()
+	defer s.metrics.WSActiveConnections.Dec()
+	log.Infof("[%s] WebSocket connected in %s mode", requestID, mode)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stop:
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(time.Second))
+			conn.Close()
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	p := &wsPeer{s: s, ctx: ctx, conn: conn, args: args, requestID: requestID}
+	if args.push {
+		s.pushMessages(p, items, cancel)
+	} else {
+		s.echoMessages(p)
+	}
+}
+
+func (s *Server) echoMessages(p *wsPeer) {
+	for {
+		kind, data, err := p.conn.ReadMessage()
+		if err != nil {
+			log.Infof("[%s] WebSocket closed after %d messages: %v", p.requestID, p.sent, err)
+			return
+		}
+		s.metrics.WSMessages.WithLabelValues("received").Inc()
+		if !p.deliver(wsMessage{kind: kind, data: data, at: s.clk.Now()}) {
+			return
+		}
+	}
+}
+
+func (s *Server) pushMessages(p *wsPeer, items []models.DataItem, cancel context.CancelFunc) {
+	// Reading answers pings and notices the client leaving
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := p.conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	interval := time.Duration(s.config(p.ctx).WSPushInterval) * time.Millisecond
+	for id := 1; ; id++ {
+		select {
+		case <-p.ctx.Done():
+			log.Infof("[%s] WebSocket closed after %d messages", p.requestID, p.sent)
+			return
+		case <-s.clk.After(interval):
+		}
+
+		item := items[(id-1)%len(items)]
+		item.Value = math.Round(s.randFloat64(p.ctx)*10000) / 100
+		data, _ := json.Marshal(item)
+		if !p.deliver(wsMessage{kind: websocket.TextMessage, data: data, at: s.clk.Now()}) {
+			return
+		}
+	}
+}
+
+// wsMessage is a message on its way to the client
+type wsMessage struct {
+	kind int // websocket.TextMessage or websocket.BinaryMessage
+	data []byte
+	at   time.Time // When it was received or produced
+}
+
+// wsPeer sends the messages of one connection
+type wsPeer struct {
+	s         *Server
+	ctx       context.Context
+	conn      *websocket.Conn
+	args      wsArgs
+	requestID string
+	held      *wsMessage // Sent right after the next message
+	sent      int
+}
+
+// deliver sends msg, or loses it, or holds it back to send after the next
+// one. It returns false once the connection is closed.
+func (p *wsPeer) deliver(msg wsMessage) bool {
+	s, cfg := p.s, p.s.config(p.ctx)
+	if cfg.WSLossRate > 0 && s.randFloat64(p.ctx) < cfg.WSLossRate {
+		s.metrics.WSMessages.WithLabelValues("lost").Inc()
+		return true
+	}
+	if p.held == nil && cfg.WSReorderRate > 0 && s.randFloat64(p.ctx) < cfg.WSReorderRate {
+		s.metrics.WSMessages.WithLabelValues("reordered").Inc()
+		p.held = &msg
+		return true
+	}
+
+	if !p.write(msg) {
+		return false
+	}
+	if held := p.held; held != nil {
+		p.held = nil
+		return p.write(*held)
+	}
+	return true
+}
+
+func (p *wsPeer) write(msg wsMessage) bool {
+	s, cfg := p.s, p.s.config(p.ctx)
+	s.sleep(p.ctx, s.jittered(p.ctx, cfg.WSDelay, cfg.WSJitter))
+	if p.ctx.Err() != nil {
+		return false
+	}
+	if err := p.conn.WriteMessage(msg.kind, msg.data); err != nil {
+		return false
+	}
+	p.sent++
+	s.metrics.WSMessages.WithLabelValues("sent").Inc()
+	s.metrics.WSMessageLatency.Observe(float64(s.clk.Since(msg.at).Milliseconds()))
+
+	if p.sent == p.args.closeAfter || cfg.WSCloseRate > 0 && s.randFloat64(p.ctx) < cfg.WSCloseRate {
+		p.close()
+		return false
+	}
+	return true
+}
+
+// close ends the connection with the chosen close code without waiting for
+// the client's answer. 1006 is never sent on the wire, so for it the
+// connection is dropped without a close frame.
+func (p *wsPeer) close() {
+	code := p.args.closeCode
+	log.Warnf("[%s] Closing the WebSocket with code %d after %d messages", p.requestID, code, p.sent)
+	p.s.metrics.WSCloses.WithLabelValues(strconv.Itoa(code)).Inc()
+
+	if code != websocket.CloseAbnormalClosure {
+		p.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(code, "closed by slow-server"), time.Now().Add(time.Second))
+	}
+	p.conn.Close()
+}
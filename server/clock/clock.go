@@ -0,0 +1,80 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for the simulation and the middleware, so
+// tests can swap in a Fake and never actually sleep
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is the wall clock
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Fake is a clock for tests. Sleep and After return immediately after moving
+// the clock forward, so simulated delays cost no real time while durations
+// measured against the clock stay exact. Concurrent sleepers all advance the
+// same clock, so it is meant for requests run one at a time.
+type Fake struct {
+	mu    sync.Mutex
+	now   time.Time
+	slept time.Duration
+}
+
+// NewFake returns a Fake clock starting at start
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *Fake) Sleep(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if d > 0 {
+		f.now = f.now.Add(d)
+		f.slept += d
+	}
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.Sleep(d)
+	ch := make(chan time.Time, 1)
+	ch <- f.Now()
+	return ch
+}
+
+// Advance moves the clock forward without counting it as sleep
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Slept returns the total time callers have slept on f
+func (f *Fake) Slept() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.slept
+}
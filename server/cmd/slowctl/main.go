@@ -0,0 +1,19 @@
+// Command slowctl controls running slow-server instances through their
+// admin API, e.g. every replica of a deployment at once:
+//
+//	slowctl -t dns+http://slow-server-pods:8080 fault fail 50% of db for 2m
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Unic-X/slow-server/slowctl"
+)
+
+func main() {
+	if err := slowctl.Run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -1,13 +1,22 @@
 package config
 
 import (
+	"fmt"
 	"github.com/charmbracelet/log"
+	"net"
+	"net/url"
 	"os"
+	"reflect"
+	"runtime"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	Port           int
+	AdminPort      int // pprof, runtime metrics and state dump; 0 disables the listener
+	GRPCPort       int // gRPC API; 0 disables the listener
 	LogLevel       string
 	SimulateErrors bool
 	MinDelay      int
@@ -17,11 +26,154 @@ type Config struct {
 	ProcessDelay  int
 	ErrorRate     float64 // Percentage of errors 0.00 = 0% error and 1.00 = 100% error
 	EnableMetrics bool
+
+	// Simulated cache placed in front of the dependencies listed in CacheSteps
+	CacheEnabled   bool
+	CacheSteps     []string // "db", "external"
+	CacheHitRatio  float64  // Steady-state hit ratio once the cache is warm
+	CacheHitDelay  int      // ms spent answering from the cache
+	CacheMissDelay int      // ms spent on the lookup before falling through
+	CacheTTL       int      // ms an entry lives before it is evicted
+	CacheWarmup    int      // ms for the hit ratio to ramp up after start or flush
+
+	// Seeded in-memory data set behind the simulated DB step
+	StoreSize int   // Number of generated users and data items
+	StoreSeed int64 // Seed for the deterministic record generator
+
+	// List endpoint pagination
+	DefaultPageSize int // Page size when the client sends no limit
+	MaxPageSize     int // Upper bound for the limit parameter
+	DBRowCost       int // µs of simulated DB time per scanned row
+
+	// Async /api/process jobs
+	JobWorkers      int      // Size of the worker pool
+	JobQueueSize    int      // Jobs waiting beyond this are rejected with 503
+	JobRetention    int      // ms a finished job stays queryable
+	CallbackTimeout int      // ms to wait for a completion webhook
+	CallbackHosts   []string // Hosts, optionally with a port, webhooks may be sent to; none disables them
+
+	// Per-item cost of /api/process
+	MaxItems         int     // Requests with more items are rejected
+	MaxProcessBytes  int     // Requests with a larger body or more item bytes are rejected
+	ItemDelay        int     // ms of fixed processing time per item
+	ItemByteCost     int     // µs of processing time per byte of an item
+	ItemMemoryFactor int     // Bytes held in memory per byte of an item while processing
+	ItemErrorRate    float64 // Probability that a single item fails
+
+	// Per-request X-Slow-* fault injection headers
+	FaultHeadersEnabled bool
+	FaultHeadersAllow   []string // Client IPs or CIDRs allowed to send the headers
+	FaultHeadersSecret  string   // Alternatively, clients presenting this X-Slow-Token are allowed
+
+	// Seed for every simulated delay and failure; the same seed and request
+	// order reproduce a run exactly
+	Seed int64
+
+	// Gradual degradation applied to every API request while a mode is on.
+	// Unlike the simulated delays these really use memory, goroutines and CPU.
+	DegradeMemory        bool
+	LeakBytes            int // Bytes retained per request
+	LeakMaxBytes         int // Leaking stops once this much is retained
+	DegradeGoroutines    bool
+	LeakGoroutines       int // Goroutines parked forever per request
+	LeakMaxGoroutines    int // Leaking stops once this many are parked
+	DegradeCPU           bool
+	CPUBurn              int // ms of busy CPU per request
+	CPUBurnMaxConcurrent int // Requests burning at once; the rest skip the burn
+
+	// Slow response bodies by route path, "*" for every other route
+	Throttles map[string]Throttle
+
+	// Probability per API request of each network-level failure
+	NetResetRate       float64
+	NetHangRate        float64
+	NetTruncateRate    float64
+	NetInvalidJSONRate float64
+	NetBadLengthRate   float64
+
+	// Server-Sent Events on /api/stream
+	StreamInterval       int     // ms between events
+	StreamJitter         int     // Up to this many ms added to or taken off every interval
+	StreamHeartbeat      int     // ms of silence before a heartbeat comment, 0 disables them
+	StreamDisconnectRate float64 // Probability per event that the connection is dropped
+
+	// WebSocket peer on /ws
+	WSDelay        int     // ms every message is held back
+	WSJitter       int     // Up to this many ms added to or taken off the delay
+	WSPushInterval int     // ms between messages in push mode
+	WSLossRate     float64 // Probability that a message is dropped
+	WSReorderRate  float64 // Probability that a message is sent after the next one
+	WSCloseRate    float64 // Probability per message that the connection is closed
+	WSCloseCode    int     // Close code sent when closing on purpose
+
+	// GraphQL on /graphql: "n+1" resolves relations one at a time,
+	// "dataloader" batches them per level of the query
+	GraphQLMode   string
+	GraphQLFields map[string]Resolver // By "Type.field", "*" for every other resolver
+
+	// Fan-out of /api/batch
+	BatchConcurrency int // Sub-requests of one batch running at once
+	BatchMaxSize     int // Batches with more sub-requests are rejected
+
+	// Real HTTP calls to other slow-server instances by step name ("db",
+	// "external", "process"), made in place of the step's simulated delay
+	Downstreams       map[string]string
+	DownstreamTimeout int // ms a downstream call may take before it fails with 504
+
+	// Reverse proxy mode: with an upstream every request but /metrics and
+	// /admin/ is forwarded there, slowed down and failed per the first
+	// matching rule, or per MinDelay, MaxDelay and ErrorRate without one
+	ProxyUpstream string
+	ProxyRules    []ProxyRule
+
+	// Service level objectives tracked over the requests they match
+	SLOs []SLO
+
+	// Most recent API requests kept for /admin/traffic, 0 records none
+	TrafficRecordSize int
 }
 
-func LoadConfig() *Config {
-	cfg := &Config{
+// SLO is an objective for the requests matching Method and Path: a request
+// is good when it does not fail with a 5xx and, with a Latency, finishes
+// within it
+type SLO struct {
+	Name      string
+	Method    string  // "" for every method
+	Path      string  // Exact path, prefix ending in "*", or "*" for every path
+	Objective float64 // Fraction of requests that must be good, e.g. 0.99
+	Latency   int     // ms; 0 makes it an availability objective
+}
+
+// ProxyRule is the fault injection applied to proxied requests it matches
+type ProxyRule struct {
+	Method      string // "" for every method
+	Path        string // Exact path, prefix ending in "*", or "*" for every path
+	MinDelay    int    // ms added before forwarding, at least
+	MaxDelay    int    // and at most
+	ErrorRate   float64
+	ErrorStatus int // Returned instead of forwarding when a request fails
+	Throttle    Throttle
+}
+
+// Resolver is the simulated cost of a GraphQL field resolver
+type Resolver struct {
+	Delay     int     // ms per call, half of it fixed and half random
+	ErrorRate float64 // Probability that a call fails
+}
+
+// Throttle slows down the writing of a response body
+type Throttle struct {
+	BytesPerSec int // 0 for unlimited
+	ChunkSize   int // Bytes written and flushed at a time, 0 passes writes through
+	FlushDelay  int // ms each flush is held back, like a buffering proxy
+}
+
+// Default returns the built-in configuration without reading the environment
+func Default() *Config {
+	return &Config{
 		Port:           8080,
+		AdminPort:      6060,
+		GRPCPort:       9090,
 		LogLevel:       "info",
 		SimulateErrors: true,
 		MinDelay:       500,  
@@ -31,7 +183,68 @@ func LoadConfig() *Config {
 		ProcessDelay:   500,  // 500ms for processing simulation
 		ErrorRate:      0.15, // 15% error rate
 		EnableMetrics:  true,
+
+		CacheEnabled:   true,
+		CacheSteps:     []string{"db"},
+		CacheHitRatio:  0.8,   // 80% hits once warm
+		CacheHitDelay:  5,     // 5ms for a cache hit
+		CacheMissDelay: 20,    // 20ms wasted on a miss
+		CacheTTL:       30000, // 30s entry lifetime
+		CacheWarmup:    10000, // 10s cold-start ramp
+
+		StoreSize: 100,
+		StoreSeed: 1,
+
+		DefaultPageSize: 50,
+		MaxPageSize:     500,
+		DBRowCost:       200, // 100 scanned rows add 20ms
+
+		JobWorkers:      4,
+		JobQueueSize:    100,
+		JobRetention:    600000, // 10m
+		CallbackTimeout: 2000,
+
+		MaxItems:         1000,
+		MaxProcessBytes:  256 << 10, // Up to 64MiB held at the default factor
+		ItemDelay:        20,
+		ItemByteCost:     10,
+		ItemMemoryFactor: 256,  // A 1KB item holds 256KB
+		ItemErrorRate:    0.05, // 5% of items fail
+
+		FaultHeadersEnabled: false,
+		FaultHeadersAllow:   []string{"127.0.0.1/32", "::1/128"},
+
+		LeakBytes:            1 << 20, // 1MiB per request
+		LeakMaxBytes:         512 << 20,
+		LeakGoroutines:       10,
+		LeakMaxGoroutines:    100000,
+		CPUBurn:              50,
+		CPUBurnMaxConcurrent: runtime.NumCPU(),
+
+		StreamInterval:  1000,
+		StreamJitter:    500,
+		StreamHeartbeat: 15000,
+
+		WSDelay:        100,
+		WSJitter:       50,
+		WSPushInterval: 1000,
+		WSCloseCode:    1011, // Internal error
+
+		GraphQLMode:   "n+1",
+		GraphQLFields: map[string]Resolver{"*": {Delay: 20}},
+
+		BatchConcurrency: 4,
+		BatchMaxSize:     100,
+
+		DownstreamTimeout: 10000,
+
+		TrafficRecordSize: 1000,
 	}
+}
+
+// LoadConfig returns the default configuration overridden by the environment
+func LoadConfig() *Config {
+	cfg := Default()
 
 	if port := os.Getenv("SERVER_PORT"); port != "" { //Hardcoded inside Dockerfile for now
 		if p, err := strconv.Atoi(port); err == nil {
@@ -41,6 +254,9 @@ func LoadConfig() *Config {
 		}
 	}
 
+	intEnv("ADMIN_PORT", &cfg.AdminPort)
+	intEnv("GRPC_PORT", &cfg.GRPCPort)
+
 	if logLevel := os.Getenv("LOG_LEVEL"); logLevel != "" {
 		cfg.LogLevel = logLevel
 	}
@@ -55,9 +271,625 @@ func LoadConfig() *Config {
 		}
 	}
 
+	if cacheEnabled := os.Getenv("CACHE_ENABLED"); cacheEnabled == "false" {
+		cfg.CacheEnabled = false
+	}
+
+	if steps := os.Getenv("CACHE_STEPS"); steps != "" {
+		cfg.CacheSteps = splitList(steps)
+	}
+
+	if ratio := os.Getenv("CACHE_HIT_RATIO"); ratio != "" {
+		if r, err := strconv.ParseFloat(ratio, 64); err == nil && r >= 0 && r <= 1 {
+			cfg.CacheHitRatio = r
+		}
+	}
+
+	intEnv("CACHE_HIT_DELAY", &cfg.CacheHitDelay)
+	intEnv("CACHE_MISS_DELAY", &cfg.CacheMissDelay)
+	intEnv("CACHE_TTL", &cfg.CacheTTL)
+	intEnv("CACHE_WARMUP", &cfg.CacheWarmup)
+	intEnv("STORE_SIZE", &cfg.StoreSize)
+	intEnv("PAGE_SIZE_DEFAULT", &cfg.DefaultPageSize)
+	intEnv("PAGE_SIZE_MAX", &cfg.MaxPageSize)
+	intEnv("DB_ROW_COST_US", &cfg.DBRowCost)
+	intEnv("JOB_WORKERS", &cfg.JobWorkers)
+	intEnv("JOB_QUEUE_SIZE", &cfg.JobQueueSize)
+	intEnv("JOB_RETENTION", &cfg.JobRetention)
+	intEnv("CALLBACK_TIMEOUT", &cfg.CallbackTimeout)
+	intEnv("MAX_ITEMS", &cfg.MaxItems)
+	intEnv("MAX_PROCESS_BYTES", &cfg.MaxProcessBytes)
+	intEnv("ITEM_DELAY", &cfg.ItemDelay)
+	intEnv("ITEM_BYTE_COST_US", &cfg.ItemByteCost)
+	intEnv("ITEM_MEMORY_FACTOR", &cfg.ItemMemoryFactor)
+
+	if itemErrRate := os.Getenv("ITEM_ERROR_RATE"); itemErrRate != "" {
+		if er, err := strconv.ParseFloat(itemErrRate, 64); err == nil && er >= 0 && er <= 1 {
+			cfg.ItemErrorRate = er
+		}
+	}
+
+	if seed := os.Getenv("STORE_SEED"); seed != "" {
+		if s, err := strconv.ParseInt(seed, 10, 64); err == nil {
+			cfg.StoreSeed = s
+		} else {
+			log.Printf("Invalid STORE_SEED: %s, using default: %d", seed, cfg.StoreSeed)
+		}
+	}
+
+	if faultHeaders := os.Getenv("FAULT_HEADERS_ENABLED"); faultHeaders == "true" {
+		cfg.FaultHeadersEnabled = true
+	}
+
+	if hosts := os.Getenv("JOB_CALLBACK_HOSTS"); hosts != "" {
+		cfg.CallbackHosts = splitList(hosts)
+	}
+
+	if allow := os.Getenv("FAULT_HEADERS_ALLOW"); allow != "" {
+		cfg.FaultHeadersAllow = splitList(allow)
+	}
+
+	if secret := os.Getenv("FAULT_HEADERS_SECRET"); secret != "" {
+		cfg.FaultHeadersSecret = secret
+	}
+
+	if degradeMemory := os.Getenv("DEGRADE_MEMORY"); degradeMemory == "true" {
+		cfg.DegradeMemory = true
+	}
+
+	if degradeGoroutines := os.Getenv("DEGRADE_GOROUTINES"); degradeGoroutines == "true" {
+		cfg.DegradeGoroutines = true
+	}
+
+	if degradeCPU := os.Getenv("DEGRADE_CPU"); degradeCPU == "true" {
+		cfg.DegradeCPU = true
+	}
+
+	intEnv("LEAK_BYTES", &cfg.LeakBytes)
+	intEnv("LEAK_MAX_BYTES", &cfg.LeakMaxBytes)
+	intEnv("LEAK_GOROUTINES", &cfg.LeakGoroutines)
+	intEnv("LEAK_MAX_GOROUTINES", &cfg.LeakMaxGoroutines)
+	intEnv("CPU_BURN_MS", &cfg.CPUBurn)
+	intEnv("CPU_BURN_MAX_CONCURRENT", &cfg.CPUBurnMaxConcurrent)
+
+	rateEnv("NET_RESET_RATE", &cfg.NetResetRate)
+	rateEnv("NET_HANG_RATE", &cfg.NetHangRate)
+	rateEnv("NET_TRUNCATE_RATE", &cfg.NetTruncateRate)
+	rateEnv("NET_INVALID_JSON_RATE", &cfg.NetInvalidJSONRate)
+	rateEnv("NET_BAD_LENGTH_RATE", &cfg.NetBadLengthRate)
+
+	intEnv("STREAM_INTERVAL", &cfg.StreamInterval)
+	intEnv("STREAM_JITTER", &cfg.StreamJitter)
+	intEnv("STREAM_HEARTBEAT", &cfg.StreamHeartbeat)
+	rateEnv("STREAM_DISCONNECT_RATE", &cfg.StreamDisconnectRate)
+
+	intEnv("WS_DELAY", &cfg.WSDelay)
+	intEnv("WS_JITTER", &cfg.WSJitter)
+	intEnv("WS_PUSH_INTERVAL", &cfg.WSPushInterval)
+	rateEnv("WS_LOSS_RATE", &cfg.WSLossRate)
+	rateEnv("WS_REORDER_RATE", &cfg.WSReorderRate)
+	rateEnv("WS_CLOSE_RATE", &cfg.WSCloseRate)
+	intEnv("WS_CLOSE_CODE", &cfg.WSCloseCode)
+
+	if mode := os.Getenv("GRAPHQL_MODE"); mode == "n+1" || mode == "dataloader" {
+		cfg.GraphQLMode = mode
+	} else if mode != "" {
+		log.Printf("Invalid GRAPHQL_MODE: %s, using default: %s", mode, cfg.GraphQLMode)
+	}
+
+	if fields := os.Getenv("GRAPHQL_FIELDS"); fields != "" {
+		if f, err := ParseResolvers(fields); err == nil {
+			cfg.GraphQLFields = f
+		} else {
+			log.Printf("Invalid GRAPHQL_FIELDS: %v, using defaults", err)
+		}
+	}
+
+	intEnv("BATCH_CONCURRENCY", &cfg.BatchConcurrency)
+	intEnv("BATCH_MAX_SIZE", &cfg.BatchMaxSize)
+
+	if downstreams := os.Getenv("DOWNSTREAM_URLS"); downstreams != "" {
+		if d, err := ParseDownstreams(downstreams); err == nil {
+			cfg.Downstreams = d
+		} else {
+			log.Printf("Invalid DOWNSTREAM_URLS: %v, simulating every step", err)
+		}
+	}
+
+	intEnv("DOWNSTREAM_TIMEOUT", &cfg.DownstreamTimeout)
+
+	if upstream := os.Getenv("PROXY_UPSTREAM"); upstream != "" {
+		if u, err := url.Parse(upstream); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			cfg.ProxyUpstream = upstream
+		} else {
+			log.Printf("Invalid PROXY_UPSTREAM: %s, serving the simulated API", upstream)
+		}
+	}
+
+	if rules := os.Getenv("PROXY_RULES"); rules != "" {
+		if r, err := ParseProxyRules(rules); err == nil {
+			cfg.ProxyRules = r
+		} else {
+			log.Printf("Invalid PROXY_RULES: %v, using the default delays and error rate", err)
+		}
+	}
+
+	if slos := os.Getenv("SLOS"); slos != "" {
+		if o, err := ParseSLOs(slos); err == nil {
+			cfg.SLOs = o
+		} else {
+			log.Printf("Invalid SLOS: %v, tracking no objectives", err)
+		}
+	}
+
+	intEnv("TRAFFIC_RECORD_SIZE", &cfg.TrafficRecordSize)
+	intEnv("MIN_DELAY", &cfg.MinDelay)
+	intEnv("MAX_DELAY", &cfg.MaxDelay)
+
+	if throttles := os.Getenv("THROTTLE_ROUTES"); throttles != "" {
+		if t, err := ParseThrottles(throttles); err == nil {
+			cfg.Throttles = t
+		} else {
+			log.Printf("Invalid THROTTLE_ROUTES: %v, not throttling", err)
+		}
+	}
+
+	if seed := os.Getenv("SEED"); seed != "" {
+		if s, err := strconv.ParseInt(seed, 10, 64); err == nil {
+			cfg.Seed = s
+		} else {
+			log.Printf("Invalid SEED: %s, using a random one", seed)
+		}
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+
 	return cfg
 }
 
+// StartupOnly are the fields only read when a server starts, which
+// changing at runtime would silently not apply
+var StartupOnly = map[string]bool{
+	"Port": true, "AdminPort": true, "GRPCPort": true, "LogLevel": true,
+	"StoreSize": true, "StoreSeed": true, "Seed": true,
+	"JobWorkers": true, "JobQueueSize": true,
+	"DegradeMemory": true, "DegradeGoroutines": true, "DegradeCPU": true, // Toggled on /admin/degrade
+	"ProxyUpstream": true, "SLOs": true, "TrafficRecordSize": true,
+}
+
+// Validate checks a config changed at runtime: every rate between 0 and 1
+// and no negative ms or sizes, down to the throttles, proxy rules and
+// resolvers, plus the fields with a narrower range of their own
+func (c *Config) Validate() error {
+	if err := validateNumbers("", reflect.ValueOf(*c)); err != nil {
+		return err
+	}
+	if c.GraphQLMode != "" && c.GraphQLMode != "n+1" && c.GraphQLMode != "dataloader" {
+		return fmt.Errorf("GraphQLMode must be n+1 or dataloader")
+	}
+	if c.WSCloseCode < 1000 || c.WSCloseCode > 4999 {
+		return fmt.Errorf("WSCloseCode must be between 1000 and 4999")
+	}
+	if c.MaxDelay < c.MinDelay {
+		return fmt.Errorf("MaxDelay must not be below MinDelay")
+	}
+	for _, entry := range c.FaultHeadersAllow {
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			return fmt.Errorf("FaultHeadersAllow: %q is neither an IP nor a CIDR", entry)
+		}
+	}
+	for step, target := range c.Downstreams {
+		if step != "db" && step != "external" && step != "process" {
+			return fmt.Errorf("Downstreams: step must be db, external or process, got %q", step)
+		}
+		if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Downstreams[%s]: %q is not an http(s) URL", step, target)
+		}
+	}
+	for i, r := range c.ProxyRules {
+		switch {
+		case r.Path != "*" && !strings.HasPrefix(r.Path, "/"):
+			return fmt.Errorf("ProxyRules[%d].Path must start with / or be *", i)
+		case r.MaxDelay < r.MinDelay:
+			return fmt.Errorf("ProxyRules[%d].MaxDelay must not be below MinDelay", i)
+		case r.ErrorStatus < 400 || r.ErrorStatus > 599:
+			return fmt.Errorf("ProxyRules[%d].ErrorStatus must be between 400 and 599", i)
+		}
+	}
+	return nil
+}
+
+// validateNumbers checks that every float64 in v is a rate between 0 and 1
+// and every int is non-negative, naming the offending one by its path
+func validateNumbers(path string, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name := v.Type().Field(i).Name
+			if path != "" {
+				name = path + "." + name
+			}
+			if err := validateNumbers(name, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for iter := v.MapRange(); iter.Next(); {
+			if err := validateNumbers(fmt.Sprintf("%s[%v]", path, iter.Key()), iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateNumbers(fmt.Sprintf("%s[%d]", path, i), v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Float64:
+		if v.Float() < 0 || v.Float() > 1 {
+			return fmt.Errorf("%s must be between 0 and 1", path)
+		}
+	case reflect.Int:
+		if v.Int() < 0 {
+			return fmt.Errorf("%s must not be negative", path)
+		}
+	}
+	return nil
+}
+
+// intEnv overrides dst with a non-negative integer from the environment
+func intEnv(name string, dst *int) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+		*dst = n
+	} else {
+		log.Printf("Invalid %s: %s, using default: %d", name, v, *dst)
+	}
+}
+
+// rateEnv overrides dst with a probability between 0 and 1 from the environment
+func rateEnv(name string, dst *float64) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	if r, err := strconv.ParseFloat(v, 64); err == nil && r >= 0 && r <= 1 {
+		*dst = r
+	} else {
+		log.Printf("Invalid %s: %s, using default: %v", name, v, *dst)
+	}
+}
+
+// NetworkFaultRate returns the configured probability of a faults.Network* kind
+func (c *Config) NetworkFaultRate(kind string) float64 {
+	switch kind {
+	case "reset":
+		return c.NetResetRate
+	case "hang":
+		return c.NetHangRate
+	case "truncate":
+		return c.NetTruncateRate
+	case "invalid_json":
+		return c.NetInvalidJSONRate
+	case "bad_length":
+		return c.NetBadLengthRate
+	}
+	return 0
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// ParseThrottles reads per-route throttles such as
+// "/api/data=bps:1024;chunk:256;flush:100,*=bps:65536"
+func ParseThrottles(s string) (map[string]Throttle, error) {
+	throttles := map[string]Throttle{}
+	for _, entry := range splitList(s) {
+		route, settings, ok := strings.Cut(entry, "=")
+		route = strings.TrimSpace(route)
+		if !ok || route == "" {
+			return nil, fmt.Errorf("expected route=settings, got %q", entry)
+		}
+
+		var t Throttle
+		for _, setting := range strings.Split(settings, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(setting), ":")
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%s: %s must be a non-negative integer", route, key)
+			}
+			switch key {
+			case "bps":
+				t.BytesPerSec = n
+			case "chunk":
+				t.ChunkSize = n
+			case "flush":
+				t.FlushDelay = n
+			default:
+				return nil, fmt.Errorf("%s: unknown setting %q", route, key)
+			}
+		}
+		throttles[route] = t
+	}
+	return throttles, nil
+}
+
+// ThrottleFor returns the throttle of the first of paths that has one, such
+// as a request's path and then its route pattern, falling back to "*"
+func (c *Config) ThrottleFor(paths ...string) (Throttle, bool) {
+	for _, path := range paths {
+		if t, ok := c.Throttles[path]; ok {
+			return t, true
+		}
+	}
+	t, ok := c.Throttles["*"]
+	return t, ok
+}
+
+// ParseDownstreams reads the downstream URL of each step, such as
+// "external=http://slow-b:8080/api/users,db=http://slow-c:8080/api/data?limit=5"
+func ParseDownstreams(s string) (map[string]string, error) {
+	downstreams := map[string]string{}
+	for _, entry := range splitList(s) {
+		step, target, ok := strings.Cut(entry, "=")
+		step = strings.TrimSpace(step)
+		if !ok || step == "" {
+			return nil, fmt.Errorf("expected step=url, got %q", entry)
+		}
+		if step != "db" && step != "external" && step != "process" {
+			return nil, fmt.Errorf("%s: step must be db, external or process", step)
+		}
+		u, err := url.Parse(strings.TrimSpace(target))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%s: %q is not an http(s) URL", step, target)
+		}
+		downstreams[step] = u.String()
+	}
+	return downstreams, nil
+}
+
+// ParseProxyRules reads proxy rules such as
+// "GET /api/orders*=delay:200-800;error:0.1;status:503;bps:10000,/health=delay:0"
+// in the order they are matched
+func ParseProxyRules(s string) ([]ProxyRule, error) {
+	var rules []ProxyRule
+	for _, entry := range splitList(s) {
+		match, settings, ok := strings.Cut(entry, "=")
+		match = strings.TrimSpace(match)
+		if !ok || match == "" {
+			return nil, fmt.Errorf("expected [method] path=settings, got %q", entry)
+		}
+
+		r := ProxyRule{Path: match, ErrorStatus: 503}
+		if method, path, ok := strings.Cut(match, " "); ok {
+			r.Method, r.Path = strings.ToUpper(method), strings.TrimSpace(path)
+		}
+		if r.Path != "*" && !strings.HasPrefix(r.Path, "/") {
+			return nil, fmt.Errorf("%s: path must start with / or be *", match)
+		}
+
+		for _, setting := range strings.Split(settings, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(setting), ":")
+			value = strings.TrimSpace(value)
+			if key == "error" {
+				rate, err := strconv.ParseFloat(value, 64)
+				if err != nil || rate < 0 || rate > 1 {
+					return nil, fmt.Errorf("%s: error must be between 0 and 1", match)
+				}
+				r.ErrorRate = rate
+				continue
+			}
+			if key == "delay" {
+				lo, hi, isRange := strings.Cut(value, "-")
+				if !isRange {
+					hi = lo
+				}
+				min, err1 := strconv.Atoi(lo)
+				max, err2 := strconv.Atoi(hi)
+				if err1 != nil || err2 != nil || min < 0 || max < min {
+					return nil, fmt.Errorf("%s: delay must be ms or a min-max range", match)
+				}
+				r.MinDelay, r.MaxDelay = min, max
+				continue
+			}
+
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%s: %s must be a non-negative integer", match, key)
+			}
+			switch key {
+			case "status":
+				if n < 400 || n > 599 {
+					return nil, fmt.Errorf("%s: status must be between 400 and 599", match)
+				}
+				r.ErrorStatus = n
+			case "bps":
+				r.Throttle.BytesPerSec = n
+			case "chunk":
+				r.Throttle.ChunkSize = n
+			case "flush":
+				r.Throttle.FlushDelay = n
+			default:
+				return nil, fmt.Errorf("%s: unknown setting %q", match, key)
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Matches reports whether the rule applies to a request
+func (r ProxyRule) Matches(method, path string) bool {
+	return matchRequest(r.Method, r.Path, method, path)
+}
+
+// String is the rule's match, e.g. "GET /api/orders*"
+func (r ProxyRule) String() string {
+	if r.Method == "" {
+		return r.Path
+	}
+	return r.Method + " " + r.Path
+}
+
+// ProxyRuleFor returns the first rule matching a proxied request, or a
+// "default" rule built from MinDelay, MaxDelay and the error settings
+func (c *Config) ProxyRuleFor(method, path string) ProxyRule {
+	for _, r := range c.ProxyRules {
+		if r.Matches(method, path) {
+			return r
+		}
+	}
+	r := ProxyRule{Path: "default", MinDelay: c.MinDelay, MaxDelay: c.MaxDelay, ErrorStatus: 503}
+	if c.SimulateErrors {
+		r.ErrorRate = c.ErrorRate
+	}
+	return r
+}
+
+// Proxies reports whether a request path is forwarded to ProxyUpstream
+func (c *Config) Proxies(path string) bool {
+	return c.ProxyUpstream != "" && path != "/metrics" && !strings.HasPrefix(path, "/admin/")
+}
+
+// ParseSLOs reads objectives such as
+// "data-latency=path:/api/data;latency:1000;objective:0.99,data-availability=path:/api/data;objective:0.995"
+func ParseSLOs(s string) ([]SLO, error) {
+	var slos []SLO
+	names := map[string]bool{}
+	for _, entry := range splitList(s) {
+		name, settings, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("expected name=settings, got %q", entry)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("%s: defined twice", name)
+		}
+		names[name] = true
+
+		slo := SLO{Name: name}
+		for _, setting := range strings.Split(settings, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(setting), ":")
+			value = strings.TrimSpace(value)
+			switch key {
+			case "path":
+				if value != "*" && !strings.HasPrefix(value, "/") {
+					return nil, fmt.Errorf("%s: path must start with / or be *", name)
+				}
+				slo.Path = value
+			case "method":
+				slo.Method = strings.ToUpper(value)
+			case "objective":
+				objective, err := strconv.ParseFloat(value, 64)
+				if err != nil || objective <= 0 || objective >= 1 {
+					return nil, fmt.Errorf("%s: objective must be between 0 and 1", name)
+				}
+				slo.Objective = objective
+			case "latency":
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("%s: latency must be a non-negative integer", name)
+				}
+				slo.Latency = n
+			default:
+				return nil, fmt.Errorf("%s: unknown setting %q", name, key)
+			}
+		}
+		if slo.Path == "" || slo.Objective == 0 {
+			return nil, fmt.Errorf("%s: path and objective are required", name)
+		}
+		slos = append(slos, slo)
+	}
+	return slos, nil
+}
+
+// Matches reports whether a request counts towards the objective
+func (s SLO) Matches(method, path string) bool {
+	return matchRequest(s.Method, s.Path, method, path)
+}
+
+// matchRequest matches a request against an optional method and a path
+// that is exact, a prefix ending in "*", or "*"
+func matchRequest(wantMethod, pattern, method, path string) bool {
+	if wantMethod != "" && wantMethod != method {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return pattern == path
+}
+
+// ParseResolvers reads per-field resolver costs such as
+// "Query.users=delay:50;error:0.1,DataItem.owner=delay:10,*=delay:20"
+func ParseResolvers(s string) (map[string]Resolver, error) {
+	resolvers := map[string]Resolver{}
+	for _, entry := range splitList(s) {
+		field, settings, ok := strings.Cut(entry, "=")
+		field = strings.TrimSpace(field)
+		if !ok || field == "" {
+			return nil, fmt.Errorf("expected field=settings, got %q", entry)
+		}
+
+		var r Resolver
+		for _, setting := range strings.Split(settings, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(setting), ":")
+			value = strings.TrimSpace(value)
+			switch key {
+			case "delay":
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("%s: delay must be a non-negative integer", field)
+				}
+				r.Delay = n
+			case "error":
+				rate, err := strconv.ParseFloat(value, 64)
+				if err != nil || rate < 0 || rate > 1 {
+					return nil, fmt.Errorf("%s: error must be between 0 and 1", field)
+				}
+				r.ErrorRate = rate
+			default:
+				return nil, fmt.Errorf("%s: unknown setting %q", field, key)
+			}
+		}
+		resolvers[field] = r
+	}
+	return resolvers, nil
+}
+
+// ResolverFor returns the simulated cost of a "Type.field" resolver
+func (c *Config) ResolverFor(field string) Resolver {
+	if r, ok := c.GraphQLFields[field]; ok {
+		return r
+	}
+	return c.GraphQLFields["*"]
+}
+
+// CachesStep reports whether the simulated cache fronts the given dependency
+func (c *Config) CachesStep(step string) bool {
+	if !c.CacheEnabled {
+		return false
+	}
+	for _, s := range c.CacheSteps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
 //Return port as a string
 func (c *Config) PortString() string {
 	return strconv.Itoa(c.Port)
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Current holds the config a running server reads. Changes at runtime
+// swap in a whole new Config instead of writing to the one in use, so a
+// request that loaded a snapshot keeps a consistent view until it ends.
+type Current struct {
+	p atomic.Pointer[Config]
+}
+
+// NewCurrent starts out with cfg. Writing to cfg's fields directly still
+// applies until the first Store, as long as no request is in flight.
+func NewCurrent(cfg *Config) *Current {
+	c := &Current{}
+	c.p.Store(cfg)
+	return c
+}
+
+// Load returns the config in use. Treat it as read-only.
+func (c *Current) Load() *Config {
+	return c.p.Load()
+}
+
+// Store makes cfg the config for every following request
+func (c *Current) Store(cfg *Config) {
+	c.p.Store(cfg)
+}
+
+// For returns the snapshot ctx carries, or the config in use for a
+// context without one
+func (c *Current) For(ctx context.Context) *Config {
+	if cfg, ok := FromContext(ctx); ok {
+		return cfg
+	}
+	return c.Load()
+}
+
+type contextKey struct{}
+
+// WithContext attaches the snapshot a request runs with
+func WithContext(ctx context.Context, cfg *Config) context.Context {
+	return context.WithValue(ctx, contextKey{}, cfg)
+}
+
+// FromContext returns the snapshot attached to ctx
+func FromContext(ctx context.Context) (*Config, bool) {
+	cfg, ok := ctx.Value(contextKey{}).(*Config)
+	return cfg, ok
+}
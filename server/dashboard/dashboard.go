@@ -0,0 +1,304 @@
+package dashboard
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The http_* metrics the per route RED panels are drawn from
+const (
+	requestsMetric = "http_requests_total"
+	errorsMetric   = "http_request_errors_total"
+	durationMetric = "http_request_duration_ms"
+)
+
+// quantiles drawn for every histogram
+var quantiles = []float64{0.5, 0.95, 0.99}
+
+// dependencies groups metrics into one row per simulated dependency by name
+// prefix. Metrics of no dependency get a row per first name segment.
+var dependencies = []struct {
+	Title    string
+	Prefixes []string
+}{
+	{"Database", []string{"db_"}},
+	{"External API", []string{"external_api_"}},
+	{"Processing", []string{"processing_", "process_"}},
+	{"Cache", []string{"cache_"}},
+	{"Downstream calls", []string{"downstream_"}},
+	{"Upstream proxy", []string{"proxy_"}},
+	{"Jobs", []string{"job_", "jobs_"}},
+}
+
+// Dashboard is the subset of the Grafana dashboard JSON model we generate
+type Dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh"`
+	Time          TimeRange  `json:"time"`
+	Templating    Templating `json:"templating"`
+	Panels        []Panel    `json:"panels"`
+}
+
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type Templating struct {
+	List []Variable `json:"list"`
+}
+
+type Variable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+// Panel is a row or a time series graph
+type Panel struct {
+	ID          int          `json:"id"`
+	Type        string       `json:"type"`
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	GridPos     GridPos      `json:"gridPos"`
+	Collapsed   *bool        `json:"collapsed,omitempty"`
+	Datasource  *Datasource  `json:"datasource,omitempty"`
+	FieldConfig *FieldConfig `json:"fieldConfig,omitempty"`
+	Targets     []Target     `json:"targets,omitempty"`
+}
+
+type GridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type Datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type FieldConfig struct {
+	Defaults FieldDefaults `json:"defaults"`
+}
+
+type FieldDefaults struct {
+	Unit string `json:"unit"`
+}
+
+// Target is one PromQL query of a panel
+type Target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+}
+
+// Panel sizes: three graphs per line of the 24 column grid
+const (
+	panelWidth  = 8
+	panelHeight = 8
+)
+
+// builder lays panels out row after row
+type builder struct {
+	panels []Panel
+	y, x   int
+}
+
+func (b *builder) row(title string) {
+	if b.x > 0 {
+		b.y += panelHeight
+		b.x = 0
+	}
+	collapsed := false
+	b.panels = append(b.panels, Panel{
+		ID:        len(b.panels) + 1,
+		Type:      "row",
+		Title:     title,
+		GridPos:   GridPos{H: 1, W: 24, X: 0, Y: b.y},
+		Collapsed: &collapsed,
+	})
+	b.y++
+}
+
+func (b *builder) graph(title, description, unit string, targets ...Target) {
+	if b.x+panelWidth > 24 {
+		b.y += panelHeight
+		b.x = 0
+	}
+	for i := range targets {
+		targets[i].RefID = string(rune('A' + i))
+	}
+	b.panels = append(b.panels, Panel{
+		ID:          len(b.panels) + 1,
+		Type:        "timeseries",
+		Title:       title,
+		Description: description,
+		GridPos:     GridPos{H: panelHeight, W: panelWidth, X: b.x, Y: b.y},
+		Datasource:  &Datasource{Type: "prometheus", UID: "${datasource}"},
+		FieldConfig: &FieldConfig{Defaults: FieldDefaults{Unit: unit}},
+		Targets:     targets,
+	})
+	b.x += panelWidth
+}
+
+// Generate builds the dashboard: a row of rate, errors and duration panels
+// per route, then a row per dependency with a panel per metric. Admin
+// routes are left out, and so are the http_* metrics the route rows show.
+func Generate(metrics []Metric, routes []Route) Dashboard {
+	b := &builder{}
+	for _, route := range APIRoutes(routes) {
+		sel := route.Selector()
+		b.row(route.Pattern)
+		b.graph("Rate", "Requests per second to "+route.Pattern, "reqps", Target{
+			Expr:         fmt.Sprintf("sum by (status) (rate(%s%s[$__rate_interval]))", requestsMetric, sel),
+			LegendFormat: "{{status}}",
+		})
+		b.graph("Errors", "Failed requests per second to "+route.Pattern, "reqps", Target{
+			Expr:         fmt.Sprintf("sum by (status) (rate(%s%s[$__rate_interval]))", errorsMetric, sel),
+			LegendFormat: "{{status}}",
+		})
+		var targets []Target
+		for _, q := range quantiles {
+			targets = append(targets, Target{
+				Expr: fmt.Sprintf("histogram_quantile(%s, sum by (le) (rate(%s_bucket%s[$__rate_interval])))",
+					formatQuantile(q), durationMetric, sel),
+				LegendFormat: quantileName(q),
+			})
+		}
+		b.graph("Duration", "Request duration quantiles of "+route.Pattern, "ms", targets...)
+	}
+
+	for _, group := range groupMetrics(metrics) {
+		b.row(group.Title)
+		for _, m := range group.Metrics {
+			b.graph(m.Name, m.Help, unit(m), metricTargets(m)...)
+		}
+	}
+
+	return Dashboard{
+		UID:           "slow-server",
+		Title:         "Slow Server",
+		Tags:          []string{"slow-server", "generated"},
+		SchemaVersion: 38,
+		Refresh:       "10s",
+		Time:          TimeRange{From: "now-1h", To: "now"},
+		Templating: Templating{List: []Variable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+		}},
+		Panels: b.panels,
+	}
+}
+
+// APIRoutes drops the admin routes, which are not worth a row each
+func APIRoutes(routes []Route) []Route {
+	var api []Route
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Path, "/admin/") {
+			api = append(api, route)
+		}
+	}
+	return api
+}
+
+// group is the metrics of one dashboard row
+type group struct {
+	Title   string
+	Metrics []Metric
+}
+
+// groupMetrics groups metrics by dependency, keeping registration order
+// within and across groups
+func groupMetrics(metrics []Metric) []group {
+	var groups []group
+	index := map[string]int{}
+	for _, m := range metrics {
+		switch m.Name {
+		case requestsMetric, errorsMetric, durationMetric:
+			continue
+		}
+		title := groupTitle(m.Name)
+		i, ok := index[title]
+		if !ok {
+			i = len(groups)
+			index[title] = i
+			groups = append(groups, group{Title: title})
+		}
+		groups[i].Metrics = append(groups[i].Metrics, m)
+	}
+	return groups
+}
+
+func groupTitle(name string) string {
+	for _, dep := range dependencies {
+		for _, prefix := range dep.Prefixes {
+			if strings.HasPrefix(name, prefix) {
+				return dep.Title
+			}
+		}
+	}
+	segment, _, _ := strings.Cut(name, "_")
+	return segment
+}
+
+// metricTargets draws counters as rates, histograms as quantiles and gauges
+// as they are, summed by every label
+func metricTargets(m Metric) []Target {
+	sum, legend := "sum", m.Name
+	if len(m.Labels) > 0 {
+		sum = "sum by (" + strings.Join(m.Labels, ", ") + ") "
+		var parts []string
+		for _, label := range m.Labels {
+			parts = append(parts, "{{"+label+"}}")
+		}
+		legend = strings.Join(parts, " ")
+	}
+
+	switch m.Type {
+	case Counter:
+		return []Target{{Expr: fmt.Sprintf("%s(rate(%s[$__rate_interval]))", sum, m.Name), LegendFormat: legend}}
+	case Histogram:
+		le := append([]string{"le"}, m.Labels...)
+		var targets []Target
+		for _, q := range quantiles {
+			targets = append(targets, Target{
+				Expr: fmt.Sprintf("histogram_quantile(%s, sum by (%s) (rate(%s_bucket[$__rate_interval])))",
+					formatQuantile(q), strings.Join(le, ", "), m.Name),
+				LegendFormat: quantileName(q) + " " + legend,
+			})
+		}
+		return targets
+	default:
+		return []Target{{Expr: fmt.Sprintf("%s(%s)", sum, m.Name), LegendFormat: legend}}
+	}
+}
+
+// unit picks the Grafana unit from the metric's name
+func unit(m Metric) string {
+	switch {
+	case m.Type == Counter:
+		return "ops"
+	case strings.HasSuffix(m.Name, "_ms"):
+		return "ms"
+	case strings.HasSuffix(m.Name, "_seconds"):
+		return "s"
+	case strings.HasSuffix(m.Name, "_bytes"):
+		return "bytes"
+	default:
+		return "short"
+	}
+}
+
+func formatQuantile(q float64) string {
+	return strings.TrimRight(fmt.Sprintf("%.3f", q), "0")
+}
+
+func quantileName(q float64) string {
+	return fmt.Sprintf("p%g", math.Round(q*1000)/10)
+}
@@ -0,0 +1,151 @@
+// Package dashboard generates the Grafana dashboard and the Prometheus
+// recording rules for the server from its registered metrics and its route
+// table, so neither drifts when a metric or a route is added.
+package dashboard
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metric types
+const (
+	Counter   = "counter"
+	Gauge     = "gauge"
+	Histogram = "histogram"
+)
+
+// Metric is one metric family as registered
+type Metric struct {
+	Name   string
+	Help   string
+	Type   string
+	Labels []string
+}
+
+// recorder is a prometheus.Registerer that only keeps what is registered
+type recorder struct {
+	collectors []prometheus.Collector
+}
+
+func (r *recorder) Register(c prometheus.Collector) error {
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+func (r *recorder) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		r.Register(c)
+	}
+}
+
+func (r *recorder) Unregister(prometheus.Collector) bool {
+	return false
+}
+
+// descPattern reads a *prometheus.Desc back from its String form, the only
+// way to get at its name and labels
+var descPattern = regexp.MustCompile(`^Desc\{fqName: ("(?:[^"\\]|\\.)*"), help: ("(?:[^"\\]|\\.)*"), constLabels: \{.*\}, variableLabels: \{(.*)\}\}$`)
+
+// Describe returns every metric register registers, in registration order,
+// e.g. for func(reg prometheus.Registerer) { metrics.New(reg) }
+func Describe(register func(prometheus.Registerer)) []Metric {
+	rec := &recorder{}
+	register(rec)
+
+	var described []Metric
+	for _, c := range rec.collectors {
+		ch := make(chan *prometheus.Desc)
+		go func() {
+			c.Describe(ch)
+			close(ch)
+		}()
+		for desc := range ch {
+			m, ok := parseDesc(desc.String())
+			if !ok {
+				continue
+			}
+			m.Type = metricType(c, m.Name)
+			described = append(described, m)
+		}
+	}
+	return described
+}
+
+func parseDesc(s string) (Metric, bool) {
+	match := descPattern.FindStringSubmatch(s)
+	if match == nil {
+		return Metric{}, false
+	}
+	name, err := strconv.Unquote(match[1])
+	if err != nil {
+		return Metric{}, false
+	}
+	help, _ := strconv.Unquote(match[2])
+
+	m := Metric{Name: name, Help: help}
+	if match[3] != "" {
+		for _, label := range strings.Split(match[3], ",") {
+			// Constrained labels print as c(name)
+			label = strings.TrimSuffix(strings.TrimPrefix(label, "c("), ")")
+			m.Labels = append(m.Labels, label)
+		}
+	}
+	return m, true
+}
+
+// metricType tells the type of a metric from its collector, or from the
+// usual name suffixes for collectors of several metrics such as the gRPC
+// server metrics. A Gauge is also a Counter, so it goes first.
+func metricType(c prometheus.Collector, name string) string {
+	switch c.(type) {
+	case prometheus.Histogram, *prometheus.HistogramVec:
+		return Histogram
+	case prometheus.Gauge, *prometheus.GaugeVec:
+		return Gauge
+	case prometheus.Counter, *prometheus.CounterVec:
+		return Counter
+	}
+	switch {
+	case strings.HasSuffix(name, "_total"):
+		return Counter
+	case strings.HasSuffix(name, "_seconds"):
+		return Histogram
+	default:
+		return Gauge
+	}
+}
+
+// Route is one pattern of the route table
+type Route struct {
+	Pattern string
+	Method  string // Empty when the pattern matches any method
+	Path    string
+}
+
+// Routes records the route table registered on it, as an api.Router
+type Routes []Route
+
+// HandleFunc records pattern
+func (r *Routes) HandleFunc(pattern string, _ func(http.ResponseWriter, *http.Request)) {
+	route := Route{Pattern: pattern, Path: pattern}
+	if method, path, ok := strings.Cut(pattern, " "); ok {
+		route.Method, route.Path = method, strings.TrimSpace(path)
+	}
+	*r = append(*r, route)
+}
+
+// Selector returns the label matchers of the http_* metrics for the
+// requests the route serves, which are labelled with its path as written
+// in the pattern
+func (r Route) Selector() string {
+	matchers := []string{"path=" + strconv.Quote(r.Path)}
+	if r.Method != "" {
+		matchers = append(matchers, "method="+strconv.Quote(r.Method))
+	}
+	return "{" + strings.Join(matchers, ",") + "}"
+}
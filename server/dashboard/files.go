@@ -0,0 +1,46 @@
+package dashboard
+
+import (
+	"encoding/json"
+
+	"github.com/Unic-X/slow-server/api"
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/metrics"
+	"github.com/Unic-X/slow-server/slo"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Files written for a server
+const (
+	DashboardFile = "slow-server-dashboard.json"
+	RulesFile     = "slow-server-rules.yaml"
+)
+
+// ForServer returns the metrics a server for cfg serves on /metrics and
+// its route table
+func ForServer(cfg *config.Config) ([]Metric, Routes) {
+	var m *metrics.Metrics
+	described := Describe(func(reg prometheus.Registerer) {
+		m = metrics.New(reg)
+		reg.MustRegister(slo.NewTracker(cfg.SLOs, m, clock.Real))
+	})
+
+	var routes Routes
+	api.NewServer(cfg, api.WithMetrics(m)).Routes(&routes)
+	return described, routes
+}
+
+// Files returns the contents of the dashboard and rule files for a server
+// for cfg, by file name
+func Files(cfg *config.Config) (map[string][]byte, error) {
+	described, routes := ForServer(cfg)
+	board, err := json.MarshalIndent(Generate(described, routes), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		DashboardFile: append(board, '\n'),
+		RulesFile:     []byte(Rules(described, routes)),
+	}, nil
+}
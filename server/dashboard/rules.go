@@ -0,0 +1,86 @@
+package dashboard
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ruleWindow is the rate window of every recording rule
+const ruleWindow = "5m"
+
+// Rules returns a Prometheus rule file recording the request rate, error
+// rate and duration quantiles of every route, labelled with its pattern,
+// and the rate or quantiles of every other counter and histogram by all of
+// its labels. Gauges are cheap enough to query as they are.
+func Rules(metrics []Metric, routes []Route) string {
+	var b strings.Builder
+	b.WriteString("groups:\n")
+	b.WriteString("  - name: slow-server-routes\n")
+	b.WriteString("    rules:\n")
+	for _, route := range APIRoutes(routes) {
+		sel := route.Selector()
+		writeRule(&b, "route:http_requests:rate"+ruleWindow,
+			fmt.Sprintf("sum(rate(%s%s[%s]))", requestsMetric, sel, ruleWindow), route.Pattern)
+		writeRule(&b, "route:http_request_errors:rate"+ruleWindow,
+			fmt.Sprintf("sum(rate(%s%s[%s]))", errorsMetric, sel, ruleWindow), route.Pattern)
+		for _, q := range quantiles {
+			writeRule(&b, "route:http_request_duration_ms:"+quantileName(q),
+				fmt.Sprintf("histogram_quantile(%s, sum by (le) (rate(%s_bucket%s[%s])))",
+					formatQuantile(q), durationMetric, sel, ruleWindow), route.Pattern)
+		}
+	}
+
+	for _, group := range groupMetrics(metrics) {
+		fmt.Fprintf(&b, "  - name: slow-server-%s\n", strings.ToLower(strings.ReplaceAll(group.Title, " ", "-")))
+		b.WriteString("    rules:\n")
+		for _, m := range group.Metrics {
+			for _, rule := range metricRules(m) {
+				writeRule(&b, rule[0], rule[1], "")
+			}
+		}
+	}
+	return b.String()
+}
+
+// metricRules returns the record name and expression of the rules of m,
+// named level:metric:operation after the labels kept
+func metricRules(m Metric) [][2]string {
+	level, by := "job", "job"
+	if len(m.Labels) > 0 {
+		level, by = strings.Join(m.Labels, "_"), strings.Join(m.Labels, ", ")
+	}
+
+	switch m.Type {
+	case Counter:
+		return [][2]string{{
+			fmt.Sprintf("%s:%s:rate%s", level, strings.TrimSuffix(m.Name, "_total"), ruleWindow),
+			fmt.Sprintf("sum by (%s) (rate(%s[%s]))", by, m.Name, ruleWindow),
+		}}
+	case Histogram:
+		var rules [][2]string
+		for _, q := range quantiles {
+			rules = append(rules, [2]string{
+				fmt.Sprintf("%s:%s:%s", level, m.Name, quantileName(q)),
+				fmt.Sprintf("histogram_quantile(%s, sum by (le, %s) (rate(%s_bucket[%s])))",
+					formatQuantile(q), by, m.Name, ruleWindow),
+			})
+		}
+		return rules
+	default:
+		return nil
+	}
+}
+
+func writeRule(b *strings.Builder, record, expr, route string) {
+	fmt.Fprintf(b, "      - record: %s\n", record)
+	fmt.Fprintf(b, "        expr: %s\n", quoteYAML(expr))
+	if route != "" {
+		b.WriteString("        labels:\n")
+		fmt.Fprintf(b, "          route: %s\n", quoteYAML(route))
+	}
+}
+
+// quoteYAML single quotes s, which is a valid YAML scalar whatever it holds
+func quoteYAML(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,199 @@
+package faults
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers a client can send to force the behaviour of a single request
+const (
+	DelayHeader   = "X-Slow-Delay"   // db=2s,external=500ms
+	FailHeader    = "X-Slow-Fail"    // external=503,db
+	SeedHeader    = "X-Slow-Seed"    // 42
+	NetworkHeader = "X-Slow-Network" // reset
+	TokenHeader   = "X-Slow-Token"   // Shared secret authorizing the headers above
+)
+
+// Network-level failures, where the client gets no well-formed HTTP response
+const (
+	NetworkReset       = "reset"        // Hijack the connection and reset it
+	NetworkHang        = "hang"         // Never answer, until the client gives up
+	NetworkTruncate    = "truncate"     // Send headers and part of the body, then close
+	NetworkInvalidJSON = "invalid_json" // Send a complete response whose JSON is cut short
+	NetworkBadLength   = "bad_length"   // Announce a longer Content-Length than is sent
+)
+
+// NetworkFaults lists the network failures in the order their rates are rolled
+var NetworkFaults = []string{NetworkReset, NetworkHang, NetworkTruncate, NetworkInvalidJSON, NetworkBadLength}
+
+// Steps that can be overridden
+var steps = map[string]bool{"db": true, "process": true, "external": true, "cache": true, "upstream": true}
+
+// CanDelay reports whether step takes a time that can be forced
+func CanDelay(step string) bool {
+	return steps[step]
+}
+
+// CanFail reports whether step can be failed; the cache only slows down
+func CanFail(step string) bool {
+	return steps[step] && step != "cache"
+}
+
+// Overrides forces the behaviour of simulated steps for a single request,
+// independent of the global config
+type Overrides struct {
+	Delays map[string]time.Duration // step -> exact delay
+	Fail   map[string]int           // step -> status to fail with, 0 for the step's default
+	Seed   *int64                   // Replaces the request's derived random seed
+
+	Network string // One of NetworkFaults to inflict on the response
+
+	RequestID string // For logging which request an override was used on
+}
+
+type contextKey struct{}
+
+// WithOverrides attaches o to ctx for the simulated steps to find
+func WithOverrides(ctx context.Context, o *Overrides) context.Context {
+	return context.WithValue(ctx, contextKey{}, o)
+}
+
+// FromContext returns the overrides attached to ctx, or nil
+func FromContext(ctx context.Context) *Overrides {
+	o, _ := ctx.Value(contextKey{}).(*Overrides)
+	return o
+}
+
+type argFailuresKey struct{}
+
+// WithArgFailures attaches the step failures a request asked for in its
+// body. They are kept apart from the header overrides, so that only the
+// headers count as overrides.
+func WithArgFailures(ctx context.Context, fail map[string]int) context.Context {
+	if len(fail) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, argFailuresKey{}, fail)
+}
+
+// ArgFailure reports whether the request's body asked for step to fail and
+// with which status
+func ArgFailure(ctx context.Context, step string) (int, bool) {
+	fail, _ := ctx.Value(argFailuresKey{}).(map[string]int)
+	status, ok := fail[step]
+	return status, ok
+}
+
+// Delay reports whether step has a forced delay
+func (o *Overrides) Delay(step string) (time.Duration, bool) {
+	if o == nil {
+		return 0, false
+	}
+	d, ok := o.Delays[step]
+	return d, ok
+}
+
+// Failure reports whether step must fail and with which status
+func (o *Overrides) Failure(step string) (int, bool) {
+	if o == nil {
+		return 0, false
+	}
+	status, ok := o.Fail[step]
+	return status, ok
+}
+
+// Empty reports whether o forces nothing
+func (o *Overrides) Empty() bool {
+	return o == nil || (len(o.Delays) == 0 && len(o.Fail) == 0 && o.Seed == nil && o.Network == "")
+}
+
+// HasHeaders reports whether the request carries any override header
+func HasHeaders(h http.Header) bool {
+	return h.Get(DelayHeader) != "" || h.Get(FailHeader) != "" || h.Get(SeedHeader) != "" || h.Get(NetworkHeader) != ""
+}
+
+// ParseHeaders reads the override headers of a request
+func ParseHeaders(h http.Header) (*Overrides, error) {
+	o := &Overrides{
+		Delays: map[string]time.Duration{},
+		Fail:   map[string]int{},
+	}
+
+	for step, value := range pairs(h.Get(DelayHeader)) {
+		if !CanDelay(step) {
+			return nil, fmt.Errorf("%s: unknown step %q", DelayHeader, step)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("%s: invalid delay %q for %s", DelayHeader, value, step)
+		}
+		o.Delays[step] = d
+	}
+
+	for step, value := range pairs(h.Get(FailHeader)) {
+		if !CanFail(step) {
+			return nil, fmt.Errorf("%s: unknown step %q", FailHeader, step)
+		}
+		status := 0
+		if value != "" {
+			s, err := strconv.Atoi(value)
+			if err != nil || s < 400 || s > 599 {
+				return nil, fmt.Errorf("%s: status for %s must be between 400 and 599", FailHeader, step)
+			}
+			status = s
+		}
+		o.Fail[step] = status
+	}
+
+	if seed := h.Get(SeedHeader); seed != "" {
+		s, err := strconv.ParseInt(seed, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid seed %q", SeedHeader, seed)
+		}
+		o.Seed = &s
+	}
+
+	if network := h.Get(NetworkHeader); network != "" {
+		if !isNetworkFault(network) {
+			return nil, fmt.Errorf("%s: unknown network fault %q", NetworkHeader, network)
+		}
+		o.Network = network
+	}
+
+	return o, nil
+}
+
+// NetworkFault returns the network failure forced on the request, if any
+func (o *Overrides) NetworkFault() (string, bool) {
+	if o == nil || o.Network == "" {
+		return "", false
+	}
+	return o.Network, true
+}
+
+func isNetworkFault(kind string) bool {
+	for _, k := range NetworkFaults {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// pairs splits "a=1,b=2,c" into {a: 1, b: 2, c: ""}
+func pairs(header string) map[string]string {
+	out := map[string]string{}
+	for _, entry := range strings.Split(header, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(entry, "=")
+		out[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return out
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/dashboard"
+	"github.com/charmbracelet/log"
+)
+
+//go:generate go run . generate -out ../k8s/generated
+
+// generate writes the Grafana dashboard and the recording rules of the
+// registered metrics and the route table to the -out directory
+func generate(args []string) error {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	out := flags.String("out", ".", "directory to write "+dashboard.DashboardFile+" and "+dashboard.RulesFile+" to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	files, err := dashboard.Files(config.Default())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(*out, name), content, 0o644); err != nil {
+			return err
+		}
+		log.Infof("Wrote %s", filepath.Join(*out, name))
+	}
+	return nil
+}
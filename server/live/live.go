@@ -0,0 +1,157 @@
+// Package live keeps request rates, error rates and latency percentiles
+// over a short sliding window in process, so the server can show what it
+// is doing without Prometheus or Grafana around.
+package live
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/models"
+)
+
+// Window is how far back the statistics reach
+const Window = time.Minute
+
+// slotSize is the resolution of the sliding window
+const slotSize = time.Second
+
+// bounds are the upper bounds in ms of the latency buckets percentiles are
+// interpolated from; slower observations land in an overflow bucket
+var bounds = []float64{1, 2, 5, 10, 25, 50, 75, 100, 150, 200, 300, 500, 750, 1000, 1500, 2000, 3000, 5000, 10000, 30000}
+
+// slot counts the observations of one second
+type slot struct {
+	second  int64
+	total   int64
+	errors  int64
+	buckets []int64
+}
+
+// series is a ring of per-second slots spanning the window
+type series struct {
+	slots []slot
+}
+
+func newSeries() *series {
+	s := &series{slots: make([]slot, Window/slotSize)}
+	for i := range s.slots {
+		s.slots[i].buckets = make([]int64, len(bounds)+1)
+	}
+	return s
+}
+
+// Stats tracks every route and dependency seen so far
+type Stats struct {
+	mu           sync.Mutex
+	routes       map[string]*series
+	dependencies map[string]*series
+	clk          clock.Clock
+}
+
+// New returns empty statistics on clk
+func New(clk clock.Clock) *Stats {
+	return &Stats{
+		routes:       map[string]*series{},
+		dependencies: map[string]*series{},
+		clk:          clk,
+	}
+}
+
+// RecordRoute counts a finished request to route; 4xx and 5xx are errors,
+// as in http_request_errors_total
+func (s *Stats) RecordRoute(route string, status int, d time.Duration) {
+	s.record(s.routes, route, status >= 400, d)
+}
+
+// RecordDependency counts a call to a dependency
+func (s *Stats) RecordDependency(name string, failed bool, d time.Duration) {
+	s.record(s.dependencies, name, failed, d)
+}
+
+func (s *Stats) record(all map[string]*series, name string, failed bool, d time.Duration) {
+	second := s.clk.Now().Unix()
+	ms := float64(d) / float64(time.Millisecond)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ser, ok := all[name]
+	if !ok {
+		ser = newSeries()
+		all[name] = ser
+	}
+	sl := &ser.slots[second%int64(len(ser.slots))]
+	if sl.second != second {
+		sl.second, sl.total, sl.errors = second, 0, 0
+		clear(sl.buckets)
+	}
+	sl.total++
+	if failed {
+		sl.errors++
+	}
+	sl.buckets[sort.SearchFloat64s(bounds, ms)]++
+}
+
+// Snapshot reports every route and dependency over the window, by name.
+// Routes and dependencies idle for the whole window are left out.
+func (s *Stats) Snapshot() (routes, dependencies []models.LiveStat) {
+	second := s.clk.Now().Unix()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return snapshot(s.routes, second), snapshot(s.dependencies, second)
+}
+
+func snapshot(all map[string]*series, second int64) []models.LiveStat {
+	stats := []models.LiveStat{}
+	for name, ser := range all {
+		stat, buckets := models.LiveStat{Name: name}, make([]int64, len(bounds)+1)
+		since := second - int64(len(ser.slots))
+		for _, sl := range ser.slots {
+			if sl.second <= since || sl.second > second {
+				continue
+			}
+			stat.Requests += sl.total
+			stat.Errors += sl.errors
+			for i, n := range sl.buckets {
+				buckets[i] += n
+			}
+		}
+		if stat.Requests == 0 {
+			continue
+		}
+		stat.Rate = float64(stat.Requests) / Window.Seconds()
+		stat.ErrorRate = float64(stat.Errors) / float64(stat.Requests)
+		stat.P50Ms = quantile(0.5, buckets, stat.Requests)
+		stat.P95Ms = quantile(0.95, buckets, stat.Requests)
+		stat.P99Ms = quantile(0.99, buckets, stat.Requests)
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// quantile interpolates the q-quantile within its bucket, the way
+// Prometheus' histogram_quantile does. The overflow bucket reports the
+// highest bound.
+func quantile(q float64, buckets []int64, total int64) float64 {
+	rank := q * float64(total)
+	var seen int64
+	for i, n := range buckets {
+		if float64(seen+n) < rank || n == 0 {
+			seen += n
+			continue
+		}
+		if i == len(bounds) {
+			return bounds[len(bounds)-1]
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = bounds[i-1]
+		}
+		return lower + (bounds[i]-lower)*(rank-float64(seen))/float64(n)
+	}
+	return bounds[len(bounds)-1]
+}
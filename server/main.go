@@ -1,35 +1,53 @@
 package main
 
 import (
-	"net/http"
+	"context"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
-	"github.com/Unic-X/slow-server/api"
 	"github.com/Unic-X/slow-server/config"
-	"github.com/Unic-X/slow-server/middleware"
+	"github.com/Unic-X/slow-server/slowserver"
 	"github.com/charmbracelet/log"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		if err := generate(os.Args[2:]); err != nil {
+			log.Fatal("Generate failed", "err", err)
+		}
+		return
+	}
+
 	// Load configuration
 	cfg := config.LoadConfig()
+	log.Infof("Using seed %d", cfg.Seed)
 
-	// Set up router and middleware
-	router := http.NewServeMux()
-	
-	// Register API handlers
-	router.HandleFunc("/api/data", api.GetDataHandler)
-	router.HandleFunc("/api/users", api.GetUsersHandler)
-	router.HandleFunc("/api/process", api.ProcessDataHandler)
-
-    router.Handle("/metrics", promhttp.Handler())
-	wrappedRouter := middleware.ApplyMetricsMiddleware(router)
-	wrappedRouter = middleware.ApplyLoggingMiddleware(wrappedRouter)
+	// Set up router and middleware, with diagnostics and gRPC on their own ports
+	opts := []slowserver.Option{slowserver.WithConfig(cfg)}
+	if cfg.AdminPort > 0 {
+		opts = append(opts, slowserver.WithAdminAddr(":"+strconv.Itoa(cfg.AdminPort)))
+	}
+	if cfg.GRPCPort > 0 {
+		opts = append(opts, slowserver.WithGRPCAddr(":"+strconv.Itoa(cfg.GRPCPort)))
+	}
+	server := slowserver.New(opts...)
 
 	// Start server
-	log.Infof("Starting server on port %v",cfg.Port)
-	err := http.ListenAndServe(":"+cfg.PortString(), wrappedRouter)
-	if err != nil {
-		log.Fatal("Server failed to start: %v", err)
+	if err := server.Start(); err != nil {
+		log.Fatal("Server failed to start", "err", err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	log.Infof("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Errorf("Shutdown failed: %v", err)
 	}
 }
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	grpcprom "github.com/grpc-ecosystem/go-grpc-middleware/providers/prometheus"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -9,88 +10,536 @@ import (
 // that will be shown inside Grafana dashboard
 // Main crux of all logs and monitoring should happen here
 
-var (
-	RequestsTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
-		},
-		[]string{"path", "method", "status"},
-	)
-
-	RequestDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_request_duration_ms",
-			Help:    "HTTP request duration in milliseconds",
-			Buckets: []float64{50, 100, 200, 300, 500, 800, 1000, 1500, 2000, 3000, 5000},
-		},
-		[]string{"path", "method"},
-	)
-
-	RequestErrors = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_request_errors_total",
-			Help: "Total number of HTTP request errors",
-		},
-		[]string{"path", "method", "status"},
-	)
-
-	DBQueriesTotal = promauto.NewCounter(
-		prometheus.CounterOpts{
-			Name: "db_queries_total",
-			Help: "Total number of database queries",
-		},
-	)
-
-	DBQueryDuration = promauto.NewHistogram(
-		prometheus.HistogramOpts{
-			Name:    "db_query_duration_ms",
-			Help:    "Database query duration in milliseconds",
-			Buckets: []float64{50, 100, 200, 300, 500, 800, 1000, 1500},
-		},
-	)
-
-	DBQueryErrors = promauto.NewCounter(
-		prometheus.CounterOpts{
-			Name: "db_query_errors_total",
-			Help: "Total number of database query errors",
-		},
-	)
-
-	ExternalAPICallsTotal = promauto.NewCounter(
-		prometheus.CounterOpts{
-			Name: "external_api_calls_total",
-			Help: "Total number of external API calls",
-		},
-	)
-
-	ExternalAPICallDuration = promauto.NewHistogram(
-		prometheus.HistogramOpts{
-			Name:    "external_api_call_duration_ms",
-			Help:    "External API call duration in milliseconds",
-			Buckets: []float64{100, 300, 500, 800, 1000, 1500, 2000, 3000},
-		},
-	)
-
-	ExternalAPICallErrors = promauto.NewCounter(
-		prometheus.CounterOpts{
-			Name: "external_api_call_errors_total",
-			Help: "Total number of external API call errors",
-		},
-	)
-
-	ProcessingDuration = promauto.NewHistogram(
-		prometheus.HistogramOpts{
-			Name:    "processing_duration_ms",
-			Help:    "Processing duration in milliseconds",
-			Buckets: []float64{50, 100, 200, 300, 500, 800, 1000},
-		},
-	)
-
-	ProcessingErrors = promauto.NewCounter(
-		prometheus.CounterOpts{
-			Name: "processing_errors_total",
-			Help: "Total number of processing errors",
-		},
-	)
-)
+// Metrics holds every collector of one server. Building it against its own
+// registry keeps the counters of several servers, or of consecutive tests,
+// apart.
+type Metrics struct {
+	RequestsTotal           *prometheus.CounterVec
+	RequestDuration         *prometheus.HistogramVec
+	RequestErrors           *prometheus.CounterVec
+	DBQueriesTotal          prometheus.Counter
+	DBQueryDuration         prometheus.Histogram
+	DBQueryErrors           prometheus.Counter
+	ExternalAPICallsTotal   prometheus.Counter
+	ExternalAPICallDuration prometheus.Histogram
+	ExternalAPICallErrors   prometheus.Counter
+	ProcessingDuration      prometheus.Histogram
+	ProcessingErrors        prometheus.Counter
+	CacheHits               *prometheus.CounterVec
+	CacheMisses             *prometheus.CounterVec
+	CacheEvictions          *prometheus.CounterVec
+	CacheInflightMisses     *prometheus.GaugeVec
+	DBRowsScanned           *prometheus.HistogramVec
+	PageSize                *prometheus.HistogramVec
+	JobQueueDepth           prometheus.Gauge
+	JobsInProgress          prometheus.Gauge
+	JobsTotal               *prometheus.CounterVec
+	JobQueueWait            prometheus.Histogram
+	JobDuration             prometheus.Histogram
+	JobCallbacks            *prometheus.CounterVec
+	ProcessItems            prometheus.Histogram
+	ProcessItemDuration     prometheus.Histogram
+	ProcessItemErrors       prometheus.Counter
+	ProcessMemoryBytes      prometheus.Histogram
+	FaultOverrides          *prometheus.CounterVec
+	FaultOverridesRejected  *prometheus.CounterVec
+	LeakedBytes             prometheus.Gauge
+	LeakedGoroutines        prometheus.Gauge
+	CPUBurnMs               prometheus.Counter
+	ResponseTTFB            *prometheus.HistogramVec
+	ResponseTransfer        *prometheus.HistogramVec
+	NetworkFaults           *prometheus.CounterVec
+	ActiveStreams           prometheus.Gauge
+	StreamEvents            prometheus.Counter
+	StreamEventLag          prometheus.Histogram
+	StreamDisconnects       prometheus.Counter
+	WSActiveConnections     prometheus.Gauge
+	WSConnections           *prometheus.CounterVec
+	WSMessages              *prometheus.CounterVec
+	WSMessageLatency        prometheus.Histogram
+	WSCloses                *prometheus.CounterVec
+	GraphQLResolverDuration *prometheus.HistogramVec
+	GraphQLResolverErrors   *prometheus.CounterVec
+	GraphQLBatchSize        *prometheus.HistogramVec
+	BatchFanoutWidth        prometheus.Histogram
+	BatchItemDuration       *prometheus.HistogramVec
+	BatchDuration           *prometheus.HistogramVec
+	DownstreamCalls         *prometheus.CounterVec
+	DownstreamDuration      *prometheus.HistogramVec
+	ProxyRequests           *prometheus.CounterVec
+	ProxyInjectedDelay      *prometheus.HistogramVec
+	ProxyUpstreamDuration   *prometheus.HistogramVec
+	SLOEvents               *prometheus.CounterVec
+	SLOGoodEvents           *prometheus.CounterVec
+	GRPC                    *grpcprom.ServerMetrics
+}
+
+// New creates the metrics and registers them on reg. A nil reg leaves them
+// unregistered, which is enough for handlers that are never scraped.
+func New(reg prometheus.Registerer) *Metrics {
+	f := promauto.With(reg)
+
+	m := &Metrics{
+		RequestsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_requests_total",
+				Help: "Total number of HTTP requests",
+			},
+			[]string{"path", "method", "status"},
+		),
+
+		RequestDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_request_duration_ms",
+				Help:    "HTTP request duration in milliseconds",
+				Buckets: []float64{50, 100, 200, 300, 500, 800, 1000, 1500, 2000, 3000, 5000},
+			},
+			[]string{"path", "method"},
+		),
+
+		RequestErrors: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_request_errors_total",
+				Help: "Total number of HTTP request errors",
+			},
+			[]string{"path", "method", "status"},
+		),
+
+		DBQueriesTotal: f.NewCounter(
+			prometheus.CounterOpts{
+				Name: "db_queries_total",
+				Help: "Total number of database queries",
+			},
+		),
+
+		DBQueryDuration: f.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "db_query_duration_ms",
+				Help:    "Database query duration in milliseconds",
+				Buckets: []float64{50, 100, 200, 300, 500, 800, 1000, 1500},
+			},
+		),
+
+		DBQueryErrors: f.NewCounter(
+			prometheus.CounterOpts{
+				Name: "db_query_errors_total",
+				Help: "Total number of database query errors",
+			},
+		),
+
+		ExternalAPICallsTotal: f.NewCounter(
+			prometheus.CounterOpts{
+				Name: "external_api_calls_total",
+				Help: "Total number of external API calls",
+			},
+		),
+
+		ExternalAPICallDuration: f.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "external_api_call_duration_ms",
+				Help:    "External API call duration in milliseconds",
+				Buckets: []float64{100, 300, 500, 800, 1000, 1500, 2000, 3000},
+			},
+		),
+
+		ExternalAPICallErrors: f.NewCounter(
+			prometheus.CounterOpts{
+				Name: "external_api_call_errors_total",
+				Help: "Total number of external API call errors",
+			},
+		),
+
+		ProcessingDuration: f.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "processing_duration_ms",
+				Help:    "Processing duration in milliseconds",
+				Buckets: []float64{50, 100, 200, 300, 500, 800, 1000},
+			},
+		),
+
+		ProcessingErrors: f.NewCounter(
+			prometheus.CounterOpts{
+				Name: "processing_errors_total",
+				Help: "Total number of processing errors",
+			},
+		),
+
+		CacheHits: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cache_hits_total",
+				Help: "Total number of simulated cache hits",
+			},
+			[]string{"step"},
+		),
+
+		CacheMisses: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cache_misses_total",
+				Help: "Total number of simulated cache misses",
+			},
+			[]string{"step"},
+		),
+
+		CacheEvictions: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cache_evictions_total",
+				Help: "Total number of simulated cache entries evicted by TTL or flush",
+			},
+			[]string{"step"},
+		),
+
+		CacheInflightMisses: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cache_inflight_misses",
+				Help: "Number of cache misses currently waiting on the backing dependency",
+			},
+			[]string{"step"},
+		),
+
+		DBRowsScanned: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "db_rows_scanned",
+				Help:    "Rows examined by the simulated database per list query",
+				Buckets: []float64{10, 25, 50, 100, 250, 500, 1000, 2500, 5000},
+			},
+			[]string{"path"},
+		),
+
+		PageSize: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "page_size",
+				Help:    "Number of records returned per list page",
+				Buckets: []float64{1, 5, 10, 25, 50, 100, 250, 500},
+			},
+			[]string{"path"},
+		),
+
+		JobQueueDepth: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "job_queue_depth",
+				Help: "Number of async jobs waiting for a worker",
+			},
+		),
+
+		JobsInProgress: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "jobs_in_progress",
+				Help: "Number of async jobs currently running",
+			},
+		),
+
+		JobsTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "jobs_total",
+				Help: "Total number of async jobs by final status",
+			},
+			[]string{"status"},
+		),
+
+		JobQueueWait: f.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "job_queue_wait_ms",
+				Help:    "Time async jobs spend queued before a worker picks them up in milliseconds",
+				Buckets: []float64{10, 50, 100, 500, 1000, 2000, 5000, 10000, 30000},
+			},
+		),
+
+		JobDuration: f.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "job_duration_ms",
+				Help:    "Async job run time in milliseconds",
+				Buckets: []float64{500, 1000, 2000, 3000, 5000, 8000, 10000, 15000},
+			},
+		),
+
+		JobCallbacks: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "job_callbacks_total",
+				Help: "Total number of job completion webhooks by outcome",
+			},
+			[]string{"result"},
+		),
+
+		ProcessItems: f.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "process_items",
+				Help:    "Number of items per process request",
+				Buckets: []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000},
+			},
+		),
+
+		ProcessItemDuration: f.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "process_item_duration_ms",
+				Help:    "Processing duration of a single item in milliseconds",
+				Buckets: []float64{5, 10, 20, 50, 100, 200, 500, 1000},
+			},
+		),
+
+		ProcessItemErrors: f.NewCounter(
+			prometheus.CounterOpts{
+				Name: "process_item_errors_total",
+				Help: "Total number of items that failed processing",
+			},
+		),
+
+		ProcessMemoryBytes: f.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "process_memory_bytes",
+				Help:    "Peak memory held while processing the items of one request",
+				Buckets: prometheus.ExponentialBuckets(1024, 4, 10), // 1KiB to 256MiB
+			},
+		),
+
+		FaultOverrides: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "fault_overrides_total",
+				Help: "Total number of per-request fault overrides that took effect",
+			},
+			[]string{"kind", "step"},
+		),
+
+		FaultOverridesRejected: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "fault_overrides_rejected_total",
+				Help: "Total number of requests whose fault override headers were refused",
+			},
+			[]string{"reason"},
+		),
+
+		LeakedBytes: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "degrade_leaked_bytes",
+				Help: "Bytes retained by the memory leak degradation mode",
+			},
+		),
+
+		LeakedGoroutines: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "degrade_leaked_goroutines",
+				Help: "Goroutines parked by the goroutine leak degradation mode",
+			},
+		),
+
+		CPUBurnMs: f.NewCounter(
+			prometheus.CounterOpts{
+				Name: "degrade_cpu_burn_ms_total",
+				Help: "Total milliseconds of CPU burned by the CPU degradation mode",
+			},
+		),
+
+		ResponseTTFB: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_response_ttfb_ms",
+				Help:    "Time from the start of a request to the first response byte in milliseconds",
+				Buckets: []float64{10, 50, 100, 200, 500, 1000, 2000, 5000},
+			},
+			[]string{"path"},
+		),
+
+		ResponseTransfer: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_response_transfer_ms",
+				Help:    "Time from the start of a request to the last response byte in milliseconds",
+				Buckets: []float64{10, 50, 100, 200, 500, 1000, 2000, 5000, 10000, 30000},
+			},
+			[]string{"path"},
+		),
+
+		NetworkFaults: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "network_faults_total",
+				Help: "Total number of injected network-level failures",
+			},
+			[]string{"kind"},
+		),
+
+		ActiveStreams: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "sse_active_streams",
+				Help: "Number of open Server-Sent Events streams",
+			},
+		),
+
+		StreamEvents: f.NewCounter(
+			prometheus.CounterOpts{
+				Name: "sse_events_total",
+				Help: "Total number of Server-Sent Events sent",
+			},
+		),
+
+		StreamEventLag: f.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "sse_event_lag_ms",
+				Help:    "Time from when an event was due to when it was flushed to the client in milliseconds",
+				Buckets: []float64{1, 5, 10, 50, 100, 500, 1000, 5000},
+			},
+		),
+
+		StreamDisconnects: f.NewCounter(
+			prometheus.CounterOpts{
+				Name: "sse_injected_disconnects_total",
+				Help: "Total number of streams dropped on purpose mid-stream",
+			},
+		),
+
+		WSActiveConnections: f.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "ws_active_connections",
+				Help: "Number of open WebSocket connections",
+			},
+		),
+
+		WSConnections: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ws_connections_total",
+				Help: "Total number of WebSocket connections",
+			},
+			[]string{"mode"},
+		),
+
+		WSMessages: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ws_messages_total",
+				Help: "Total number of WebSocket messages by what happened to them",
+			},
+			[]string{"outcome"},
+		),
+
+		WSMessageLatency: f.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "ws_message_latency_ms",
+				Help:    "Time from receiving or producing a WebSocket message to sending it in milliseconds",
+				Buckets: []float64{1, 5, 10, 50, 100, 200, 500, 1000, 5000},
+			},
+		),
+
+		WSCloses: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ws_injected_closes_total",
+				Help: "Total number of WebSocket connections closed on purpose, by close code",
+			},
+			[]string{"code"},
+		),
+
+		GraphQLResolverDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "graphql_resolver_duration_ms",
+				Help:    "Duration of GraphQL field resolver calls in milliseconds",
+				Buckets: []float64{1, 5, 10, 20, 50, 100, 200, 500, 1000},
+			},
+			[]string{"field"},
+		),
+
+		GraphQLResolverErrors: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "graphql_resolver_errors_total",
+				Help: "Total number of failed GraphQL field resolver calls",
+			},
+			[]string{"field"},
+		),
+
+		GraphQLBatchSize: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "graphql_dataloader_batch_size",
+				Help:    "Number of keys loaded by one batched GraphQL resolver call",
+				Buckets: []float64{1, 2, 5, 10, 20, 50, 100, 500},
+			},
+			[]string{"field"},
+		),
+
+		BatchFanoutWidth: f.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "batch_fanout_width",
+				Help:    "Number of sub-requests in one /api/batch request",
+				Buckets: []float64{1, 2, 5, 10, 20, 50, 100},
+			},
+		),
+
+		BatchItemDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "batch_item_duration_ms",
+				Help:    "Duration of a single /api/batch sub-request in milliseconds",
+				Buckets: []float64{10, 50, 100, 200, 500, 1000, 2000, 5000, 10000},
+			},
+			[]string{"op"},
+		),
+
+		BatchDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "batch_duration_ms",
+				Help:    "Duration of /api/batch requests in milliseconds, by fan-out width",
+				Buckets: []float64{10, 50, 100, 200, 500, 1000, 2000, 5000, 10000},
+			},
+			[]string{"width"},
+		),
+
+		DownstreamCalls: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "downstream_calls_total",
+				Help: "Total number of calls to downstream instances, by step and response status (0 when none arrived)",
+			},
+			[]string{"step", "status"},
+		),
+
+		DownstreamDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "downstream_call_duration_ms",
+				Help:    "Duration of calls to downstream instances in milliseconds",
+				Buckets: []float64{10, 50, 100, 200, 500, 1000, 2000, 5000, 10000},
+			},
+			[]string{"step"},
+		),
+
+		ProxyRequests: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "proxy_requests_total",
+				Help: "Total number of proxied requests, by matching rule and outcome",
+			},
+			[]string{"rule", "outcome"},
+		),
+
+		ProxyInjectedDelay: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "proxy_injected_delay_ms",
+				Help:    "Delay added to proxied requests before forwarding them in milliseconds",
+				Buckets: []float64{10, 50, 100, 200, 500, 1000, 2000, 5000, 10000},
+			},
+			[]string{"rule"},
+		),
+
+		ProxyUpstreamDuration: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "proxy_upstream_duration_ms",
+				Help:    "Time until the upstream's response headers arrive in milliseconds",
+				Buckets: []float64{10, 50, 100, 200, 500, 1000, 2000, 5000, 10000},
+			},
+			[]string{"rule"},
+		),
+
+		SLOEvents: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "slo_events_total",
+				Help: "Total number of requests counted towards an SLO",
+			},
+			[]string{"slo"},
+		),
+
+		SLOGoodEvents: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "slo_good_events_total",
+				Help: "Total number of requests that met an SLO",
+			},
+			[]string{"slo"},
+		),
+	}
+
+	// The standard grpc_server_* metrics of go-grpc-middleware
+	m.GRPC = grpcprom.NewServerMetrics(grpcprom.WithServerHandlingTimeHistogram(
+		grpcprom.WithHistogramBuckets([]float64{0.01, 0.05, 0.1, 0.2, 0.5, 1, 2, 5, 10}),
+	))
+	if reg != nil {
+		reg.MustRegister(m.GRPC)
+	}
+	return m
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Unic-X/slow-server/config"
+)
+
+// ApplyConfigMiddleware loads the config once per request and attaches the
+// snapshot, so a change at runtime never lands halfway through a request.
+// It must run outside every middleware and handler reading the config.
+func ApplyConfigMiddleware(next http.Handler, current *config.Current) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(config.WithContext(r.Context(), current.Load())))
+	})
+}
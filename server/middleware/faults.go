@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/faults"
+	"github.com/Unic-X/slow-server/metrics"
+	"github.com/charmbracelet/log"
+)
+
+// ApplyFaultHeadersMiddleware lets authorized clients force delays, failures
+// and the random seed of their own request through the X-Slow-* headers.
+// When the feature is disabled the headers are ignored; when it is enabled
+// but the client is neither allow-listed nor presents the shared secret the
+// request is refused, so a misconfigured test fails loudly. The feature and
+// the allow-list are read per request, so patching them applies at once.
+func ApplyFaultHeadersMiddleware(next http.Handler, current *config.Current, m *metrics.Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := current.For(r.Context())
+		if !cfg.FaultHeadersEnabled || !faults.HasHeaders(r.Header) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestID := r.Header.Get("X-Request-ID")
+
+		if !clientAllowed(r.RemoteAddr, r.Header.Get(faults.TokenHeader), parseAllowList(cfg.FaultHeadersAllow), cfg.FaultHeadersSecret) {
+			log.Warnf("[%s] Refusing fault override headers from %s", requestID, r.RemoteAddr)
+			m.FaultOverridesRejected.WithLabelValues("forbidden").Inc()
+			http.Error(w, "Fault override headers not allowed", http.StatusForbidden)
+			return
+		}
+
+		overrides, err := faults.ParseHeaders(r.Header)
+		if err != nil {
+			log.Warnf("[%s] Invalid fault override headers: %v", requestID, err)
+			m.FaultOverridesRejected.WithLabelValues("invalid").Inc()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		overrides.RequestID = requestID
+
+		if overrides.Seed != nil {
+			log.Warnf("[%s] Forced seed %d", requestID, *overrides.Seed)
+			m.FaultOverrides.WithLabelValues("seed", "").Inc()
+		}
+
+		next.ServeHTTP(w, r.WithContext(faults.WithOverrides(r.Context(), overrides)))
+	})
+}
+
+// parseAllowList turns IPs and CIDRs into networks, skipping bad entries
+func parseAllowList(entries []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Warnf("Ignoring invalid fault header allow-list entry %q", entry)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// clientAllowed reports whether a client at remoteAddr presenting token may
+// force faults
+func clientAllowed(remoteAddr, token string, allowed []*net.IPNet, secret string) bool {
+	if secret != "" {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1 {
+			return true
+		}
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range allowed {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
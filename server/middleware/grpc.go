@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/faults"
+	"github.com/Unic-X/slow-server/metrics"
+	"github.com/Unic-X/slow-server/random"
+	"github.com/Unic-X/slow-server/trace"
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCUnaryInterceptor gives every unary gRPC call what the logging and
+// seed middleware give an HTTP request: an x-request-id, a trace context
+// continuing any traceparent, its own random source seeded from seeds or an
+// x-slow-seed override, and log lines. The request ID and seed are sent
+// back as header metadata. x-slow-seed is authorized like the X-Slow-Seed
+// header: ignored unless fault headers are enabled, and refused with
+// PERMISSION_DENIED for a peer neither allow-listed nor presenting the
+// secret in x-slow-token.
+func GRPCUnaryInterceptor(seeds *random.Rand, current *config.Current, m *metrics.Metrics, clk clock.Clock) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, requestID, header, err := prepareGRPCCall(ctx, seeds, current, m)
+		if err != nil {
+			return nil, err
+		}
+		grpc.SetHeader(ctx, header)
+
+		startTime := clk.Now()
+		log.Infof("[%s] Started gRPC %s", requestID, info.FullMethod)
+		resp, err := handler(ctx, req)
+		log.Infof("[%s] Completed gRPC %s %s in %v", requestID, info.FullMethod, status.Code(err), clk.Since(startTime))
+		return resp, err
+	}
+}
+
+// GRPCStreamInterceptor is GRPCUnaryInterceptor for streaming calls
+func GRPCStreamInterceptor(seeds *random.Rand, current *config.Current, m *metrics.Metrics, clk clock.Clock) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, requestID, header, err := prepareGRPCCall(ss.Context(), seeds, current, m)
+		if err != nil {
+			return err
+		}
+		ss.SetHeader(header)
+
+		startTime := clk.Now()
+		log.Infof("[%s] Started gRPC %s", requestID, info.FullMethod)
+		err = handler(srv, &grpcStream{ServerStream: ss, ctx: ctx})
+		log.Infof("[%s] Completed gRPC %s %s in %v", requestID, info.FullMethod, status.Code(err), clk.Since(startTime))
+		return err
+	}
+}
+
+// prepareGRPCCall assigns the request ID and random source of a call and
+// returns the header metadata reporting them
+func prepareGRPCCall(ctx context.Context, seeds *random.Rand, current *config.Current, m *metrics.Metrics) (context.Context, string, metadata.MD, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+
+	var requestID string
+	if ids := md.Get("x-request-id"); len(ids) > 0 && ids[0] != "" {
+		requestID = ids[0]
+	} else {
+		requestID = uuid.New().String()
+		md.Set("x-request-id", requestID)
+	}
+
+	seed, err := forcedSeed(ctx, md, requestID, current.For(ctx), m)
+	if err != nil {
+		return ctx, requestID, nil, err
+	}
+	if seed == nil {
+		n := seeds.Int63()
+		seed = &n
+	}
+
+	ctx = metadata.NewIncomingContext(ctx, md)
+	ctx = trace.WithContext(ctx, trace.FromHeaders(first(md.Get(trace.Header)), requestID))
+	ctx = random.WithRand(ctx, random.New(*seed))
+	return ctx, requestID, metadata.Pairs("x-request-id", requestID, "x-slow-seed", strconv.FormatInt(*seed, 10)), nil
+}
+
+// forcedSeed returns the seed a call's x-slow-seed metadata forces, or nil
+// without one or with fault headers disabled
+func forcedSeed(ctx context.Context, md metadata.MD, requestID string, cfg *config.Config, m *metrics.Metrics) (*int64, error) {
+	value := first(md.Get(faults.SeedHeader))
+	if !cfg.FaultHeadersEnabled || value == "" {
+		return nil, nil
+	}
+
+	var remoteAddr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+	if !clientAllowed(remoteAddr, first(md.Get(faults.TokenHeader)), parseAllowList(cfg.FaultHeadersAllow), cfg.FaultHeadersSecret) {
+		log.Warnf("[%s] Refusing fault override metadata from %s", requestID, remoteAddr)
+		m.FaultOverridesRejected.WithLabelValues("forbidden").Inc()
+		return nil, status.Error(codes.PermissionDenied, "Fault override metadata not allowed")
+	}
+
+	seed, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		log.Warnf("[%s] Invalid fault override metadata: %v", requestID, err)
+		m.FaultOverridesRejected.WithLabelValues("invalid").Inc()
+		return nil, status.Errorf(codes.InvalidArgument, "%s must be an integer", strings.ToLower(faults.SeedHeader))
+	}
+	log.Warnf("[%s] Forced seed %d", requestID, seed)
+	m.FaultOverrides.WithLabelValues("seed", "").Inc()
+	return &seed, nil
+}
+
+// first returns the first of values, or ""
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// grpcStream is a ServerStream with the context prepared for the call
+type grpcStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcStream) Context() context.Context {
+	return s.ctx
+}
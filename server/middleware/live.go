@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/live"
+)
+
+// PatternMatcher finds the route pattern serving a request, like
+// (*http.ServeMux).Handler
+type PatternMatcher interface {
+	Handler(r *http.Request) (h http.Handler, pattern string)
+}
+
+// ApplyLiveStatsMiddleware records every finished API request into stats
+// under its method and route pattern, so /admin/ui groups /api/users/1 and
+// /api/users/2 together. Admin requests, including the page's own polling,
+// and /metrics are left out.
+func ApplyLiveStatsMiddleware(next http.Handler, stats *live.Stats, routes PatternMatcher, clk clock.Clock) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/admin/") || r.URL.Path == "/metrics" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		route := routeName(r, routes)
+		startTime := clk.Now()
+		lrw := newLoggingResponseWriter(w)
+		next.ServeHTTP(lrw, r)
+		stats.RecordRoute(route, lrw.statusCode, clk.Since(startTime))
+	})
+}
+
+// routeName is the method and route pattern serving r, e.g.
+// "GET /api/users/{id}"
+func routeName(r *http.Request, routes PatternMatcher) string {
+	_, pattern := routes.Handler(r)
+	switch {
+	case pattern == "":
+		return r.Method + " (unmatched)"
+	case !strings.Contains(pattern, " "):
+		return r.Method + " " + pattern
+	}
+	return pattern
+}
+
+// routePath is the path of the route pattern serving r, e.g.
+// "/api/users/{id}", or "(unmatched)" without one
+func routePath(r *http.Request, routes PatternMatcher) string {
+	_, pattern := routes.Handler(r)
+	if pattern == "" {
+		return "(unmatched)"
+	}
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return strings.TrimSpace(path)
+	}
+	return pattern
+}
+
+// metricsPath is the path label of r's metrics: its route path, or for a
+// proxied request the proxy rule it matched, e.g. "GET /api/*" or
+// "default", since every upstream path would be a new series
+func metricsPath(r *http.Request, cfg *config.Config, routes PatternMatcher) string {
+	if cfg.Proxies(r.URL.Path) {
+		return cfg.ProxyRuleFor(r.Method, r.URL.Path).String()
+	}
+	return routePath(r, routes)
+}
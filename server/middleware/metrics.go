@@ -1,16 +1,20 @@
 package middleware
 
 import (
+	"bufio"
+	"net"
 	"net/http"
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/config"
 	"github.com/Unic-X/slow-server/metrics"
-	"time"
+	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/charmbracelet/log"
 )
 
 // LoggingMiddleware logs request information and timing
-func ApplyLoggingMiddleware(next http.Handler) http.Handler {
+func ApplyLoggingMiddleware(next http.Handler, clk clock.Clock) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestID := r.Header.Get("X-Request-ID")
 		if requestID == "" {
@@ -18,7 +22,7 @@ func ApplyLoggingMiddleware(next http.Handler) http.Handler {
 			r.Header.Set("X-Request-ID", requestID)
 		}
 
-		startTime := time.Now()
+		startTime := clk.Now()
 		method := r.Method
 		path := r.URL.Path
 		log.Infof("[%s] Started %s %s", requestID, method, path)
@@ -27,16 +31,20 @@ func ApplyLoggingMiddleware(next http.Handler) http.Handler {
 
 		next.ServeHTTP(lrw, r)
 
-		duration := time.Since(startTime)
+		duration := clk.Since(startTime)
 		statusCode := lrw.statusCode
 		log.Infof("[%s] Completed %s %s %d %s in %v", 
 			requestID, method, path, statusCode, http.StatusText(statusCode), duration)
 	})
 }
 
-func ApplyMetricsMiddleware(next http.Handler) http.Handler {
+// ApplyMetricsMiddleware records the count, duration and errors of every
+// request, labelled with the path of its route pattern so that
+// /api/users/1 and /api/users/2 share one series, or in proxy mode with
+// the proxy rule it matched
+func ApplyMetricsMiddleware(next http.Handler, current *config.Current, m *metrics.Metrics, routes PatternMatcher, clk clock.Clock) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		startTime := time.Now()
+		startTime := clk.Now()
 		
 		// Create a custom response writer to capture status code
 		mrw := newLoggingResponseWriter(w)
@@ -45,20 +53,20 @@ func ApplyMetricsMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(mrw, r)
 		
 		// Record metrics
-		duration := time.Since(startTime)
-		path := r.URL.Path
+		duration := clk.Since(startTime)
+		path := metricsPath(r, current.For(r.Context()), routes)
 		method := r.Method
 		statusCode := mrw.statusCode
 		
 		// Update request counters
-		metrics.RequestsTotal.WithLabelValues(path, method, string(statusCode)).Inc()
+		m.RequestsTotal.WithLabelValues(path, method, strconv.Itoa(statusCode)).Inc()
 		
 		// Update request duration histogram
-		metrics.RequestDuration.WithLabelValues(path, method).Observe(float64(duration.Milliseconds()))
+		m.RequestDuration.WithLabelValues(path, method).Observe(float64(duration.Milliseconds()))
 		
 		// Track error rates
 		if statusCode >= 400 {
-			metrics.RequestErrors.WithLabelValues(path, method, string(statusCode)).Inc()
+			m.RequestErrors.WithLabelValues(path, method, strconv.Itoa(statusCode)).Inc()
 		}
 	})
 }
@@ -79,3 +87,14 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.statusCode = code
 	lrw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
+// Hijack hands the connection to the handler, e.g. for a WebSocket upgrade
+func (lrw *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	lrw.statusCode = http.StatusSwitchingProtocols
+	return http.NewResponseController(lrw.ResponseWriter).Hijack()
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/faults"
+	"github.com/Unic-X/slow-server/metrics"
+	"github.com/Unic-X/slow-server/random"
+	"github.com/charmbracelet/log"
+)
+
+// ApplyNetworkFaultsMiddleware breaks API and proxied responses below the
+// HTTP layer: resetting the connection, hanging, or sending a truncated,
+// malformed or mislabelled body; a stream is cut inside its first event
+// instead. Each kind has its own rate, and an X-Slow-Network override
+// forces one. It must run inside ApplySeedMiddleware so the rolls come from
+// the request's own random source.
+func ApplyNetworkFaultsMiddleware(next http.Handler, current *config.Current, m *metrics.Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := current.For(r.Context())
+		if !strings.HasPrefix(r.URL.Path, "/api/") && !cfg.Proxies(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		kind := pickNetworkFault(r, cfg)
+		if kind == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestID := r.Header.Get("X-Request-ID")
+		log.Warnf("[%s] Injecting network fault %s", requestID, kind)
+		m.NetworkFaults.WithLabelValues(kind).Inc()
+
+		switch kind {
+		case faults.NetworkReset:
+			closeConn(w, true)
+		case faults.NetworkHang:
+			<-r.Context().Done()
+		default:
+			captured := &capturedResponse{w: w, header: w.Header(), status: http.StatusOK}
+			next.ServeHTTP(captured, r)
+			if !captured.streaming {
+				writeBroken(w, kind, captured)
+			}
+		}
+	})
+}
+
+// pickNetworkFault returns the forced network fault of the request, or rolls
+// the configured rates. Rates of 0 are not rolled, so enabling nothing keeps
+// the random sequence, and with it every seeded run, unchanged.
+func pickNetworkFault(r *http.Request, cfg *config.Config) string {
+	if kind, ok := faults.FromContext(r.Context()).NetworkFault(); ok {
+		return kind
+	}
+
+	rnd := random.FromContext(r.Context())
+	if rnd == nil {
+		return ""
+	}
+	for _, kind := range faults.NetworkFaults {
+		if rate := cfg.NetworkFaultRate(kind); rate > 0 && rnd.Float64() < rate {
+			return kind
+		}
+	}
+	return ""
+}
+
+// writeBroken sends the captured response damaged the way kind describes
+func writeBroken(w http.ResponseWriter, kind string, captured *capturedResponse) {
+	body := captured.body.Bytes()
+	header := w.Header()
+
+	switch kind {
+	case faults.NetworkTruncate:
+		// Stream half the body chunked, then drop the connection before the final chunk
+		header.Del("Content-Length")
+		w.WriteHeader(captured.status)
+		w.Write(body[:len(body)/2])
+		http.NewResponseController(w).Flush()
+		closeConn(w, false)
+	case faults.NetworkInvalidJSON:
+		cut := body[:len(body)/2]
+		header.Set("Content-Length", strconv.Itoa(len(cut)))
+		w.WriteHeader(captured.status)
+		w.Write(cut)
+	case faults.NetworkBadLength:
+		// net/http closes the connection once the handler returns short of the announced length
+		header.Set("Content-Length", strconv.Itoa(len(body)+len(body)/2+1))
+		w.WriteHeader(captured.status)
+		w.Write(body)
+	}
+}
+
+// closeConn takes over the connection and closes it, with a TCP reset if
+// reset is set. Connections that cannot be hijacked, like HTTP/2 streams, are
+// aborted instead.
+func closeConn(w http.ResponseWriter, reset bool) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok && reset {
+		tcp.SetLinger(0) // Close sends RST instead of FIN
+	}
+	conn.Close()
+}
+
+// errStreamBroken is returned to a streaming handler once its stream was cut
+var errStreamBroken = errors.New("stream broken by network fault")
+
+// capturedResponse buffers a handler's response so it can be sent damaged.
+// Headers go straight to the real writer. A handler that flushes is
+// streaming and cannot be buffered to the end, so its stream is cut in the
+// middle of the first data it flushes, whatever the kind of fault.
+type capturedResponse struct {
+	w           http.ResponseWriter
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	streaming   bool
+	broken      bool
+}
+
+func (c *capturedResponse) Header() http.Header {
+	return c.header
+}
+
+func (c *capturedResponse) WriteHeader(code int) {
+	if !c.wroteHeader {
+		c.status = code
+		c.wroteHeader = true
+	}
+}
+
+func (c *capturedResponse) Write(p []byte) (int, error) {
+	if c.broken {
+		return 0, errStreamBroken
+	}
+	c.wroteHeader = true
+	return c.body.Write(p)
+}
+
+// FlushError sends the headers on the first flush, then half of the first
+// data flushed before closing the connection
+func (c *capturedResponse) FlushError() error {
+	if c.broken {
+		return errStreamBroken
+	}
+	rc := http.NewResponseController(c.w)
+	if !c.streaming {
+		c.streaming = true
+		c.header.Del("Content-Length")
+		c.w.WriteHeader(c.status)
+	}
+	if c.body.Len() == 0 {
+		return rc.Flush()
+	}
+
+	body := c.body.Bytes()
+	c.w.Write(body[:len(body)/2])
+	rc.Flush()
+	closeConn(c.w, false)
+	c.broken = true
+	return errStreamBroken
+}
+
+func (c *capturedResponse) Flush() {
+	c.FlushError()
+}
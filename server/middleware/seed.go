@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Unic-X/slow-server/faults"
+	"github.com/Unic-X/slow-server/random"
+)
+
+// ApplySeedMiddleware gives every request its own random source, seeded from
+// the next value of the run-wide source or from an X-Slow-Seed override.
+// The seed is echoed in the X-Slow-Seed response header so that a single
+// request can be replayed exactly.
+func ApplySeedMiddleware(next http.Handler, seeds *random.Rand) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var seed int64
+		if o := faults.FromContext(r.Context()); o != nil && o.Seed != nil {
+			seed = *o.Seed
+		} else {
+			seed = seeds.Int63()
+		}
+
+		w.Header().Set(faults.SeedHeader, strconv.FormatInt(seed, 10))
+		next.ServeHTTP(w, r.WithContext(random.WithRand(r.Context(), random.New(seed))))
+	})
+}
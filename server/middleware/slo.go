@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/slo"
+)
+
+// ApplySLOMiddleware counts every finished request towards the SLOs it
+// matches, by its status and its duration including the body transfer
+func ApplySLOMiddleware(next http.Handler, t *slo.Tracker, clk clock.Clock) http.Handler {
+	if t.Empty() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTime := clk.Now()
+		lrw := newLoggingResponseWriter(w)
+		next.ServeHTTP(lrw, r)
+		t.Record(r.Method, r.URL.Path, lrw.statusCode, clk.Since(startTime))
+	})
+}
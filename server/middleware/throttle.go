@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/metrics"
+)
+
+// ApplyThrottleMiddleware writes response bodies the way a slow network or
+// a buffering proxy would deliver them, per the config.Throttle of the path
+// or else of its route pattern such as /api/users/{id}, or the matching
+// proxy rule's, and records the time to first and to last byte of every
+// response under the path of its route pattern, or the proxy rule it matched
+func ApplyThrottleMiddleware(next http.Handler, current *config.Current, m *metrics.Metrics, routes PatternMatcher, clk clock.Clock) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := current.For(r.Context())
+		throttle, _ := cfg.ThrottleFor(r.URL.Path, routePath(r, routes))
+		if cfg.Proxies(r.URL.Path) {
+			if rule := cfg.ProxyRuleFor(r.Method, r.URL.Path); rule.Throttle != (config.Throttle{}) {
+				throttle = rule.Throttle
+			}
+		}
+		tw := &throttledWriter{
+			ResponseWriter: w,
+			throttle:       throttle,
+			ctx:            r.Context(),
+			clk:            clk,
+			start:          clk.Now(),
+		}
+
+		next.ServeHTTP(tw, r)
+
+		if tw.firstByte.IsZero() {
+			tw.markFirstByte()
+		}
+		path := metricsPath(r, cfg, routes)
+		m.ResponseTTFB.WithLabelValues(path).Observe(float64(tw.firstByte.Sub(tw.start).Milliseconds()))
+		m.ResponseTransfer.WithLabelValues(path).Observe(float64(clk.Since(tw.start).Milliseconds()))
+	})
+}
+
+// throttledWriter limits the write rate, splits the body into chunks that
+// are flushed one by one and holds back every flush, until the request's
+// ctx ends
+type throttledWriter struct {
+	http.ResponseWriter
+	throttle  config.Throttle
+	ctx       context.Context
+	clk       clock.Clock
+	start     time.Time
+	firstByte time.Time
+}
+
+func (tw *throttledWriter) markFirstByte() {
+	if tw.firstByte.IsZero() {
+		tw.firstByte = tw.clk.Now()
+	}
+}
+
+// sleep holds the response back for d, or until the client has gone away
+func (tw *throttledWriter) sleep(d time.Duration) error {
+	select {
+	case <-tw.ctx.Done():
+		return tw.ctx.Err()
+	case <-tw.clk.After(d):
+		return nil
+	}
+}
+
+func (tw *throttledWriter) WriteHeader(code int) {
+	tw.markFirstByte()
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if tw.throttle.ChunkSize > 0 && n > tw.throttle.ChunkSize {
+			n = tw.throttle.ChunkSize
+		}
+		if tw.throttle.BytesPerSec > 0 {
+			if err := tw.sleep(time.Duration(n) * time.Second / time.Duration(tw.throttle.BytesPerSec)); err != nil {
+				return written, err
+			}
+		}
+
+		tw.markFirstByte()
+		m, err := tw.ResponseWriter.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		if tw.throttle.ChunkSize > 0 {
+			tw.Flush()
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Flush sends what has been written so far after the configured flush
+// delay, unless the client has gone away meanwhile
+func (tw *throttledWriter) Flush() {
+	if tw.throttle.FlushDelay > 0 {
+		if tw.sleep(time.Duration(tw.throttle.FlushDelay)*time.Millisecond) != nil {
+			return
+		}
+	}
+	http.NewResponseController(tw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (tw *throttledWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// Hijack hands the connection to the handler unthrottled
+func (tw *throttledWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.markFirstByte()
+	return http.NewResponseController(tw.ResponseWriter).Hijack()
+}
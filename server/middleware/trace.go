@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Unic-X/slow-server/trace"
+)
+
+// ApplyTraceMiddleware continues the trace of an incoming traceparent
+// header, or starts one, so that downstream calls made for the request
+// carry it on together with the request ID
+func ApplyTraceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc := trace.FromHeaders(r.Header.Get(trace.Header), r.Header.Get("X-Request-ID"))
+		next.ServeHTTP(w, r.WithContext(trace.WithContext(r.Context(), tc)))
+	})
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/faults"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/traffic"
+)
+
+// ApplyTrafficMiddleware records every finished API request into rec with
+// its route pattern, status, duration and seed for /admin/traffic. Admin
+// requests and /metrics are left out, like in the live stats.
+func ApplyTrafficMiddleware(next http.Handler, rec *traffic.Recorder, routes PatternMatcher, clk clock.Clock) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/admin/") || r.URL.Path == "/metrics" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorded := models.RecordedRequest{
+			Time:   clk.Now(),
+			Method: r.Method,
+			Path:   r.URL.RequestURI(),
+			Route:  routeName(r, routes),
+		}
+		lrw := newLoggingResponseWriter(w)
+		next.ServeHTTP(lrw, r)
+
+		recorded.DurationMs = float64(clk.Since(recorded.Time)) / float64(time.Millisecond)
+		recorded.Status = lrw.statusCode
+		recorded.RequestID = r.Header.Get("X-Request-ID")
+		recorded.Seed, _ = strconv.ParseInt(w.Header().Get(faults.SeedHeader), 10, 64)
+		rec.Record(recorded)
+	})
+}
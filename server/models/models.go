@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type AppError struct {
 	Message    string
 	StatusCode int
@@ -24,8 +26,10 @@ type DataItem struct {
 
 // DataResponse represents the response for the data endpoint
 type DataResponse struct {
-	Data  []DataItem `json:"data"`
-	Count int        `json:"count"`
+	Data       []DataItem `json:"data"`
+	Count      int        `json:"count"`
+	Total      int        `json:"total"`
+	NextCursor string     `json:"next_cursor,omitempty"`
 }
 
 type User struct {
@@ -35,17 +39,239 @@ type User struct {
 }
 
 type UsersResponse struct {
-	Users []User `json:"users"`
-	Count int    `json:"count"`
+	Users      []User `json:"users"`
+	Count      int    `json:"count"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type ProcessRequest struct {
-	Items []string          `json:"items"`
-	Args  map[string]string `json:"args"`
+	Items       []string          `json:"items"`
+	Args        map[string]string `json:"args"`
+	CallbackURL string            `json:"callback_url,omitempty"` // Notified when an async job finishes
+}
+
+// Per-item outcomes in a ProcessResponse
+const (
+	ItemOK     = "ok"
+	ItemFailed = "failed"
+)
+
+// ItemResult is the outcome of processing a single ProcessRequest item
+type ItemResult struct {
+	Index      int    `json:"index"`
+	Item       string `json:"item"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
 }
 
+// ProcessResponse reports every item; Success is only true if none failed
 type ProcessResponse struct {
-	Success   bool   `json:"success"`
-	ProcessID int    `json:"process_id"`
-	Message   string `json:"message"`
+	Success   bool         `json:"success"`
+	Message   string       `json:"message"`
+	Processed int          `json:"processed"`
+	Failed    int          `json:"failed"`
+	Results   []ItemResult `json:"results"`
+}
+
+// Operations a BatchItem can run
+const (
+	BatchUser  = "user"
+	BatchUsers = "users"
+	BatchData  = "data"
+)
+
+// BatchRequest is a list of sub-requests that /api/batch runs side by side
+type BatchRequest struct {
+	Requests    []BatchItem `json:"requests"`
+	Concurrency int         `json:"concurrency,omitempty"` // Overrides BATCH_CONCURRENCY
+}
+
+// BatchItem is one sub-request: a user lookup by ID, or a users or data
+// list with the query parameters of the list endpoints
+type BatchItem struct {
+	Op     string            `json:"op"`
+	ID     int               `json:"id,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// BatchResult is the outcome of one BatchItem. Status is the HTTP status
+// the sub-request would have had on its own endpoint.
+type BatchResult struct {
+	Index      int         `json:"index"`
+	Op         string      `json:"op"`
+	Status     int         `json:"status"`
+	Body       interface{} `json:"body,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	DurationMs int64       `json:"duration_ms"`
+}
+
+// BatchResponse reports every sub-request. DurationMs is the wall time of
+// the whole batch, so it follows the slowest sub-request.
+type BatchResponse struct {
+	Succeeded   int           `json:"succeeded"`
+	Failed      int           `json:"failed"`
+	Concurrency int           `json:"concurrency"`
+	DurationMs  int64         `json:"duration_ms"`
+	SlowestMs   int64         `json:"slowest_ms"`
+	Results     []BatchResult `json:"results"`
+}
+
+// CacheFlushResponse reports the outcome of a cache flush admin action
+type CacheFlushResponse struct {
+	Step    string `json:"step,omitempty"`
+	Evicted int    `json:"evicted"`
+}
+
+// Job states reported by the async processing API
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job is an asynchronous /api/process request
+type Job struct {
+	ID         string           `json:"id"`
+	Status     string           `json:"status"`
+	Progress   float64          `json:"progress"` // Fraction of pipeline steps completed, 0 to 1
+	Result     *ProcessResponse `json:"result,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	StartedAt  *time.Time       `json:"started_at,omitempty"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+// DegradeStatus reports which degradation modes are on and what they have consumed so far
+type DegradeStatus struct {
+	Memory           bool `json:"memory"`
+	Goroutines       bool `json:"goroutines"`
+	CPU              bool `json:"cpu"`
+	LeakedBytes      int  `json:"leaked_bytes"`
+	LeakedGoroutines int  `json:"leaked_goroutines"`
+	BurningRequests  int  `json:"burning_requests"`
+}
+
+// SLOStatus reports one objective over every window the server tracks
+type SLOStatus struct {
+	Name      string      `json:"name"`
+	Method    string      `json:"method,omitempty"`
+	Path      string      `json:"path"`
+	Objective float64     `json:"objective"`
+	LatencyMs int         `json:"latency_ms,omitempty"`
+	Windows   []SLOWindow `json:"windows"`
+}
+
+// SLOWindow is an objective over one window. SLI is the good fraction and
+// BurnRate how fast the error budget is used up, 1 meaning exactly on
+// budget; both are omitted while the window holds no requests.
+type SLOWindow struct {
+	Window   string   `json:"window"`
+	Good     int64    `json:"good"`
+	Total    int64    `json:"total"`
+	SLI      *float64 `json:"sli,omitempty"`
+	BurnRate *float64 `json:"burn_rate,omitempty"`
+}
+
+// LiveStats is what /admin/ui shows: the last WindowSeconds of every route
+// and dependency, and the faults in effect
+type LiveStats struct {
+	WindowSeconds int          `json:"window_seconds"`
+	Routes        []LiveStat   `json:"routes"`
+	Dependencies  []LiveStat   `json:"dependencies"`
+	Faults        ActiveFaults `json:"faults"`
+}
+
+// LiveStat is one route or dependency over the window. Rate is per second
+// and ErrorRate the failed fraction.
+type LiveStat struct {
+	Name      string  `json:"name"`
+	Requests  int64   `json:"requests"`
+	Errors    int64   `json:"errors"`
+	Rate      float64 `json:"rate"`
+	ErrorRate float64 `json:"error_rate"`
+	P50Ms     float64 `json:"p50_ms"`
+	P95Ms     float64 `json:"p95_ms"`
+	P99Ms     float64 `json:"p99_ms"`
+}
+
+// ActiveFaults lists the faults the server injects right now
+type ActiveFaults struct {
+	Degrade       DegradeStatus      `json:"degrade"`
+	ErrorRate     float64            `json:"error_rate"`               // 0 unless SIMULATE_ERRORS
+	Network       map[string]float64 `json:"network,omitempty"`        // Kind -> rate
+	Throttled     []string           `json:"throttled,omitempty"`      // Routes of THROTTLE_ROUTES
+	Downstreams   map[string]string  `json:"downstreams,omitempty"`    // Step -> URL
+	ProxyUpstream string             `json:"proxy_upstream,omitempty"` // Set in proxy mode
+	Injected      []InjectedFault    `json:"injected,omitempty"`       // One-off faults from /admin/faults
+	Scenario      *ScenarioStatus    `json:"scenario,omitempty"`       // Running scenario from /admin/scenario
+}
+
+// Kinds of one-off faults
+const (
+	FaultFail  = "fail"
+	FaultDelay = "delay"
+)
+
+// FaultRequest injects a one-off fault into a step for DurationMs: every
+// call fails with Status (0 for the step's usual one), or is slowed down
+// by DelayMs, with probability Rate
+type FaultRequest struct {
+	Kind       string  `json:"kind"`
+	Step       string  `json:"step"`
+	Rate       float64 `json:"rate"`
+	Status     int     `json:"status,omitempty"`
+	DelayMs    int     `json:"delay_ms,omitempty"`
+	DurationMs int     `json:"duration_ms"`
+}
+
+// InjectedFault is an active one-off fault
+type InjectedFault struct {
+	ID string `json:"id"`
+	FaultRequest
+	Until time.Time `json:"until"`
+}
+
+// Scenario is a drill: its phases run one after the other, each injecting
+// its faults for as long as it lasts. With Repeat it starts over after the
+// last phase until it is stopped.
+type Scenario struct {
+	Name   string          `json:"name"`
+	Phases []ScenarioPhase `json:"phases"`
+	Repeat bool            `json:"repeat,omitempty"`
+}
+
+// ScenarioPhase injects Faults for DurationMs; their own duration_ms is
+// left out. A phase without faults is a pause.
+type ScenarioPhase struct {
+	Name       string         `json:"name,omitempty"`
+	DurationMs int            `json:"duration_ms"`
+	Faults     []FaultRequest `json:"faults,omitempty"`
+}
+
+// ScenarioStatus is where a running scenario is. Phase counts from 1 and
+// Faults are the ones its current phase injects until PhaseUntil.
+type ScenarioStatus struct {
+	Name       string          `json:"name"`
+	Phase      int             `json:"phase"`
+	Phases     int             `json:"phases"`
+	PhaseName  string          `json:"phase_name,omitempty"`
+	Started    time.Time       `json:"started"`
+	PhaseUntil time.Time       `json:"phase_until"`
+	Repeat     bool            `json:"repeat,omitempty"`
+	Faults     []InjectedFault `json:"faults,omitempty"`
+}
+
+// RecordedRequest is one finished API request as /admin/traffic lists it
+type RecordedRequest struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"` // Including the query
+	Route      string    `json:"route"`
+	Status     int       `json:"status"`
+	DurationMs float64   `json:"duration_ms"`
+	Seed       int64     `json:"seed,omitempty"` // Replays the request with X-Slow-Seed
 }
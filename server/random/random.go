@@ -0,0 +1,57 @@
+package random
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+)
+
+// Rand is a goroutine-safe random source. One seeded Rand drives a whole run
+// and hands out a derived seed to every request, so the same seed and the
+// same request order always produce the same delays and failures.
+type Rand struct {
+	mu   sync.Mutex
+	seed int64
+	r    *rand.Rand
+}
+
+// New returns a Rand seeded with seed
+func New(seed int64) *Rand {
+	return &Rand{seed: seed, r: rand.New(rand.NewSource(seed))}
+}
+
+// Seed returns the seed r was created with
+func (r *Rand) Seed() int64 {
+	return r.seed
+}
+
+func (r *Rand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Float64()
+}
+
+func (r *Rand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Intn(n)
+}
+
+func (r *Rand) Int63() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Int63()
+}
+
+type contextKey struct{}
+
+// WithRand attaches r as the random source of a single request
+func WithRand(ctx context.Context, r *Rand) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the request's random source, or nil
+func FromContext(ctx context.Context) *Rand {
+	r, _ := ctx.Value(contextKey{}).(*Rand)
+	return r
+}
@@ -0,0 +1,76 @@
+package slo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Unic-X/slow-server/config"
+)
+
+// burnAlert is one multiwindow, multi-burn-rate alert: it fires when the
+// error budget burns faster than Factor over both the long and the short
+// window
+type burnAlert struct {
+	Severity string
+	Factor   float64
+	Long     string
+	Short    string
+	For      string
+}
+
+// burnAlerts are the thresholds recommended for a 30 day budget: 2% of it
+// spent in an hour or 5% in six hours pages, 10% in a day or in three days
+// opens a ticket
+var burnAlerts = []burnAlert{
+	{"page", 14.4, "1h", "5m", "2m"},
+	{"page", 6, "6h", "30m", "15m"},
+	{"ticket", 3, "1d", "2h", "1h"},
+	{"ticket", 1, "3d", "6h", "3h"},
+}
+
+// Rules returns a Prometheus rule file with an error ratio recording rule
+// per window and the burn rate alerts of every SLO, matching the
+// slo_events_total and slo_good_events_total counters
+func Rules(slos []config.SLO) string {
+	var b strings.Builder
+	b.WriteString("groups:\n")
+	b.WriteString("  - name: slo-recording\n")
+	b.WriteString("    rules:\n")
+	for _, w := range Windows {
+		fmt.Fprintf(&b, "      - record: slo:sli_error:ratio_rate%s\n", w.Name)
+		fmt.Fprintf(&b, "        expr: 1 - (sum by (slo) (rate(slo_good_events_total[%s])) / sum by (slo) (rate(slo_events_total[%s])))\n",
+			w.Name, w.Name)
+	}
+
+	if len(slos) == 0 {
+		return b.String()
+	}
+	b.WriteString("  - name: slo-alerts\n")
+	b.WriteString("    rules:\n")
+	for _, slo := range slos {
+		budget := 1 - slo.Objective
+		for _, a := range burnAlerts {
+			threshold := formatFloat(a.Factor * budget)
+			fmt.Fprintf(&b, "      - alert: SLOErrorBudgetBurn\n")
+			fmt.Fprintf(&b, "        expr: |\n")
+			fmt.Fprintf(&b, "          slo:sli_error:ratio_rate%s{slo=%q} > %s\n", a.Long, slo.Name, threshold)
+			fmt.Fprintf(&b, "          and\n")
+			fmt.Fprintf(&b, "          slo:sli_error:ratio_rate%s{slo=%q} > %s\n", a.Short, slo.Name, threshold)
+			fmt.Fprintf(&b, "        for: %s\n", a.For)
+			fmt.Fprintf(&b, "        labels:\n")
+			fmt.Fprintf(&b, "          severity: %s\n", a.Severity)
+			fmt.Fprintf(&b, "          slo: %q\n", slo.Name)
+			fmt.Fprintf(&b, "        annotations:\n")
+			fmt.Fprintf(&b, "          summary: %q\n", fmt.Sprintf("%s is burning its error budget %sx too fast over %s",
+				slo.Name, formatFloat(a.Factor), a.Long))
+		}
+	}
+	return b.String()
+}
+
+// formatFloat prints v without the noise of float arithmetic, e.g. 1-0.995
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e9)/1e9, 'g', -1, 64)
+}
@@ -0,0 +1,168 @@
+package slo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/metrics"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Window is a period SLIs and burn rates are computed over
+type Window struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Windows are the short and long windows of the multiwindow, multi-burn-rate
+// alerts in Rules, shortest first
+var Windows = []Window{
+	{"5m", 5 * time.Minute},
+	{"30m", 30 * time.Minute},
+	{"1h", time.Hour},
+	{"2h", 2 * time.Hour},
+	{"6h", 6 * time.Hour},
+	{"1d", 24 * time.Hour},
+	{"3d", 72 * time.Hour},
+}
+
+// bucketSize is the resolution of the sliding windows
+const bucketSize = time.Minute
+
+// bucket counts the events of one minute
+type bucket struct {
+	minute int64
+	good   int64
+	total  int64
+}
+
+// objective is an SLO with a ring of per-minute buckets spanning the
+// longest window
+type objective struct {
+	config.SLO
+	buckets []bucket
+}
+
+// Tracker counts good and total events of every SLO and computes their
+// SLIs and burn rates over sliding windows. It is a prometheus.Collector
+// exporting them as slo_sli_ratio, slo_burn_rate and slo_objective.
+type Tracker struct {
+	mu         sync.Mutex
+	objectives []*objective
+	metrics    *metrics.Metrics
+	clk        clock.Clock
+}
+
+// NewTracker tracks slos, counting events into m
+func NewTracker(slos []config.SLO, m *metrics.Metrics, clk clock.Clock) *Tracker {
+	t := &Tracker{metrics: m, clk: clk}
+	n := int(Windows[len(Windows)-1].Duration / bucketSize)
+	for _, slo := range slos {
+		t.objectives = append(t.objectives, &objective{SLO: slo, buckets: make([]bucket, n)})
+	}
+	return t
+}
+
+// Empty reports whether there is nothing to track
+func (t *Tracker) Empty() bool {
+	return len(t.objectives) == 0
+}
+
+// Record counts a finished request towards every SLO it matches
+func (t *Tracker) Record(method, path string, status int, d time.Duration) {
+	minute := t.clk.Now().Unix() / int64(bucketSize/time.Second)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, o := range t.objectives {
+		if !o.Matches(method, path) {
+			continue
+		}
+		good := status < 500 && (o.Latency == 0 || d <= time.Duration(o.Latency)*time.Millisecond)
+
+		b := &o.buckets[minute%int64(len(o.buckets))]
+		if b.minute != minute {
+			*b = bucket{minute: minute}
+		}
+		b.total++
+		t.metrics.SLOEvents.WithLabelValues(o.Name).Inc()
+		if good {
+			b.good++
+			t.metrics.SLOGoodEvents.WithLabelValues(o.Name).Inc()
+		}
+	}
+}
+
+// Status reports every SLO over every window
+func (t *Tracker) Status() []models.SLOStatus {
+	minute := t.clk.Now().Unix() / int64(bucketSize/time.Second)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	statuses := []models.SLOStatus{}
+	for _, o := range t.objectives {
+		status := models.SLOStatus{
+			Name:      o.Name,
+			Method:    o.Method,
+			Path:      o.Path,
+			Objective: o.Objective,
+			LatencyMs: o.Latency,
+		}
+		for _, w := range Windows {
+			status.Windows = append(status.Windows, o.window(w, minute))
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// window sums the buckets of the last w, up to and including minute
+func (o *objective) window(w Window, minute int64) models.SLOWindow {
+	sw := models.SLOWindow{Window: w.Name}
+	since := minute - int64(w.Duration/bucketSize)
+	for _, b := range o.buckets {
+		if b.minute > since && b.minute <= minute {
+			sw.Good += b.good
+			sw.Total += b.total
+		}
+	}
+	if sw.Total > 0 {
+		sli := float64(sw.Good) / float64(sw.Total)
+		burn := (1 - sli) / (1 - o.Objective)
+		sw.SLI, sw.BurnRate = &sli, &burn
+	}
+	return sw
+}
+
+var (
+	sliDesc = prometheus.NewDesc("slo_sli_ratio",
+		"Fraction of good requests of an SLO over a sliding window", []string{"slo", "window"}, nil)
+	burnRateDesc = prometheus.NewDesc("slo_burn_rate",
+		"Rate an SLO's error budget is used up over a sliding window, 1 is on budget", []string{"slo", "window"}, nil)
+	objectiveDesc = prometheus.NewDesc("slo_objective",
+		"Target fraction of good requests of an SLO", []string{"slo"}, nil)
+)
+
+func (t *Tracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sliDesc
+	ch <- burnRateDesc
+	ch <- objectiveDesc
+}
+
+// Collect computes the windows at scrape time. Windows without requests
+// are left out rather than reported as perfect.
+func (t *Tracker) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range t.Status() {
+		ch <- prometheus.MustNewConstMetric(objectiveDesc, prometheus.GaugeValue, status.Objective, status.Name)
+		for _, w := range status.Windows {
+			if w.SLI == nil {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(sliDesc, prometheus.GaugeValue, *w.SLI, status.Name, w.Window)
+			ch <- prometheus.MustNewConstMetric(burnRateDesc, prometheus.GaugeValue, *w.BurnRate, status.Name, w.Window)
+		}
+	}
+}
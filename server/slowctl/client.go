@@ -0,0 +1,125 @@
+// Package slowctl drives the admin API of one or more running servers at
+// once, for the slowctl command.
+package slowctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dnsPrefix marks a target whose host stands for every address it resolves
+// to, e.g. the pods behind a headless Service
+const dnsPrefix = "dns+"
+
+// Client calls the admin API of every target
+type Client struct {
+	Targets []string
+	HTTP    *http.Client
+}
+
+// NewClient returns a client for targets, expanding dns+ targets to one
+// per address
+func NewClient(targets []string) (*Client, error) {
+	c := &Client{HTTP: &http.Client{Timeout: 10 * time.Second}}
+	for _, target := range targets {
+		expanded, err := expand(target)
+		if err != nil {
+			return nil, err
+		}
+		c.Targets = append(c.Targets, expanded...)
+	}
+	if len(c.Targets) == 0 {
+		return nil, fmt.Errorf("no targets")
+	}
+	return c, nil
+}
+
+func expand(target string) ([]string, error) {
+	raw, resolve := strings.CutPrefix(target, dnsPrefix)
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("target %q is not an http(s) URL", target)
+	}
+	base := strings.TrimSuffix(u.String(), "/")
+	if !resolve {
+		return []string{base}, nil
+	}
+
+	addrs, err := net.LookupHost(u.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", u.Hostname(), err)
+	}
+	var targets []string
+	for _, addr := range addrs {
+		host := addr
+		if port := u.Port(); port != "" {
+			host = net.JoinHostPort(addr, port)
+		} else if strings.Contains(addr, ":") {
+			host = "[" + addr + "]"
+		}
+		each := *u
+		each.Host = host
+		targets = append(targets, strings.TrimSuffix(each.String(), "/"))
+	}
+	return targets, nil
+}
+
+// Result is the outcome of a call on one target
+type Result struct {
+	Target string
+	Body   []byte
+	Err    error
+}
+
+// Do sends the same request to every target at once and returns the
+// results in target order. Responses other than 2xx are errors.
+func (c *Client) Do(method, path string, body interface{}) []Result {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+
+	results := make([]Result, len(c.Targets))
+	var wg sync.WaitGroup
+	for i, target := range c.Targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.do(target, method, path, payload)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (c *Client) do(target, method, path string, payload []byte) Result {
+	result := Result{Target: target}
+	req, err := http.NewRequest(method, target+path, bytes.NewReader(payload))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+	result.Body, result.Err = io.ReadAll(resp.Body)
+	if result.Err == nil && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		result.Err = fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(result.Body)))
+	}
+	return result
+}
@@ -0,0 +1,553 @@
+package slowctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Unic-X/slow-server/faults"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/google/uuid"
+)
+
+// TargetsEnv lists default targets, comma separated
+const TargetsEnv = "SLOWCTL_TARGETS"
+
+const usage = `Usage: slowctl [-t URL]... COMMAND
+
+Targets are admin listeners, by default $SLOWCTL_TARGETS or
+http://localhost:6060. A target starting with dns+ stands for every
+address its host resolves to, e.g. dns+http://slow-server-pods:6060 for
+every replica.
+
+Commands:
+  config                      Show the config
+  config FIELD=VALUE...       Patch config fields, VALUE as JSON or a string
+  fault fail 50% of db for 2m [with 503]
+  fault delay db by 2s for 1m [at 25%]
+                              Inject a one-off fault
+  faults                      List the active one-off faults
+  faults clear [ID]           Remove one or all one-off faults
+  scenario                    Show the running scenario
+  scenario start NAME [-repeat] PHASE...
+                              Run phases one after the other, each
+                              "DURATION [FAULT [+ FAULT]...]", e.g.
+                              "1m fail 20% of db" "2m fail db + delay external by 1s"
+  scenario stop               Stop the running scenario
+  traffic [-limit N]          Dump the recorded requests as JSON lines
+  stats                       Show rates, errors and percentiles once
+  watch [-interval 2s] [-count N]
+                              Keep showing them
+`
+
+// Run runs the slowctl command line args, writing to out
+func Run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("slowctl", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	var targets []string
+	flags.Func("t", "target URL, repeatable", func(s string) error {
+		targets = append(targets, s)
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%v\n\n%s", err, usage)
+	}
+	if len(targets) == 0 {
+		targets = splitTargets(os.Getenv(TargetsEnv))
+	}
+	if len(targets) == 0 {
+		targets = []string{"http://localhost:6060"}
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	c, err := NewClient(targets)
+	if err != nil {
+		return err
+	}
+
+	switch cmd, rest := args[0], args[1:]; cmd {
+	case "config":
+		return c.config(rest, out)
+	case "fault":
+		return c.fault(rest, out)
+	case "faults":
+		return c.faults(rest, out)
+	case "scenario":
+		return c.scenario(rest, out)
+	case "traffic":
+		return c.traffic(rest, out)
+	case "stats":
+		return c.watch([]string{"-count", "1"}, out)
+	case "watch":
+		return c.watch(rest, out)
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", cmd, usage)
+	}
+}
+
+func splitTargets(s string) []string {
+	var targets []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// report writes every result under its target, the body through format,
+// and fails if any target did
+func (c *Client) report(out io.Writer, results []Result, format func(io.Writer, []byte) error) error {
+	failed := 0
+	for _, result := range results {
+		if len(c.Targets) > 1 {
+			fmt.Fprintf(out, "== %s\n", result.Target)
+		}
+		err := result.Err
+		if err == nil {
+			err = format(out, result.Body)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d targets failed", failed, len(results))
+	}
+	return nil
+}
+
+func (c *Client) config(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return c.report(out, c.Do(http.MethodGet, "/admin/config", nil), printJSON)
+	}
+
+	patch := map[string]json.RawMessage{}
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("expected FIELD=VALUE, got %q", arg)
+		}
+		if !json.Valid([]byte(value)) {
+			quoted, _ := json.Marshal(value)
+			value = string(quoted)
+		}
+		patch[name] = json.RawMessage(value)
+	}
+	return c.report(out, c.Do(http.MethodPatch, "/admin/config", patch), func(w io.Writer, body []byte) error {
+		var cfg map[string]json.RawMessage
+		if err := json.Unmarshal(body, &cfg); err != nil {
+			return err
+		}
+		for _, arg := range args {
+			name, _, _ := strings.Cut(arg, "=")
+			fmt.Fprintf(w, "%s = %s\n", name, cfg[name])
+		}
+		return nil
+	})
+}
+
+// fault injects the fault under one random ID on every target, so that
+// faults clear ID removes it from all of them
+func (c *Client) fault(args []string, out io.Writer) error {
+	request, err := ParseFault(args)
+	if err != nil {
+		return err
+	}
+	named := models.InjectedFault{ID: uuid.NewString()[:8], FaultRequest: request}
+	return c.report(out, c.Do(http.MethodPost, "/admin/faults", named), func(w io.Writer, body []byte) error {
+		var fault models.InjectedFault
+		if err := json.Unmarshal(body, &fault); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Injected fault %s: %s\n", fault.ID, describeFault(fault))
+		return nil
+	})
+}
+
+func (c *Client) faults(args []string, out io.Writer) error {
+	results := []Result{}
+	switch {
+	case len(args) == 0:
+		results = c.Do(http.MethodGet, "/admin/faults", nil)
+	case args[0] == "clear" && len(args) <= 2:
+		path := "/admin/faults"
+		if len(args) == 2 {
+			path += "?id=" + url.QueryEscape(args[1])
+		}
+		results = c.Do(http.MethodDelete, path, nil)
+	default:
+		return fmt.Errorf("expected faults or faults clear [ID]")
+	}
+
+	return c.report(out, results, func(w io.Writer, body []byte) error {
+		var active []models.InjectedFault
+		if err := json.Unmarshal(body, &active); err != nil {
+			return err
+		}
+		if len(active) == 0 {
+			fmt.Fprintln(w, "No one-off faults")
+		}
+		for _, fault := range active {
+			fmt.Fprintf(w, "%s: %s\n", fault.ID, describeFault(fault))
+		}
+		return nil
+	})
+}
+
+func (c *Client) scenario(args []string, out io.Writer) error {
+	results := []Result{}
+	switch {
+	case len(args) == 0:
+		results = c.Do(http.MethodGet, "/admin/scenario", nil)
+	case args[0] == "start" && len(args) > 1:
+		scenario, err := ParseScenario(args[1:])
+		if err != nil {
+			return err
+		}
+		results = c.Do(http.MethodPost, "/admin/scenario", scenario)
+	case args[0] == "stop" && len(args) == 1:
+		results = c.Do(http.MethodDelete, "/admin/scenario", nil)
+	default:
+		return fmt.Errorf("expected scenario, scenario start NAME PHASE... or scenario stop")
+	}
+
+	return c.report(out, results, func(w io.Writer, body []byte) error {
+		var status *models.ScenarioStatus
+		if err := json.Unmarshal(body, &status); err != nil {
+			return err
+		}
+		if status == nil {
+			fmt.Fprintln(w, "No scenario running")
+			return nil
+		}
+		fmt.Fprintf(w, "Scenario %s: %s\n", status.Name, describePhase(status))
+		for _, fault := range status.Faults {
+			fmt.Fprintf(w, "%s: %s\n", fault.ID, describeFault(fault))
+		}
+		return nil
+	})
+}
+
+// describePhase tells where a scenario is, e.g. "phase 2 of 3 until 15:04:05"
+func describePhase(status *models.ScenarioStatus) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "phase %d of %d", status.Phase, status.Phases)
+	if status.PhaseName != "" {
+		fmt.Fprintf(&b, " (%s)", status.PhaseName)
+	}
+	fmt.Fprintf(&b, " until %s", status.PhaseUntil.Local().Format(time.TimeOnly))
+	if status.Repeat {
+		b.WriteString(", repeating")
+	}
+	return b.String()
+}
+
+func (c *Client) traffic(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("traffic", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	limit := flags.Int("limit", 0, "most recent requests to dump, 0 for all")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	path := "/admin/traffic"
+	if *limit > 0 {
+		path += "?limit=" + strconv.Itoa(*limit)
+	}
+	return c.report(out, c.Do(http.MethodGet, path, nil), func(w io.Writer, body []byte) error {
+		var recorded []json.RawMessage
+		if err := json.Unmarshal(body, &recorded); err != nil {
+			return err
+		}
+		for _, request := range recorded {
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, request); err != nil {
+				return err
+			}
+			buf.WriteByte('\n')
+			w.Write(buf.Bytes())
+		}
+		return nil
+	})
+}
+
+// describeFault reads like the fault command that injected it
+func describeFault(f models.InjectedFault) string {
+	var b strings.Builder
+	rate := strconv.FormatFloat(math.Round(f.Rate*1000)/10, 'f', -1, 64) + "%"
+	switch f.Kind {
+	case models.FaultFail:
+		fmt.Fprintf(&b, "fail %s of %s", rate, f.Step)
+		if f.Status != 0 {
+			fmt.Fprintf(&b, " with %d", f.Status)
+		}
+	default:
+		fmt.Fprintf(&b, "delay %s of %s by %v", rate, f.Step, time.Duration(f.DelayMs)*time.Millisecond)
+	}
+	fmt.Fprintf(&b, " until %s", f.Until.Local().Format(time.TimeOnly))
+	return b.String()
+}
+
+func (c *Client) watch(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	interval := flags.Duration("interval", 2*time.Second, "time between refreshes")
+	count := flags.Int("count", 0, "refreshes before exiting, 0 for no limit")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	redraw := *count != 1 && isTerminal(out)
+
+	for i := 0; *count == 0 || i < *count; i++ {
+		if i > 0 {
+			time.Sleep(*interval)
+		}
+		var buf bytes.Buffer
+		err := c.report(&buf, c.Do(http.MethodGet, "/admin/stats", nil), printStats)
+		if redraw {
+			fmt.Fprint(out, "\033[H\033[2J")
+		}
+		out.Write(buf.Bytes())
+		if err != nil && *count != 0 {
+			return err
+		}
+	}
+	return nil
+}
+
+func printStats(w io.Writer, body []byte) error {
+	var stats models.LiveStats
+	if err := json.Unmarshal(body, &stats); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Last %ds\tReq/s\tErrors\tp50\tp95\tp99\t\n", stats.WindowSeconds)
+	for _, group := range [][]models.LiveStat{stats.Routes, stats.Dependencies} {
+		for _, s := range group {
+			fmt.Fprintf(tw, "%s\t%.2f\t%.1f%%\t%.1fms\t%.1fms\t%.1fms\t\n",
+				s.Name, s.Rate, s.ErrorRate*100, s.P50Ms, s.P95Ms, s.P99Ms)
+		}
+		fmt.Fprintln(tw, "\t\t\t\t\t\t")
+	}
+	tw.Flush()
+
+	f := stats.Faults
+	var active []string
+	for _, mode := range []struct {
+		name string
+		on   bool
+	}{{"memory", f.Degrade.Memory}, {"goroutines", f.Degrade.Goroutines}, {"cpu", f.Degrade.CPU}} {
+		if mode.on {
+			active = append(active, "degrade "+mode.name)
+		}
+	}
+	if f.ErrorRate > 0 {
+		active = append(active, fmt.Sprintf("error rate %.1f%%", f.ErrorRate*100))
+	}
+	for _, kind := range faults.NetworkFaults {
+		if rate, ok := f.Network[kind]; ok {
+			active = append(active, fmt.Sprintf("network %s %.1f%%", kind, rate*100))
+		}
+	}
+	for _, fault := range f.Injected {
+		active = append(active, describeFault(fault))
+	}
+	if f.Scenario != nil {
+		active = append(active, fmt.Sprintf("scenario %s %s", f.Scenario.Name, describePhase(f.Scenario)))
+		for _, fault := range f.Scenario.Faults {
+			active = append(active, describeFault(fault))
+		}
+	}
+	if len(active) == 0 {
+		active = append(active, "none")
+	}
+	fmt.Fprintf(w, "Faults: %s\n", strings.Join(active, ", "))
+	return nil
+}
+
+func printJSON(w io.Writer, body []byte) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, body, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ParseFault reads a one-off fault such as "fail 50% of db for 2m",
+// "fail external for 30s with 503" or "delay db by 2s for 1m at 25%".
+// Without a percentage every call is affected.
+func ParseFault(args []string) (models.FaultRequest, error) {
+	if len(args) == 0 {
+		return models.FaultRequest{}, errors.New("expected fail or delay")
+	}
+	request := models.FaultRequest{Kind: args[0], Rate: 1}
+	if request.Kind != models.FaultFail && request.Kind != models.FaultDelay {
+		return request, fmt.Errorf("unknown fault %q, expected fail or delay", request.Kind)
+	}
+
+	words := args[1:]
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if strings.HasSuffix(word, "%") {
+			rate, err := parseRate(word)
+			if err != nil {
+				return request, err
+			}
+			request.Rate = rate
+			continue
+		}
+
+		switch word {
+		case "of", "on", "for", "by", "with", "at":
+		default:
+			if request.Step != "" {
+				return request, fmt.Errorf("unexpected %q", word)
+			}
+			request.Step = word
+			continue
+		}
+		if i+1 == len(words) {
+			return request, fmt.Errorf("%q needs a value", word)
+		}
+		i++
+		value := words[i]
+
+		switch word {
+		case "of", "on":
+			request.Step = value
+		case "for", "by":
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return request, fmt.Errorf("invalid duration %q", value)
+			}
+			if word == "for" {
+				request.DurationMs = int(d.Milliseconds())
+			} else {
+				request.DelayMs = int(d.Milliseconds())
+			}
+		case "with":
+			status, err := strconv.Atoi(value)
+			if err != nil {
+				return request, fmt.Errorf("invalid status %q", value)
+			}
+			request.Status = status
+		case "at":
+			rate, err := parseRate(value)
+			if err != nil {
+				return request, err
+			}
+			request.Rate = rate
+		}
+	}
+
+	switch {
+	case request.Step == "":
+		return request, errors.New("missing the step, e.g. of db")
+	case request.DurationMs == 0:
+		return request, errors.New("missing the duration, e.g. for 2m")
+	case request.Kind == models.FaultDelay && request.DelayMs == 0:
+		return request, errors.New("missing the delay, e.g. by 2s")
+	}
+	return request, nil
+}
+
+// ParseScenario reads a scenario such as
+// "drill -repeat 1m fail 20% of db 2m fail db + delay external by 1s",
+// given as the name, an optional -repeat and one arg per phase: its
+// duration and the faults it injects, joined by +, written like ParseFault
+// without the duration
+func ParseScenario(args []string) (models.Scenario, error) {
+	if len(args) == 0 {
+		return models.Scenario{}, errors.New("expected the scenario name")
+	}
+	scenario := models.Scenario{Name: args[0]}
+	phases := args[1:]
+	if len(phases) > 0 && phases[0] == "-repeat" {
+		scenario.Repeat = true
+		phases = phases[1:]
+	}
+	if len(phases) == 0 {
+		return scenario, errors.New("expected at least one phase, e.g. \"1m fail 20% of db\"")
+	}
+
+	for i, arg := range phases {
+		words := strings.Fields(arg)
+		if len(words) == 0 {
+			return scenario, fmt.Errorf("phase %d is empty", i+1)
+		}
+		d, err := time.ParseDuration(words[0])
+		if err != nil || d <= 0 {
+			return scenario, fmt.Errorf("phase %d: invalid duration %q", i+1, words[0])
+		}
+		phase := models.ScenarioPhase{DurationMs: int(d.Milliseconds())}
+
+		for _, fault := range splitFaults(words[1:]) {
+			switch {
+			case len(fault) == 0:
+				return scenario, fmt.Errorf("phase %d: missing a fault around +", i+1)
+			case slices.Contains(fault, "for"):
+				return scenario, fmt.Errorf("phase %d: faults last as long as their phase, drop \"for\"", i+1)
+			}
+			request, err := ParseFault(append(fault, "for", words[0]))
+			if err != nil {
+				return scenario, fmt.Errorf("phase %d: %v", i+1, err)
+			}
+			phase.Faults = append(phase.Faults, request)
+		}
+		scenario.Phases = append(scenario.Phases, phase)
+	}
+	return scenario, nil
+}
+
+// splitFaults splits the faults of a phase at every +
+func splitFaults(words []string) [][]string {
+	if len(words) == 0 {
+		return nil
+	}
+	groups := [][]string{{}}
+	for _, word := range words {
+		if word == "+" {
+			groups = append(groups, []string{})
+			continue
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], word)
+	}
+	return groups
+}
+
+func parseRate(s string) (float64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || !strings.HasSuffix(s, "%") || percent <= 0 || percent > 100 {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+	return percent / 100, nil
+}
@@ -0,0 +1,5 @@
+// Package slowpb holds the gRPC API of the slow server, generated from
+// slowserver.proto
+package slowpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative slowserver.proto
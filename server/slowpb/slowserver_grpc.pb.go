@@ -0,0 +1,249 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: slowserver.proto
+
+package slowpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SlowServer_GetData_FullMethodName           = "/slowserver.v1.SlowServer/GetData"
+	SlowServer_GetUsers_FullMethodName          = "/slowserver.v1.SlowServer/GetUsers"
+	SlowServer_ProcessData_FullMethodName       = "/slowserver.v1.SlowServer/ProcessData"
+	SlowServer_StreamProcessData_FullMethodName = "/slowserver.v1.SlowServer/StreamProcessData"
+)
+
+// SlowServerClient is the client API for SlowServer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SlowServer mirrors the REST API. Every call runs the same simulated
+// pipeline, with the same delays, failures and caches.
+type SlowServerClient interface {
+	GetData(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*DataResponse, error)
+	GetUsers(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*UsersResponse, error)
+	ProcessData(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*ProcessResponse, error)
+	// StreamProcessData runs ProcessData and sends every item's result as
+	// soon as the item is done.
+	StreamProcessData(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ItemResult], error)
+}
+
+type slowServerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSlowServerClient(cc grpc.ClientConnInterface) SlowServerClient {
+	return &slowServerClient{cc}
+}
+
+func (c *slowServerClient) GetData(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*DataResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DataResponse)
+	err := c.cc.Invoke(ctx, SlowServer_GetData_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slowServerClient) GetUsers(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*UsersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UsersResponse)
+	err := c.cc.Invoke(ctx, SlowServer_GetUsers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slowServerClient) ProcessData(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*ProcessResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProcessResponse)
+	err := c.cc.Invoke(ctx, SlowServer_ProcessData_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *slowServerClient) StreamProcessData(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ItemResult], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SlowServer_ServiceDesc.Streams[0], SlowServer_StreamProcessData_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ProcessRequest, ItemResult]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SlowServer_StreamProcessDataClient = grpc.ServerStreamingClient[ItemResult]
+
+// SlowServerServer is the server API for SlowServer service.
+// All implementations must embed UnimplementedSlowServerServer
+// for forward compatibility.
+//
+// SlowServer mirrors the REST API. Every call runs the same simulated
+// pipeline, with the same delays, failures and caches.
+type SlowServerServer interface {
+	GetData(context.Context, *ListRequest) (*DataResponse, error)
+	GetUsers(context.Context, *ListRequest) (*UsersResponse, error)
+	ProcessData(context.Context, *ProcessRequest) (*ProcessResponse, error)
+	// StreamProcessData runs ProcessData and sends every item's result as
+	// soon as the item is done.
+	StreamProcessData(*ProcessRequest, grpc.ServerStreamingServer[ItemResult]) error
+	mustEmbedUnimplementedSlowServerServer()
+}
+
+// UnimplementedSlowServerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSlowServerServer struct{}
+
+func (UnimplementedSlowServerServer) GetData(context.Context, *ListRequest) (*DataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetData not implemented")
+}
+func (UnimplementedSlowServerServer) GetUsers(context.Context, *ListRequest) (*UsersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUsers not implemented")
+}
+func (UnimplementedSlowServerServer) ProcessData(context.Context, *ProcessRequest) (*ProcessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ProcessData not implemented")
+}
+func (UnimplementedSlowServerServer) StreamProcessData(*ProcessRequest, grpc.ServerStreamingServer[ItemResult]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamProcessData not implemented")
+}
+func (UnimplementedSlowServerServer) mustEmbedUnimplementedSlowServerServer() {}
+func (UnimplementedSlowServerServer) testEmbeddedByValue()                    {}
+
+// UnsafeSlowServerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SlowServerServer will
+// result in compilation errors.
+type UnsafeSlowServerServer interface {
+	mustEmbedUnimplementedSlowServerServer()
+}
+
+func RegisterSlowServerServer(s grpc.ServiceRegistrar, srv SlowServerServer) {
+	// If the following call pancis, it indicates UnimplementedSlowServerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SlowServer_ServiceDesc, srv)
+}
+
+func _SlowServer_GetData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlowServerServer).GetData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlowServer_GetData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlowServerServer).GetData(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlowServer_GetUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlowServerServer).GetUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlowServer_GetUsers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlowServerServer).GetUsers(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlowServer_ProcessData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProcessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SlowServerServer).ProcessData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SlowServer_ProcessData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SlowServerServer).ProcessData(ctx, req.(*ProcessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SlowServer_StreamProcessData_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ProcessRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SlowServerServer).StreamProcessData(m, &grpc.GenericServerStream[ProcessRequest, ItemResult]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SlowServer_StreamProcessDataServer = grpc.ServerStreamingServer[ItemResult]
+
+// SlowServer_ServiceDesc is the grpc.ServiceDesc for SlowServer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SlowServer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "slowserver.v1.SlowServer",
+	HandlerType: (*SlowServerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetData",
+			Handler:    _SlowServer_GetData_Handler,
+		},
+		{
+			MethodName: "GetUsers",
+			Handler:    _SlowServer_GetUsers_Handler,
+		},
+		{
+			MethodName: "ProcessData",
+			Handler:    _SlowServer_ProcessData_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamProcessData",
+			Handler:       _SlowServer_StreamProcessData_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "slowserver.proto",
+}
@@ -0,0 +1,47 @@
+package slowserver
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// runtimeRegistry collects process and Go runtime metrics, including the
+// runtime/metrics GC and stop-the-world pause histograms
+func runtimeRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(
+			collectors.MetricsGC,
+			collectors.GoRuntimeMetricsRule{Matcher: regexp.MustCompile(`^/sched/pauses/.*`)},
+		)),
+	)
+	return reg
+}
+
+// newAdminHandler serves what must stay off the public port: pprof, the
+// server's metrics together with the runtime ones, a state dump, the
+// recorded traffic, and the admin API changing the config, faults,
+// scenarios, degradation modes and caches
+func (s *Server) newAdminHandler() http.Handler {
+	router := http.NewServeMux()
+
+	router.HandleFunc("/debug/pprof/", pprof.Index)
+	router.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	router.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	router.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	router.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	gatherers := prometheus.Gatherers{s.registry, runtimeRegistry()}
+	router.Handle("/metrics", promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}))
+
+	router.HandleFunc("/debug/vars", s.api.DebugVarsHandler)
+	s.api.AdminRoutes(router)
+
+	return router
+}
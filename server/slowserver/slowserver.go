@@ -0,0 +1,342 @@
+// Package slowserver embeds the slow server simulator in another Go
+// program, typically as a stand-in for a slow dependency in integration
+// tests. Every Server owns its config, data set, random source, clock, job
+// workers and Prometheus registry, so several can run in one process.
+//
+// Diagnostics (pprof, runtime metrics and a state dump) and the admin API
+// are served by a separate handler, optionally on its own admin listener.
+package slowserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/Unic-X/slow-server/api"
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/metrics"
+	"github.com/Unic-X/slow-server/middleware"
+	"github.com/Unic-X/slow-server/random"
+	"github.com/charmbracelet/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+)
+
+// Server is one embedded simulator
+type Server struct {
+	addr     string
+	api      *api.Server
+	registry *prometheus.Registry
+	handler  http.Handler
+
+	adminAddr    string
+	adminHandler http.Handler
+
+	grpcAddr string
+	grpc     *grpc.Server
+
+	mu            sync.Mutex
+	http          *http.Server
+	listener      net.Listener
+	adminHTTP     *http.Server
+	adminListener net.Listener
+	grpcListener  net.Listener
+}
+
+type options struct {
+	cfg      *config.Config
+	addr     string
+	clk      clock.Clock
+	seed     *int64
+	registry *prometheus.Registry
+
+	adminAddr string
+	grpcAddr  string
+}
+
+// Option customizes a Server
+type Option func(*options)
+
+// WithConfig runs the server with cfg instead of config.Default(). The
+// server reads cfg on every request, so later changes to it apply until it
+// is patched on /admin/config, which swaps in a copy.
+func WithConfig(cfg *config.Config) Option {
+	return func(o *options) {
+		o.cfg = cfg
+	}
+}
+
+// WithAddr sets the address Start listens on, ":<port>" of the config by default
+func WithAddr(addr string) Option {
+	return func(o *options) {
+		o.addr = addr
+	}
+}
+
+// WithAdminAddr makes Start also listen on addr for the admin handler.
+// Without it the diagnostics are only reachable through AdminHandler.
+func WithAdminAddr(addr string) Option {
+	return func(o *options) {
+		o.adminAddr = addr
+	}
+}
+
+// WithGRPCAddr makes Start also serve the gRPC API on addr. Without it the
+// gRPC service is only reachable through GRPCServer.
+func WithGRPCAddr(addr string) Option {
+	return func(o *options) {
+		o.grpcAddr = addr
+	}
+}
+
+// WithClock makes the server sleep and time requests on c, e.g. a
+// clock.Fake so simulated delays take no real time
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clk = c
+	}
+}
+
+// WithSeed overrides the seed of the config, making the simulated delays
+// and failures of a run reproducible
+func WithSeed(seed int64) Option {
+	return func(o *options) {
+		o.seed = &seed
+	}
+}
+
+// WithRegistry registers the server's metrics on reg instead of a fresh
+// registry, e.g. to scrape them together with the embedding program's own
+func WithRegistry(reg *prometheus.Registry) Option {
+	return func(o *options) {
+		o.registry = reg
+	}
+}
+
+// New builds a server. It does not listen until Start is called; Handler
+// can be mounted on an existing server instead.
+func New(opts ...Option) *Server {
+	o := options{clk: clock.Real}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	cfg := o.cfg
+	if cfg == nil {
+		cfg = config.Default()
+	}
+	seed := cfg.Seed
+	if o.seed != nil {
+		seed = *o.seed
+	}
+	addr := o.addr
+	if addr == "" {
+		addr = ":" + cfg.PortString()
+	}
+
+	// Every request derives its own seed from the run-wide one
+	seeds := random.New(seed)
+	registry := o.registry
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+	m := metrics.New(registry)
+	s := &Server{
+		addr:     addr,
+		api:      api.NewServer(cfg, api.WithClock(o.clk), api.WithRand(seeds), api.WithMetrics(m)),
+		registry: registry,
+
+		adminAddr: o.adminAddr,
+		grpcAddr:  o.grpcAddr,
+	}
+	registry.MustRegister(s.api.SLOTracker())
+	s.adminHandler = s.newAdminHandler()
+
+	s.grpc = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(m.GRPC.UnaryServerInterceptor(), middleware.GRPCUnaryInterceptor(seeds, s.api.Config(), m, o.clk)),
+		grpc.ChainStreamInterceptor(m.GRPC.StreamServerInterceptor(), middleware.GRPCStreamInterceptor(seeds, s.api.Config(), m, o.clk)),
+	)
+	s.api.RegisterGRPC(s.grpc)
+	m.GRPC.InitializeMetrics(s.grpc)
+
+	router := http.NewServeMux()
+	s.api.Routes(router)
+	router.Handle("/metrics", promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}))
+
+	current := s.api.Config()
+	handler := middleware.ApplyNetworkFaultsMiddleware(router, current, m)
+	handler = middleware.ApplySeedMiddleware(handler, seeds)
+	handler = middleware.ApplyFaultHeadersMiddleware(handler, current, m)
+	handler = middleware.ApplyThrottleMiddleware(handler, current, m, router, o.clk)
+	handler = middleware.ApplyMetricsMiddleware(handler, current, m, router, o.clk)
+	handler = middleware.ApplySLOMiddleware(handler, s.api.SLOTracker(), o.clk)
+	handler = middleware.ApplyLiveStatsMiddleware(handler, s.api.LiveStats(), router, o.clk)
+	handler = middleware.ApplyTrafficMiddleware(handler, s.api.Traffic(), router, o.clk)
+	handler = middleware.ApplyTraceMiddleware(handler)
+	handler = middleware.ApplyLoggingMiddleware(handler, o.clk)
+	s.handler = middleware.ApplyConfigMiddleware(handler, current)
+
+	return s
+}
+
+// Handler serves the API, SLO report and /metrics endpoints with all middleware applied
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// AdminHandler serves /debug/pprof/, /debug/vars, /metrics including the
+// process and Go runtime metrics, and the /admin/ API. Keep it off public
+// networks.
+func (s *Server) AdminHandler() http.Handler {
+	return s.adminHandler
+}
+
+// Metrics returns the collectors the server records into
+func (s *Server) Metrics() *metrics.Metrics {
+	return s.api.Metrics()
+}
+
+// GRPCServer serves the slowpb.SlowServer API, e.g. to run it on a listener
+// of the embedding program's own
+func (s *Server) GRPCServer() *grpc.Server {
+	return s.grpc
+}
+
+// Registry is the server's own Prometheus registry, as served on /metrics
+func (s *Server) Registry() *prometheus.Registry {
+	return s.registry
+}
+
+// Config returns the config the server runs with: the one it was built
+// with, or the latest copy patched on /admin/config
+func (s *Server) Config() *config.Config {
+	return s.api.Config().Load()
+}
+
+// Start listens on the configured address, and the admin and gRPC
+// addresses if they were given, and serves in the background
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		return errors.New("slowserver: already started")
+	}
+
+	listener, srv, err := serve("server", s.addr, s.handler)
+	if err != nil {
+		return err
+	}
+	// Ending the open event streams lets Shutdown finish without waiting on clients
+	srv.RegisterOnShutdown(s.api.Close)
+
+	if s.adminAddr != "" {
+		s.adminListener, s.adminHTTP, err = serve("admin server", s.adminAddr, s.adminHandler)
+		if err != nil {
+			srv.Close()
+			return err
+		}
+	}
+
+	if s.grpcAddr != "" {
+		s.grpcListener, err = net.Listen("tcp", s.grpcAddr)
+		if err != nil {
+			srv.Close()
+			if s.adminHTTP != nil {
+				s.adminHTTP.Close()
+			}
+			return err
+		}
+		log.Infof("Starting gRPC server on %s", s.grpcListener.Addr())
+		go func(l net.Listener) {
+			if err := s.grpc.Serve(l); err != nil {
+				log.Errorf("The gRPC server stopped: %v", err)
+			}
+		}(s.grpcListener)
+	}
+	s.listener, s.http = listener, srv
+	return nil
+}
+
+func serve(name, addr string, handler http.Handler) (net.Listener, *http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv := &http.Server{Handler: handler}
+
+	log.Infof("Starting %s on %s", name, listener.Addr())
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("The %s stopped: %v", name, err)
+		}
+	}()
+	return listener, srv, nil
+}
+
+// Addr is the address the server listens on once started, useful with port 0
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return s.addr
+	}
+	return s.listener.Addr().String()
+}
+
+// AdminAddr is the address the admin listener listens on once started
+func (s *Server) AdminAddr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.adminListener == nil {
+		return s.adminAddr
+	}
+	return s.adminListener.Addr().String()
+}
+
+// GRPCAddr is the address the gRPC listener listens on once started
+func (s *Server) GRPCAddr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.grpcListener == nil {
+		return s.grpcAddr
+	}
+	return s.grpcListener.Addr().String()
+}
+
+// Shutdown stops accepting requests, waits for in-flight ones until ctx is
+// done and stops the job workers
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	srv, adminSrv := s.http, s.adminHTTP
+	s.mu.Unlock()
+
+	defer s.api.Close()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpc.Stop()
+	}
+
+	if adminSrv != nil {
+		if err := adminSrv.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
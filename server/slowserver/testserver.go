@@ -0,0 +1,33 @@
+package slowserver
+
+import (
+	"net/http/httptest"
+)
+
+// TestServer is a Server listening on a random loopback port, in the style
+// of httptest.Server
+type TestServer struct {
+	URL      string // Base URL of the form http://127.0.0.1:port
+	AdminURL string // Base URL of the admin handler, on a port of its own
+	Server   *Server
+
+	ts      *httptest.Server
+	adminTS *httptest.Server
+}
+
+// NewTestServer starts a Server built from opts on a random port, and its
+// admin handler on another. Callers should Close it when done, e.g. with
+// t.Cleanup(ts.Close).
+func NewTestServer(opts ...Option) *TestServer {
+	s := New(opts...)
+	ts := httptest.NewServer(s.Handler())
+	adminTS := httptest.NewServer(s.AdminHandler())
+	return &TestServer{URL: ts.URL, AdminURL: adminTS.URL, Server: s, ts: ts, adminTS: adminTS}
+}
+
+// Close shuts the server down and blocks until all requests have finished
+func (t *TestServer) Close() {
+	t.Server.api.Close() // Ends open event streams first, Close waits for them
+	t.ts.Close()
+	t.adminTS.Close()
+}
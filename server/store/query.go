@@ -0,0 +1,252 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Unic-X/slow-server/models"
+)
+
+// Query describes one page of a list request. Either Offset or Cursor is
+// used for positioning; a Cursor always wins since it seeks straight to
+// the last row of the previous page instead of scanning past Offset rows.
+type Query struct {
+	Filters map[string]string // field -> filter value
+	Sort    string            // field to order by, "-field" for descending
+	Offset  int
+	Cursor  string
+	Limit   int
+}
+
+// Page is the result of a Query. Scanned counts the rows the simulated
+// database had to examine to build the page, which drives its cost.
+type Page[T any] struct {
+	Items      []T
+	Total      int
+	Scanned    int
+	NextCursor string
+}
+
+// collection describes how a record type can be sorted and filtered
+type collection[T any] struct {
+	id      func(T) int
+	sorts   map[string]func(T) interface{} // values are float64 or string
+	filters map[string]func(value string) (func(T) bool, error)
+}
+
+// cursor is the position after the last row of a page. Sort is the order
+// the page was built in, since Key is meaningless under any other.
+type cursor struct {
+	Sort string      `json:"s"`
+	Key  interface{} `json:"k"`
+	ID   int         `json:"id"`
+}
+
+var userCollection = collection[models.User]{
+	id: func(u models.User) int { return u.ID },
+	sorts: map[string]func(models.User) interface{}{
+		"id":    func(u models.User) interface{} { return float64(u.ID) },
+		"name":  func(u models.User) interface{} { return u.Name },
+		"email": func(u models.User) interface{} { return u.Email },
+	},
+	filters: map[string]func(string) (func(models.User) bool, error){
+		"name": func(v string) (func(models.User) bool, error) {
+			return func(u models.User) bool { return containsFold(u.Name, v) }, nil
+		},
+		"email": func(v string) (func(models.User) bool, error) {
+			return func(u models.User) bool { return containsFold(u.Email, v) }, nil
+		},
+	},
+}
+
+var dataCollection = collection[models.DataItem]{
+	id: func(d models.DataItem) int { return d.ID },
+	sorts: map[string]func(models.DataItem) interface{}{
+		"id":    func(d models.DataItem) interface{} { return float64(d.ID) },
+		"name":  func(d models.DataItem) interface{} { return d.Name },
+		"value": func(d models.DataItem) interface{} { return d.Value },
+	},
+	filters: map[string]func(string) (func(models.DataItem) bool, error){
+		"name": func(v string) (func(models.DataItem) bool, error) {
+			return func(d models.DataItem) bool { return containsFold(d.Name, v) }, nil
+		},
+		"min_value": func(v string) (func(models.DataItem) bool, error) {
+			min, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, badQuery("min_value must be a number")
+			}
+			return func(d models.DataItem) bool { return d.Value >= min }, nil
+		},
+		"max_value": func(v string) (func(models.DataItem) bool, error) {
+			max, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, badQuery("max_value must be a number")
+			}
+			return func(d models.DataItem) bool { return d.Value <= max }, nil
+		},
+	},
+}
+
+// QueryUsers returns one page of users
+func (s *Store) QueryUsers(q Query) (Page[models.User], error) {
+	return runQuery(userCollection, s.ListUsers(), q)
+}
+
+// QueryData returns one page of data items
+func (s *Store) QueryData(q Query) (Page[models.DataItem], error) {
+	return runQuery(dataCollection, s.ListData(), q)
+}
+
+// IsFilter reports whether name is a filter understood by the users or data listing
+func IsFilter(name string) bool {
+	_, user := userCollection.filters[name]
+	_, data := dataCollection.filters[name]
+	return user || data
+}
+
+func runQuery[T any](c collection[T], rows []T, q Query) (Page[T], error) {
+	var page Page[T]
+
+	sortField, desc := strings.TrimPrefix(q.Sort, "-"), strings.HasPrefix(q.Sort, "-")
+	if sortField == "" {
+		sortField = "id"
+	}
+	key, ok := c.sorts[sortField]
+	if !ok {
+		return page, badQuery(fmt.Sprintf("Cannot sort by %q", sortField))
+	}
+	order := sortField
+	if desc {
+		order = "-" + sortField
+	}
+
+	var matchers []func(T) bool
+	for name, value := range q.Filters {
+		build, ok := c.filters[name]
+		if !ok {
+			return page, badQuery(fmt.Sprintf("Cannot filter by %q", name))
+		}
+		m, err := build(value)
+		if err != nil {
+			return page, err
+		}
+		matchers = append(matchers, m)
+	}
+	matches := func(row T) bool {
+		for _, m := range matchers {
+			if !m(row) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Order by (key, id) so that the cursor position is always unique
+	before := func(a, b T) bool {
+		if cmp := compareKeys(key(a), key(b)); cmp != 0 {
+			return (cmp < 0) != desc
+		}
+		return (c.id(a) < c.id(b)) != desc
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return before(rows[i], rows[j]) })
+
+	for _, row := range rows {
+		if matches(row) {
+			page.Total++
+		}
+	}
+
+	// Cursor pagination seeks like an index lookup; offset pagination has to
+	// walk past every skipped match, so deep offsets scan more rows.
+	start, skip := 0, q.Offset
+	if q.Cursor != "" {
+		cur, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return page, err
+		}
+		if cur.Sort != order {
+			return page, badQuery(fmt.Sprintf("Cursor was issued for sort %q, not %q", cur.Sort, order))
+		}
+		start = sort.Search(len(rows), func(i int) bool {
+			if cmp := compareKeys(key(rows[i]), cur.Key); cmp != 0 {
+				return (cmp > 0) != desc
+			}
+			if desc {
+				return c.id(rows[i]) < cur.ID
+			}
+			return c.id(rows[i]) > cur.ID
+		})
+		skip = 0
+	}
+
+	// Fetch one extra row to know whether another page exists
+	collected := make([]T, 0, q.Limit+1)
+	for i := start; i < len(rows) && len(collected) <= q.Limit; i++ {
+		page.Scanned++
+		if !matches(rows[i]) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		collected = append(collected, rows[i])
+	}
+
+	if len(collected) > q.Limit {
+		collected = collected[:q.Limit]
+		if q.Limit > 0 {
+			last := collected[len(collected)-1]
+			page.NextCursor = encodeCursor(cursor{Sort: order, Key: key(last), ID: c.id(last)})
+		}
+	}
+	page.Items = collected
+
+	return page, nil
+}
+
+// compareKeys orders two sort keys of the same field
+func compareKeys(a, b interface{}) int {
+	switch av := a.(type) {
+	case float64:
+		bv, _ := b.(float64)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	case string:
+		bv, _ := b.(string)
+		return strings.Compare(av, bv)
+	}
+	return 0
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(raw, &c) != nil {
+		return c, badQuery("Invalid cursor")
+	}
+	return c, nil
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func badQuery(message string) error {
+	return models.NewAppError(message, http.StatusBadRequest)
+}
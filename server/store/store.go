@@ -0,0 +1,150 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Unic-X/slow-server/models"
+)
+
+// Store is the in-memory data set served behind the simulated DB step.
+// It is seeded from a deterministic generator so that the same size and
+// seed always produce the same records.
+type Store struct {
+	mu         sync.RWMutex
+	users      map[int]models.User
+	items      map[int]models.DataItem
+	nextUserID int
+}
+
+var (
+	firstNames = []string{"Ada", "Alan", "Barbara", "Claude", "Dennis", "Edsger", "Frances", "Grace", "Hedy", "John", "Ken", "Linus", "Margaret", "Niklaus", "Radia", "Tim"}
+	lastNames  = []string{"Lovelace", "Turing", "Liskov", "Shannon", "Ritchie", "Dijkstra", "Allen", "Hopper", "Lamarr", "McCarthy", "Thompson", "Torvalds", "Hamilton", "Wirth", "Perlman", "Berners-Lee"}
+	itemKinds  = []string{"Sensor", "Gauge", "Counter", "Meter", "Probe", "Tracker"}
+)
+
+// New builds a store holding size users and size data items generated from seed
+func New(size int, seed int64) *Store {
+	rng := rand.New(rand.NewSource(seed))
+	s := &Store{
+		users: make(map[int]models.User, size),
+		items: make(map[int]models.DataItem, size),
+	}
+
+	for id := 1; id <= size; id++ {
+		first := firstNames[rng.Intn(len(firstNames))]
+		last := lastNames[rng.Intn(len(lastNames))]
+		s.users[id] = models.User{
+			ID:    id,
+			Name:  first + " " + last,
+			Email: fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), id),
+		}
+
+		s.items[id] = models.DataItem{
+			ID:    id,
+			Name:  fmt.Sprintf("%s %d", itemKinds[rng.Intn(len(itemKinds))], id),
+			Value: math.Round(rng.Float64()*10000) / 100,
+		}
+	}
+	s.nextUserID = size + 1
+
+	return s
+}
+
+// ListUsers returns every user ordered by ID
+func (s *Store) ListUsers() []models.User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]models.User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
+}
+
+// GetUser looks up a single user
+func (s *Store) GetUser(id int) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return models.User{}, userNotFound(id)
+	}
+	return u, nil
+}
+
+// CreateUser stores u under a freshly assigned ID
+func (s *Store) CreateUser(u models.User) (models.User, error) {
+	if err := validateUser(u); err != nil {
+		return models.User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u.ID = s.nextUserID
+	s.nextUserID++
+	s.users[u.ID] = u
+	return u, nil
+}
+
+// UpdateUser replaces the user stored under id
+func (s *Store) UpdateUser(id int, u models.User) (models.User, error) {
+	if err := validateUser(u); err != nil {
+		return models.User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return models.User{}, userNotFound(id)
+	}
+	u.ID = id
+	s.users[id] = u
+	return u, nil
+}
+
+// DeleteUser removes the user stored under id
+func (s *Store) DeleteUser(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return userNotFound(id)
+	}
+	delete(s.users, id)
+	return nil
+}
+
+// ListData returns every data item ordered by ID
+func (s *Store) ListData() []models.DataItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make([]models.DataItem, 0, len(s.items))
+	for _, item := range s.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+func validateUser(u models.User) error {
+	if strings.TrimSpace(u.Name) == "" || !strings.Contains(u.Email, "@") {
+		return models.NewAppError("User requires a name and a valid email", http.StatusBadRequest)
+	}
+	return nil
+}
+
+func userNotFound(id int) error {
+	return models.NewAppError(fmt.Sprintf("User %d not found", id), http.StatusNotFound)
+}
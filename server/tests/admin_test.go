@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/slowserver"
+)
+
+func TestAdminDebugVars(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.FaultHeadersSecret = "s3cret"
+	server := slowserver.New(slowserver.WithConfig(testCfg), slowserver.WithClock(fakeClock))
+
+	rr := httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("/debug/vars returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var vars struct {
+		Config     config.Config `json:"config"`
+		Goroutines int           `json:"goroutines"`
+		MemStats   struct {
+			HeapAlloc uint64
+		} `json:"memstats"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("Failed to parse /debug/vars: %v", err)
+	}
+	if vars.Config.DBQueryDelay != testCfg.DBQueryDelay {
+		t.Errorf("Expected the active config in the dump, got DBQueryDelay %d", vars.Config.DBQueryDelay)
+	}
+	if vars.Config.FaultHeadersSecret != "[redacted]" {
+		t.Errorf("Expected the fault header secret to be redacted, got %q", vars.Config.FaultHeadersSecret)
+	}
+	if vars.Goroutines == 0 || vars.MemStats.HeapAlloc == 0 {
+		t.Error("Expected runtime stats in the dump")
+	}
+}
+
+func TestAdminDebugVarsFaultSchedule(t *testing.T) {
+	server := slowserver.New(slowserver.WithConfig(setupTestConfig()), slowserver.WithClock(fakeClock))
+	admin := server.AdminHandler()
+
+	for path, body := range map[string]string{
+		"/admin/faults":   `{"kind":"fail","step":"db","rate":0.5,"status":503,"duration_ms":120000}`,
+		"/admin/scenario": `{"name":"db-outage","phases":[{"name":"slow","duration_ms":60000,"faults":[{"kind":"delay","step":"external","rate":1,"delay_ms":500}]}]}`,
+	} {
+		rr := httptest.NewRecorder()
+		admin.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("POST %s returned wrong status code: got %v want %v", path, rr.Code, http.StatusCreated)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	var vars struct {
+		Injected []models.InjectedFault `json:"injected"`
+		Scenario *models.ScenarioStatus `json:"scenario"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("Failed to parse /debug/vars: %v", err)
+	}
+	if len(vars.Injected) != 1 || vars.Injected[0].Step != "db" || vars.Injected[0].Status != 503 {
+		t.Errorf("Expected the injected db failure in the dump, got %+v", vars.Injected)
+	}
+	if vars.Scenario == nil || vars.Scenario.PhaseName != "slow" || len(vars.Scenario.Faults) != 1 {
+		t.Errorf("Expected the scenario phase in the dump, got %+v", vars.Scenario)
+	}
+}
+
+func TestAdminMetricsIncludeRuntime(t *testing.T) {
+	server := slowserver.New(slowserver.WithConfig(setupTestConfig()), slowserver.WithClock(fakeClock))
+
+	rr := httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+
+	for _, name := range []string{"go_goroutines", "go_gc_pauses_seconds", "process_", "db_queries_total"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("Expected %s in the admin metrics", name)
+		}
+	}
+}
+
+func TestPprofOnlyOnAdminHandler(t *testing.T) {
+	server := slowserver.New(slowserver.WithConfig(setupTestConfig()), slowserver.WithClock(fakeClock))
+
+	rr := httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("pprof index returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	rr = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("pprof reachable on the public handler: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestAdminAPIOnlyOnAdminHandler(t *testing.T) {
+	server := slowserver.New(slowserver.WithConfig(setupTestConfig()), slowserver.WithClock(fakeClock))
+
+	for _, target := range []string{"/admin/config", "/admin/faults", "/admin/degrade", "/admin/degrade/reset", "/admin/cache/flush", "/admin/ui"} {
+		rr := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, target, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s reachable on the public handler: got %v want %v", target, rr.Code, http.StatusNotFound)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("/admin/config returned wrong status code on the admin handler: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestAdminListener(t *testing.T) {
+	server := slowserver.New(
+		slowserver.WithConfig(setupTestConfig()),
+		slowserver.WithClock(fakeClock),
+		slowserver.WithAddr("127.0.0.1:0"),
+		slowserver.WithAdminAddr("127.0.0.1:0"),
+	)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	resp, err := http.Get("http://" + server.AdminAddr() + "/debug/vars")
+	if err != nil {
+		t.Fatalf("GET /debug/vars failed: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("admin listener returned wrong status code: got %v want %v", resp.StatusCode, http.StatusOK)
+	}
+}
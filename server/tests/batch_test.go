@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func postBatch(t *testing.T, body string, expected int) models.BatchResponse {
+	req := httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	testServer.BatchHandler(rr, req)
+
+	if rr.Code != expected {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, expected, rr.Body.String())
+	}
+	var response models.BatchResponse
+	if expected < http.StatusBadRequest {
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse batch response: %v", err)
+		}
+	}
+	return response
+}
+
+func TestBatchEndpoint(t *testing.T) {
+	setupTestConfig()
+
+	response := postBatch(t, `{"requests": [
+		{"op": "user", "id": 3},
+		{"op": "users", "params": {"limit": "2"}},
+		{"op": "data", "params": {"min_value": "50"}},
+		{"op": "user", "id": 999}
+	]}`, http.StatusMultiStatus)
+
+	if response.Succeeded != 3 || response.Failed != 1 || len(response.Results) != 4 {
+		t.Fatalf("Expected 3 of 4 sub-requests to succeed, got %+v", response)
+	}
+	expected := []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusNotFound}
+	for i, result := range response.Results {
+		if result.Index != i || result.Status != expected[i] {
+			t.Errorf("Result %d: got index %d status %d, want status %d", i, result.Index, result.Status, expected[i])
+		}
+	}
+
+	var user models.User
+	body, _ := json.Marshal(response.Results[0].Body)
+	if err := json.Unmarshal(body, &user); err != nil || user.ID != 3 {
+		t.Errorf("Expected user 3 in the first result, got %s", body)
+	}
+
+	m := testServer.Metrics()
+	if got := histogramSampleSum(t, m.BatchFanoutWidth); got != 4 {
+		t.Errorf("Expected a fan-out width of 4, got %v", got)
+	}
+	if got := histogramSampleCount(t, m.BatchItemDuration.WithLabelValues("user").(prometheus.Histogram)); got != 2 {
+		t.Errorf("Expected 2 user lookups to be timed, got %d", got)
+	}
+	if got := histogramSampleCount(t, m.BatchDuration.WithLabelValues("2-5").(prometheus.Histogram)); got != 1 {
+		t.Errorf("Expected the batch timed under width 2-5, got %d", got)
+	}
+}
+
+func TestBatchSequentialAddsUp(t *testing.T) {
+	setupTestConfig()
+
+	// One at a time on the fake clock, the batch takes exactly as long as
+	// its sub-requests together; otherwise it only waits for the slowest
+	response := postBatch(t, `{"concurrency": 1, "requests": [
+		{"op": "user", "id": 1}, {"op": "user", "id": 2}, {"op": "users"}
+	]}`, http.StatusOK)
+
+	var total int64
+	for _, result := range response.Results {
+		total += result.DurationMs
+	}
+	if response.Concurrency != 1 || response.DurationMs != total {
+		t.Errorf("Expected the batch to take the %dms of its sub-requests, took %dms", total, response.DurationMs)
+	}
+	if response.SlowestMs != response.Results[2].DurationMs {
+		t.Errorf("Expected the user list to be the slowest, got %dms", response.SlowestMs)
+	}
+}
+
+func TestBatchAllFailed(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.SimulateErrors = true
+	testCfg.ErrorRate = 1
+
+	response := postBatch(t, `{"requests": [{"op": "user", "id": 1}, {"op": "data"}]}`, http.StatusBadGateway)
+	for _, result := range response.Results {
+		if result.Status != http.StatusInternalServerError || result.Error == "" {
+			t.Errorf("Expected sub-request %d to fail with 500, got %d", result.Index, result.Status)
+		}
+	}
+}
+
+func TestBatchInvalidRequests(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.BatchMaxSize = 2
+
+	tests := []struct {
+		body     string
+		expected int
+	}{
+		{`{"requests": []}`, http.StatusBadRequest},
+		{`{"requests": [{"op": "orders"}]}`, http.StatusBadRequest},
+		{`{"concurrency": -1, "requests": [{"op": "data"}]}`, http.StatusBadRequest},
+		{`{"requests": [{"op": "data"}, {"op": "data"}, {"op": "data"}]}`, http.StatusRequestEntityTooLarge},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		postBatch(t, tt.body, tt.expected)
+	}
+}
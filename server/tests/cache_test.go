@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func setupCacheTestConfig() {
+	testCfg := setupTestConfig()
+	testCfg.CacheEnabled = true
+	testCfg.CacheSteps = []string{"db"}
+	testCfg.CacheHitRatio = 1 // Always hit once an entry exists
+	testCfg.CacheHitDelay = 1
+	testCfg.CacheMissDelay = 1
+	testCfg.CacheTTL = 60000
+	testCfg.CacheWarmup = 0
+}
+
+func flushCache(t *testing.T) models.CacheFlushResponse {
+	req := httptest.NewRequest(http.MethodPost, "/admin/cache/flush", nil)
+	rr := httptest.NewRecorder()
+	testServer.CacheFlushHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("flush returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var response models.CacheFlushResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse flush response: %v", err)
+	}
+	return response
+}
+
+func getData(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/data", nil)
+	rr := httptest.NewRecorder()
+	testServer.GetDataHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestCacheHitAfterMiss(t *testing.T) {
+	setupCacheTestConfig()
+	m := testServer.Metrics()
+
+	getData(t) // Cold: miss and populate
+	getData(t) // Warm: hit
+
+	if got := testutil.ToFloat64(m.CacheMisses.WithLabelValues("db")); got != 1 {
+		t.Errorf("Expected 1 cache miss, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.CacheHits.WithLabelValues("db")); got != 1 {
+		t.Errorf("Expected 1 cache hit, got %v", got)
+	}
+}
+
+func TestCacheFlushEvicts(t *testing.T) {
+	setupCacheTestConfig()
+	m := testServer.Metrics()
+
+	getData(t)
+
+	response := flushCache(t)
+	if response.Evicted != 1 {
+		t.Errorf("Expected 1 evicted entry, got %d", response.Evicted)
+	}
+	if got := testutil.ToFloat64(m.CacheEvictions.WithLabelValues("db")); got != 1 {
+		t.Errorf("Expected 1 eviction, got %v", got)
+	}
+
+	// The first request after a flush must fall through to the dependency
+	getData(t)
+	if got := testutil.ToFloat64(m.CacheMisses.WithLabelValues("db")); got != 2 {
+		t.Errorf("Expected a second miss right after flush, got %v", got)
+	}
+}
+
+func TestCacheFlushMethodNotAllowed(t *testing.T) {
+	setupCacheTestConfig()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/cache/flush", nil)
+	rr := httptest.NewRecorder()
+	testServer.CacheFlushHandler(rr, req)
+
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("flush accepted GET: got %v want %v", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
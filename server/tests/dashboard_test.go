@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/dashboard"
+)
+
+func TestGeneratedDashboardUpToDate(t *testing.T) {
+	files, err := dashboard.Files(config.Default())
+	if err != nil {
+		t.Fatalf("Files failed: %v", err)
+	}
+	for name, content := range files {
+		committed, err := os.ReadFile(filepath.Join("..", "..", "k8s", "generated", name))
+		if err != nil {
+			t.Fatalf("Reading k8s/generated/%s failed: %v", name, err)
+		}
+		if string(committed) != string(content) {
+			t.Errorf("k8s/generated/%s is stale, run go generate in server/", name)
+		}
+	}
+}
+
+func TestDescribeMetrics(t *testing.T) {
+	described, _ := dashboard.ForServer(config.Default())
+	byName := map[string]dashboard.Metric{}
+	for _, m := range described {
+		byName[m.Name] = m
+	}
+
+	tests := []struct {
+		name   string
+		typ    string
+		labels string
+	}{
+		{"http_requests_total", dashboard.Counter, "path,method,status"},
+		{"db_query_duration_ms", dashboard.Histogram, ""},
+		{"cache_hits_total", dashboard.Counter, "step"},
+		{"jobs_in_progress", dashboard.Gauge, ""},
+		{"slo_burn_rate", dashboard.Gauge, "slo,window"},
+		{"grpc_server_handling_seconds", dashboard.Histogram, "grpc_type,grpc_service,grpc_method"},
+	}
+	for _, tt := range tests {
+		m, ok := byName[tt.name]
+		if !ok {
+			t.Errorf("Expected %s to be described", tt.name)
+			continue
+		}
+		if m.Type != tt.typ || strings.Join(m.Labels, ",") != tt.labels {
+			t.Errorf("%s: got %s by %v, want %s by %s", tt.name, m.Type, m.Labels, tt.typ, tt.labels)
+		}
+	}
+}
+
+func TestDashboardCoversMetricsAndRoutes(t *testing.T) {
+	described, routes := dashboard.ForServer(config.Default())
+	board := dashboard.Generate(described, routes)
+
+	var exprs []string
+	rows := map[string]bool{}
+	for _, panel := range board.Panels {
+		if panel.Type == "row" {
+			rows[panel.Title] = true
+		}
+		for _, target := range panel.Targets {
+			exprs = append(exprs, target.Expr)
+		}
+	}
+	all := strings.Join(exprs, "\n")
+
+	for _, m := range described {
+		if !strings.Contains(all, m.Name) {
+			t.Errorf("Expected a panel for %s", m.Name)
+		}
+	}
+	for _, route := range routes {
+		admin := strings.HasPrefix(route.Path, "/admin/")
+		if rows[route.Pattern] == admin {
+			t.Errorf("Route %s: got a row %v, want %v", route.Pattern, rows[route.Pattern], !admin)
+		}
+	}
+	for _, dep := range []string{"Database", "External API", "Processing", "Cache", "Downstream calls"} {
+		if !rows[dep] {
+			t.Errorf("Expected a row for %s", dep)
+		}
+	}
+	if !strings.Contains(all, `http_request_duration_ms_bucket{path="/api/users/{id}",method="GET"}`) {
+		t.Errorf("Expected the duration of GET /api/users/{id} by its pattern")
+	}
+}
+
+func TestRouteSelector(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"/api/data", `{path="/api/data"}`},
+		{"DELETE /api/users/{id}", `{path="/api/users/{id}",method="DELETE"}`},
+		{"GET /files/{path...}", `{path="/files/{path...}",method="GET"}`},
+		{"/static/", `{path="/static/"}`},
+		{"/v1.0/ping", `{path="/v1.0/ping"}`},
+	}
+	for _, tt := range tests {
+		var routes dashboard.Routes
+		routes.HandleFunc(tt.pattern, nil)
+		if got := routes[0].Selector(); got != tt.expected {
+			t.Errorf("%s: got %s want %s", tt.pattern, got, tt.expected)
+		}
+	}
+}
+
+func TestGeneratedRules(t *testing.T) {
+	described, routes := dashboard.ForServer(config.Default())
+	rules := dashboard.Rules(described, routes)
+
+	for _, want := range []string{
+		"      - record: route:http_request_duration_ms:p99\n" +
+			"        expr: 'histogram_quantile(0.99, sum by (le) (rate(http_request_duration_ms_bucket{path=\"/api/users/{id}\",method=\"GET\"}[5m])))'\n" +
+			"        labels:\n" +
+			"          route: 'GET /api/users/{id}'\n",
+		"      - record: step:cache_hits:rate5m\n" +
+			"        expr: 'sum by (step) (rate(cache_hits_total[5m]))'\n",
+		"      - record: job:db_query_duration_ms:p95\n",
+	} {
+		if !strings.Contains(rules, want) {
+			t.Errorf("Expected the rules to contain:\n%s", want)
+		}
+	}
+	if strings.Contains(rules, "/admin/") || strings.Contains(rules, ":jobs_in_progress:") {
+		t.Errorf("Expected no rules for admin routes or gauges")
+	}
+}
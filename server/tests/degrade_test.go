@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// degradeRouter serves the API and the admin API of the test server together
+func degradeRouter() *http.ServeMux {
+	router := http.NewServeMux()
+	testServer.Routes(router)
+	testServer.AdminRoutes(router)
+	return router
+}
+
+func serve(router http.Handler, method, target string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+	return rr
+}
+
+func degradeStatus(t *testing.T, rr *httptest.ResponseRecorder) models.DegradeStatus {
+	if rr.Code != http.StatusOK {
+		t.Fatalf("degrade admin returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var status models.DegradeStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to parse degrade status: %v", err)
+	}
+	return status
+}
+
+func TestMemoryLeakStopsAtCap(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.DegradeMemory = true
+	testCfg.LeakBytes = 1000
+	testCfg.LeakMaxBytes = 2500
+	testServer = newTestServer(testCfg)
+	router := degradeRouter()
+
+	for i := 0; i < 3; i++ {
+		serve(router, http.MethodGet, "/api/data")
+	}
+
+	status := degradeStatus(t, serve(router, http.MethodGet, "/admin/degrade"))
+	if status.LeakedBytes != 2500 {
+		t.Errorf("Expected the leak to stop at 2500 bytes, got %d", status.LeakedBytes)
+	}
+	if got := testutil.ToFloat64(testServer.Metrics().LeakedBytes); got != 2500 {
+		t.Errorf("Expected the leaked bytes gauge at 2500, got %v", got)
+	}
+
+	status = degradeStatus(t, serve(router, http.MethodPost, "/admin/degrade/reset"))
+	if status.LeakedBytes != 0 || !status.Memory {
+		t.Errorf("Expected reset to free the memory and keep the mode on, got %+v", status)
+	}
+}
+
+func TestGoroutineLeakAndReset(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.DegradeGoroutines = true
+	testCfg.LeakGoroutines = 3
+	testCfg.LeakMaxGoroutines = 5
+	testServer = newTestServer(testCfg)
+	router := degradeRouter()
+
+	before := runtime.NumGoroutine()
+	serve(router, http.MethodGet, "/api/data")
+	serve(router, http.MethodGet, "/api/data")
+
+	if got := testutil.ToFloat64(testServer.Metrics().LeakedGoroutines); got != 5 {
+		t.Errorf("Expected 5 leaked goroutines, got %v", got)
+	}
+	if runtime.NumGoroutine() < before+5 {
+		t.Errorf("Expected at least %d goroutines, got %d", before+5, runtime.NumGoroutine())
+	}
+
+	status := degradeStatus(t, serve(router, http.MethodPost, "/admin/degrade/reset"))
+	if status.LeakedGoroutines != 0 {
+		t.Errorf("Expected reset to release the goroutines, got %d", status.LeakedGoroutines)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() >= before+5 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if runtime.NumGoroutine() >= before+5 {
+		t.Errorf("Leaked goroutines did not exit after reset")
+	}
+}
+
+func TestCPUBurn(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.DegradeCPU = true
+	testCfg.CPUBurn = 2
+	testCfg.CPUBurnMaxConcurrent = 1
+	testServer = newTestServer(testCfg)
+	router := degradeRouter()
+
+	start := time.Now()
+	serve(router, http.MethodGet, "/api/data")
+	if elapsed := time.Since(start); elapsed < 2*time.Millisecond {
+		t.Errorf("Expected the request to burn 2ms of CPU, took %v", elapsed)
+	}
+	if got := testutil.ToFloat64(testServer.Metrics().CPUBurnMs); got != 2 {
+		t.Errorf("Expected 2ms of CPU burn to be counted, got %v", got)
+	}
+}
+
+func TestDegradeModeToggle(t *testing.T) {
+	setupTestConfig()
+	router := degradeRouter()
+
+	status := degradeStatus(t, serve(router, http.MethodPost, "/admin/degrade?mode=cpu&enabled=true"))
+	if !status.CPU || status.Memory || status.Goroutines {
+		t.Errorf("Expected only the cpu mode to be on, got %+v", status)
+	}
+
+	if rr := serve(router, http.MethodPost, "/admin/degrade?mode=disk&enabled=true"); rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown mode returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := serve(router, http.MethodPost, "/admin/degrade?mode=cpu"); rr.Code != http.StatusBadRequest {
+		t.Errorf("missing enabled returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
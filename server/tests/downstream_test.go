@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/slowserver"
+	"github.com/Unic-X/slow-server/trace"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newHopTestServer starts an instance whose steps call the given downstreams
+func newHopTestServer(t *testing.T, testCfg *config.Config, downstreams map[string]string) *slowserver.TestServer {
+	testCfg.Downstreams = downstreams
+	if testCfg.DownstreamTimeout == 0 {
+		testCfg.DownstreamTimeout = 1000
+	}
+	return newSlowTestServer(t, testCfg)
+}
+
+func getStatus(t *testing.T, url string, header http.Header) int {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestDownstreamPropagatesTrace(t *testing.T) {
+	var got http.Header
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer stub.Close()
+
+	a := newHopTestServer(t, setupTestConfig(), map[string]string{"external": stub.URL})
+	incoming := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	status := getStatus(t, a.URL+"/api/users", http.Header{
+		"Traceparent":  {incoming},
+		"X-Request-Id": {"hop-test"},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+
+	tc, ok := trace.Parse(got.Get(trace.Header))
+	if !ok {
+		t.Fatalf("Expected a valid traceparent downstream, got %q", got.Get(trace.Header))
+	}
+	if tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.SpanID == "00f067aa0ba902b7" {
+		t.Errorf("Expected the trace continued with a new span, got %s", tc.Traceparent())
+	}
+	if got.Get("X-Request-ID") != "hop-test" {
+		t.Errorf("Expected the request ID passed on, got %q", got.Get("X-Request-ID"))
+	}
+
+	// Without a traceparent the first hop starts the trace
+	getStatus(t, a.URL+"/api/users", nil)
+	if _, ok := trace.Parse(got.Get(trace.Header)); !ok || got.Get("X-Request-ID") == "" {
+		t.Errorf("Expected a new trace and request ID, got %q and %q", got.Get(trace.Header), got.Get("X-Request-ID"))
+	}
+}
+
+func TestDownstreamErrorMapping(t *testing.T) {
+	tests := []struct {
+		downstream int
+		expected   int
+	}{
+		{http.StatusInternalServerError, http.StatusBadGateway},
+		{http.StatusNotFound, http.StatusBadGateway},
+		{http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+		{http.StatusGatewayTimeout, http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.downstream)
+		}))
+		a := newHopTestServer(t, setupTestConfig(), map[string]string{"db": stub.URL})
+		if got := getStatus(t, a.URL+"/api/users/1", nil); got != tt.expected {
+			t.Errorf("Downstream %d: got %d want %d", tt.downstream, got, tt.expected)
+		}
+		stub.Close()
+	}
+
+	// No downstream listening at all
+	stub := httptest.NewServer(http.NotFoundHandler())
+	stub.Close()
+	a := newHopTestServer(t, setupTestConfig(), map[string]string{"db": stub.URL})
+	if got := getStatus(t, a.URL+"/api/users/1", nil); got != http.StatusBadGateway {
+		t.Errorf("Unreachable downstream: got %d want %d", got, http.StatusBadGateway)
+	}
+	if got := testutil.ToFloat64(a.Server.Metrics().DownstreamCalls.WithLabelValues("db", "0")); got != 1 {
+		t.Errorf("Expected the failed call counted with status 0, got %v", got)
+	}
+}
+
+func TestDownstreamTimeout(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer stub.Close()
+
+	testCfg := setupTestConfig()
+	testCfg.DownstreamTimeout = 50
+	a := newHopTestServer(t, testCfg, map[string]string{"external": stub.URL})
+	if got := getStatus(t, a.URL+"/api/users", nil); got != http.StatusGatewayTimeout {
+		t.Errorf("Expected 504 once the downstream call times out, got %d", got)
+	}
+}
+
+func TestDownstreamCascade(t *testing.T) {
+	// c fails every DB query, b calls c for its external step and a calls b
+	cCfg := setupTestConfig()
+	cCfg.SimulateErrors = true
+	cCfg.ErrorRate = 1
+	c := newHopTestServer(t, cCfg, nil)
+	b := newHopTestServer(t, setupTestConfig(), map[string]string{"external": c.URL + "/api/users/1"})
+	a := newHopTestServer(t, setupTestConfig(), map[string]string{"db": b.URL + "/api/users?limit=1"})
+
+	if got := getStatus(t, a.URL+"/api/users/1", nil); got != http.StatusBadGateway {
+		t.Errorf("Expected the failure to cascade up as 502, got %d", got)
+	}
+	if got := testutil.ToFloat64(b.Server.Metrics().DownstreamCalls.WithLabelValues("external", "500")); got != 1 {
+		t.Errorf("Expected b to see a 500 from c, got %v", got)
+	}
+	if got := testutil.ToFloat64(a.Server.Metrics().DownstreamCalls.WithLabelValues("db", "502")); got != 1 {
+		t.Errorf("Expected a to see a 502 from b, got %v", got)
+	}
+}
+
+func TestDownstreamSkipsLocalErrorRate(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer stub.Close()
+
+	// Every local roll would fail, but the healthy downstream decides db
+	testCfg := setupTestConfig()
+	testCfg.SimulateErrors = true
+	testCfg.ErrorRate = 1
+	a := newHopTestServer(t, testCfg, map[string]string{"db": stub.URL})
+	if got := getStatus(t, a.URL+"/api/users/1", nil); got != http.StatusOK {
+		t.Errorf("Expected the downstream's success to stand, got %d", got)
+	}
+}
+
+func TestDownstreamInjectedDelay(t *testing.T) {
+	calls := 0
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer stub.Close()
+
+	a := newHopTestServer(t, setupTestConfig(), map[string]string{"db": stub.URL})
+	resp, err := http.Post(a.AdminURL+"/admin/faults", "application/json",
+		strings.NewReader(`{"kind":"delay","step":"db","rate":1,"delay_ms":2000,"duration_ms":60000}`))
+	if err != nil {
+		t.Fatalf("POST /admin/faults failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := getStatus(t, a.URL+"/api/users/1", nil); got != http.StatusOK || calls != 1 {
+		t.Fatalf("Expected the downstream to be called, got %d with %d calls", got, calls)
+	}
+	if got := histogramSampleSum(t, a.Server.Metrics().DBQueryDuration); got < 2000 {
+		t.Errorf("Expected the injected 2s delay before the downstream call, db took %vms", got)
+	}
+}
+
+func TestDownstreamFaultHeaders(t *testing.T) {
+	calls := 0
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer stub.Close()
+
+	testCfg := setupTestConfig()
+	testCfg.FaultHeadersEnabled = true
+	testCfg.FaultHeadersSecret = "s3cret"
+	a := newHopTestServer(t, testCfg, map[string]string{"db": stub.URL})
+
+	if got := getStatus(t, a.URL+"/api/users/1", http.Header{
+		"X-Slow-Fail":  {"db=503"},
+		"X-Slow-Token": {"s3cret"},
+	}); got != http.StatusServiceUnavailable {
+		t.Errorf("Expected the forced db failure to return 503, got %d", got)
+	}
+	if got := getStatus(t, a.URL+"/api/users/1", http.Header{
+		"X-Slow-Delay": {"db=150ms"},
+		"X-Slow-Token": {"s3cret"},
+	}); got != http.StatusOK {
+		t.Errorf("Expected the forced db delay to return 200, got %d", got)
+	}
+	if calls != 0 {
+		t.Errorf("Expected forced steps to skip the downstream, got %d calls", calls)
+	}
+	if got := testutil.ToFloat64(a.Server.Metrics().FaultOverrides.WithLabelValues("delay", "db")); got != 1 {
+		t.Errorf("Expected 1 forced db delay to be counted, got %v", got)
+	}
+
+	if got := getStatus(t, a.URL+"/api/users/1", nil); got != http.StatusOK || calls != 1 {
+		t.Errorf("Expected a request without fault headers to call the downstream, got %d with %d calls", got, calls)
+	}
+}
+
+func TestParseDownstreams(t *testing.T) {
+	d, err := config.ParseDownstreams("external=http://slow-b:8080/api/users, db=https://slow-c/api/data?limit=5")
+	if err != nil {
+		t.Fatalf("ParseDownstreams failed: %v", err)
+	}
+	if d["external"] != "http://slow-b:8080/api/users" || d["db"] != "https://slow-c/api/data?limit=5" {
+		t.Errorf("Unexpected downstreams: %v", d)
+	}
+
+	for _, invalid := range []string{"cache=http://x", "db=slow-b:8080", "http://slow-b", "db=ftp://x"} {
+		if _, err := config.ParseDownstreams(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -9,10 +9,22 @@ import (
 	"time"
 
 	"github.com/Unic-X/slow-server/api"
+	"github.com/Unic-X/slow-server/clock"
 	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/faults"
+	"github.com/Unic-X/slow-server/middleware"
 	"github.com/Unic-X/slow-server/models"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
+// fakeClock replaces the wall clock in every test that calls setupTestConfig,
+// so simulated delays take no real time and durations are exact
+var fakeClock *clock.Fake
+
+// testServer is rebuilt by every setupTestConfig call
+var testServer *api.Server
+
 func setupTestConfig() *config.Config {
 	// Create test config with predictable behavior
 	testCfg := &config.Config{
@@ -26,12 +38,30 @@ func setupTestConfig() *config.Config {
 		ProcessDelay:   15,
 		ErrorRate:      0,
 		EnableMetrics:  true,
+		StoreSize:      10,
+		StoreSeed:      1,
+		DefaultPageSize: 50,
+		MaxPageSize:     500,
+		JobWorkers:      2,
+		JobQueueSize:    10,
+		JobRetention:    60000,
+		CallbackTimeout: 1000,
+		WSCloseCode:     1011,
+
+		TrafficRecordSize: 100,
 	}
-	// Set the test config in the API package
-	api.SetConfig(testCfg)
+	// Serve the tests from a fresh server running on the test config
+	fakeClock = newFakeClock()
+	testServer = newTestServer(testCfg)
 	return testCfg
 }
 
+// newTestServer builds a server on the fake clock, for tests that change
+// config read only at construction
+func newTestServer(cfg *config.Config) *api.Server {
+	return api.NewServer(cfg, api.WithClock(fakeClock))
+}
+
 func TestGetDataEndpoint(t *testing.T) {
 	setupTestConfig()
 
@@ -46,7 +76,7 @@ func TestGetDataEndpoint(t *testing.T) {
 
 	// Create a ResponseRecorder to record the response
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(api.GetDataHandler)
+	handler := http.HandlerFunc(testServer.GetDataHandler)
 
 	// Call the handler
 	handler.ServeHTTP(rr, req)
@@ -90,7 +120,7 @@ func TestGetUsersEndpoint(t *testing.T) {
 
 	// Create a ResponseRecorder to record the response
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(api.GetUsersHandler)
+	handler := http.HandlerFunc(testServer.GetUsersHandler)
 
 	// Call the handler
 	handler.ServeHTTP(rr, req)
@@ -144,7 +174,7 @@ func TestProcessDataEndpoint(t *testing.T) {
 
 	// Create a ResponseRecorder to record the response
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(api.ProcessDataHandler)
+	handler := http.HandlerFunc(testServer.ProcessDataHandler)
 
 	// Call the handler
 	handler.ServeHTTP(rr, req)
@@ -167,9 +197,9 @@ func TestProcessDataEndpoint(t *testing.T) {
 		t.Errorf("Expected success to be true, got false")
 	}
 
-	// Verify the process ID is set
-	if response.ProcessID == 0 {
-		t.Errorf("Expected non-zero process ID, got 0")
+	// Verify every item has a result
+	if len(response.Results) != 3 {
+		t.Errorf("Expected 3 item results, got %d", len(response.Results))
 	}
 }
 
@@ -183,7 +213,7 @@ func TestMethodNotAllowed(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(api.GetDataHandler)
+	handler := http.HandlerFunc(testServer.GetDataHandler)
 	handler.ServeHTTP(rr, req)
 
 	// Check that method is not allowed
@@ -211,7 +241,7 @@ func TestInvalidRequestBody(t *testing.T) {
 
 	req.Header.Set("Content-Type", "application/json")
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(api.ProcessDataHandler)
+	handler := http.HandlerFunc(testServer.ProcessDataHandler)
 	handler.ServeHTTP(rr, req)
 
 	// Check that the request is considered bad
@@ -223,27 +253,41 @@ func TestInvalidRequestBody(t *testing.T) {
 func TestResponseTime(t *testing.T) {
 	setupTestConfig()
 
+	// Pin the step delays so the expected response time is exact
+	overrides := &faults.Overrides{
+		Delays: map[string]time.Duration{
+			"db":      20 * time.Millisecond,
+			"process": 15 * time.Millisecond,
+		},
+	}
 	req, err := http.NewRequest("GET", "/api/data", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	req = req.WithContext(faults.WithOverrides(req.Context(), overrides))
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(api.GetDataHandler)
+	m := testServer.Metrics()
+	routes := http.NewServeMux()
+	routes.HandleFunc("/api/data", testServer.GetDataHandler)
+	handler := middleware.ApplyMetricsMiddleware(routes, testServer.Config(), m, routes, fakeClock)
 
-	// Measure response time
-	start := time.Now()
+	// Measure response time on the fake clock
+	start := fakeClock.Now()
 	handler.ServeHTTP(rr, req)
-	duration := time.Since(start)
+	duration := fakeClock.Since(start)
 
-	// The test config has delays, so we expect some minimum response time
-	// MinDelay + DBQueryDelay + ProcessDelay would be the minimum expected
-	// But it could be more due to actual processing time
-	minExpectedDuration := 45 * time.Millisecond // 10 + 20 + 15
+	expectedDuration := 35 * time.Millisecond // 20 + 15
+	if duration != expectedDuration {
+		t.Errorf("Unexpected response time: %v, expected exactly %v", duration, expectedDuration)
+	}
 
-	if duration < minExpectedDuration {
-		t.Errorf("Response was too fast: %v, expected at least %v", 
-			duration, minExpectedDuration)
+	// The metrics must have observed exactly the injected delays
+	if got := histogramSampleSum(t, m.DBQueryDuration); got != 20 {
+		t.Errorf("DB query histogram observed %vms, expected 20ms", got)
+	}
+	if got := histogramSampleSum(t, m.RequestDuration.WithLabelValues("/api/data", "GET").(prometheus.Histogram)); got != 35 {
+		t.Errorf("Request histogram observed %vms, expected 35ms", got)
 	}
 
 	// Also check we got a valid response
@@ -252,3 +296,21 @@ func TestResponseTime(t *testing.T) {
 			status, http.StatusOK)
 	}
 }
+
+// histogramSampleSum returns the sum of all observations of h
+func histogramSampleSum(t *testing.T, h prometheus.Histogram) float64 {
+	var m dto.Metric
+	if err := h.Write(&m); err != nil {
+		t.Fatalf("Failed to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleSum()
+}
+
+// histogramSampleCount returns the number of observations of h
+func histogramSampleCount(t *testing.T, h prometheus.Histogram) uint64 {
+	var m dto.Metric
+	if err := h.Write(&m); err != nil {
+		t.Fatalf("Failed to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
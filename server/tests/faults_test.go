@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/middleware"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func faultHeadersHandler(testCfg *config.Config) http.Handler {
+	return middleware.ApplyFaultHeadersMiddleware(http.HandlerFunc(testServer.GetUsersHandler), config.NewCurrent(testCfg), testServer.Metrics())
+}
+
+func TestFaultHeadersForceFailure(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.FaultHeadersEnabled = true
+	testCfg.FaultHeadersSecret = "s3cret"
+	handler := faultHeadersHandler(testCfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Header.Set("X-Slow-Fail", "external=503")
+	req.Header.Set("X-Slow-Token", "s3cret")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("forced failure returned wrong status code: got %v want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+	if got := testutil.ToFloat64(testServer.Metrics().FaultOverrides.WithLabelValues("fail", "external")); got != 1 {
+		t.Errorf("Expected 1 forced external failure to be counted, got %v", got)
+	}
+}
+
+func TestFaultHeadersForceDelay(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.FaultHeadersEnabled = true
+	testCfg.FaultHeadersAllow = []string{"192.0.2.0/24"} // httptest's default client address
+	handler := faultHeadersHandler(testCfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Header.Set("X-Slow-Delay", "db=150ms")
+	rr := httptest.NewRecorder()
+
+	start := fakeClock.Now()
+	handler.ServeHTTP(rr, req)
+	duration := fakeClock.Since(start)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if duration < 150*time.Millisecond {
+		t.Errorf("Forced delay not applied: request took %v", duration)
+	}
+}
+
+func TestFaultHeadersRefusedForUnknownClient(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.FaultHeadersEnabled = true
+	testCfg.FaultHeadersAllow = []string{"10.0.0.1"}
+	handler := faultHeadersHandler(testCfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Header.Set("X-Slow-Fail", "db")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("unauthorized override returned wrong status code: got %v want %v", rr.Code, http.StatusForbidden)
+	}
+	if got := testutil.ToFloat64(testServer.Metrics().FaultOverridesRejected.WithLabelValues("forbidden")); got != 1 {
+		t.Errorf("Expected 1 refused override to be counted, got %v", got)
+	}
+}
+
+func TestFaultHeadersIgnoredWhenDisabled(t *testing.T) {
+	testCfg := setupTestConfig()
+	handler := faultHeadersHandler(testCfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Header.Set("X-Slow-Fail", "db")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("disabled overrides were applied: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestFaultHeadersInvalid(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.FaultHeadersEnabled = true
+	testCfg.FaultHeadersAllow = []string{"192.0.2.1"}
+	handler := faultHeadersHandler(testCfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Header.Set("X-Slow-Delay", "db=soon")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid override accepted: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestFaultHeadersPatchedAtRuntime(t *testing.T) {
+	ts := newSlowTestServer(t, setupTestConfig())
+	header := http.Header{"X-Slow-Fail": {"db=503"}}
+
+	if got := getStatus(t, ts.URL+"/api/users/1", header); got != http.StatusOK {
+		t.Fatalf("Expected the headers ignored while disabled, got %d", got)
+	}
+
+	if got := patchConfig(t, ts.AdminURL, `{"FaultHeadersEnabled":true,"FaultHeadersAllow":["127.0.0.1"]}`); got != http.StatusOK {
+		t.Fatalf("Enabling fault headers returned %d", got)
+	}
+	if got := getStatus(t, ts.URL+"/api/users/1", header); got != http.StatusServiceUnavailable {
+		t.Errorf("Expected the forced failure once enabled, got %d", got)
+	}
+
+	if got := patchConfig(t, ts.AdminURL, `{"FaultHeadersAllow":["192.0.2.0/24"]}`); got != http.StatusOK {
+		t.Fatalf("Patching the allow-list returned %d", got)
+	}
+	if got := getStatus(t, ts.URL+"/api/users/1", header); got != http.StatusForbidden {
+		t.Errorf("Expected the client refused once no longer allowed, got %d", got)
+	}
+
+	if got := patchConfig(t, ts.AdminURL, `{"FaultHeadersAllow":["localhost"]}`); got != http.StatusBadRequest {
+		t.Errorf("Expected an invalid allow-list entry rejected, got %d", got)
+	}
+}
@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type graphQLResponse struct {
+	Data struct {
+		Data []struct {
+			ID    int `json:"id"`
+			Owner *struct {
+				ID   int    `json:"id"`
+				Name string `json:"name"`
+			} `json:"owner"`
+		} `json:"data"`
+		Users []struct {
+			Email string `json:"email"`
+		} `json:"users"`
+	} `json:"data"`
+	Errors []struct {
+		Message string        `json:"message"`
+		Path    []interface{} `json:"path"`
+	} `json:"errors"`
+}
+
+func queryGraphQL(t *testing.T, target, query string) graphQLResponse {
+	body, _ := json.Marshal(map[string]string{"query": query})
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(string(body)))
+	rr := httptest.NewRecorder()
+	testServer.GraphQLHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var response graphQLResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse GraphQL response: %v", err)
+	}
+	return response
+}
+
+func resolverDuration(field string) prometheus.Histogram {
+	return testServer.Metrics().GraphQLResolverDuration.WithLabelValues(field).(prometheus.Histogram)
+}
+
+func TestGraphQLNPlusOne(t *testing.T) {
+	setupTestConfig()
+
+	response := queryGraphQL(t, "/graphql?mode=n%2B1", "{ data(limit: 5) { id owner { id name } } users(limit: 2) { email } }")
+	if len(response.Errors) != 0 {
+		t.Fatalf("Unexpected errors: %+v", response.Errors)
+	}
+	if len(response.Data.Data) != 5 || len(response.Data.Users) != 2 {
+		t.Fatalf("Expected 5 items and 2 users, got %d and %d", len(response.Data.Data), len(response.Data.Users))
+	}
+	for _, item := range response.Data.Data {
+		if item.Owner == nil || item.Owner.ID != item.ID {
+			t.Errorf("Expected item %d to be owned by user %d, got %+v", item.ID, item.ID, item.Owner)
+		}
+	}
+
+	if got := histogramSampleCount(t, resolverDuration("DataItem.owner")); got != 5 {
+		t.Errorf("Expected one owner resolver call per item, got %d", got)
+	}
+	if got := histogramSampleCount(t, resolverDuration("Query.data")); got != 1 {
+		t.Errorf("Expected one data resolver call, got %d", got)
+	}
+}
+
+func TestGraphQLDataloader(t *testing.T) {
+	setupTestConfig()
+
+	response := queryGraphQL(t, "/graphql?mode=dataloader", "{ data(limit: 5) { id owner { id } } }")
+	if len(response.Errors) != 0 {
+		t.Fatalf("Unexpected errors: %+v", response.Errors)
+	}
+	for _, item := range response.Data.Data {
+		if item.Owner == nil || item.Owner.ID != item.ID {
+			t.Errorf("Expected item %d to be owned by user %d, got %+v", item.ID, item.ID, item.Owner)
+		}
+	}
+
+	if got := histogramSampleCount(t, resolverDuration("DataItem.owner")); got != 1 {
+		t.Errorf("Expected the owners loaded in one batch, got %d resolver calls", got)
+	}
+	batch := testServer.Metrics().GraphQLBatchSize.WithLabelValues("DataItem.owner").(prometheus.Histogram)
+	if got := histogramSampleSum(t, batch); got != 5 {
+		t.Errorf("Expected a batch of 5 owners, got %v", got)
+	}
+}
+
+func TestGraphQLPartialErrors(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.GraphQLFields = map[string]config.Resolver{
+		"*":              {Delay: 10},
+		"DataItem.owner": {Delay: 10, ErrorRate: 1},
+	}
+
+	response := queryGraphQL(t, "/graphql", "{ data(limit: 3) { id owner { id } } }")
+	if len(response.Data.Data) != 3 {
+		t.Fatalf("Expected the items despite the failed owners, got %d", len(response.Data.Data))
+	}
+	for _, item := range response.Data.Data {
+		if item.Owner != nil {
+			t.Errorf("Expected a null owner for item %d", item.ID)
+		}
+	}
+	if len(response.Errors) != 3 {
+		t.Fatalf("Expected one error per owner, got %+v", response.Errors)
+	}
+	if e := response.Errors[0]; e.Message != "DataItem.owner resolver failed" || len(e.Path) != 3 {
+		t.Errorf("Unexpected error %+v", e)
+	}
+	if got := testutil.ToFloat64(testServer.Metrics().GraphQLResolverErrors.WithLabelValues("DataItem.owner")); got != 3 {
+		t.Errorf("Expected 3 resolver errors to be counted, got %v", got)
+	}
+}
+
+func TestParseResolvers(t *testing.T) {
+	resolvers, err := config.ParseResolvers("Query.users=delay:50;error:0.1, *=delay:20")
+	if err != nil {
+		t.Fatalf("ParseResolvers failed: %v", err)
+	}
+	cfg := &config.Config{GraphQLFields: resolvers}
+	if got := cfg.ResolverFor("Query.users"); got != (config.Resolver{Delay: 50, ErrorRate: 0.1}) {
+		t.Errorf("Unexpected Query.users resolver: %+v", got)
+	}
+	if got := cfg.ResolverFor("DataItem.owner"); got.Delay != 20 {
+		t.Errorf("Expected the * resolver for other fields, got %+v", got)
+	}
+
+	for _, invalid := range []string{"Query.users", "Query.users=error:2", "Query.users=speed:1"} {
+		if _, err := config.ParseResolvers(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/slowpb"
+	"github.com/Unic-X/slow-server/slowserver"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// startGRPCServer serves testCfg's gRPC API on a random port, on clk
+func startGRPCServer(t *testing.T, testCfg *config.Config, clk clock.Clock) (*slowserver.Server, slowpb.SlowServerClient) {
+	server := slowserver.New(
+		slowserver.WithConfig(testCfg),
+		slowserver.WithClock(clk),
+		slowserver.WithSeed(1),
+		slowserver.WithAddr("127.0.0.1:0"),
+		slowserver.WithGRPCAddr("127.0.0.1:0"),
+	)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	})
+
+	conn, err := grpc.NewClient(server.GRPCAddr(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect to the gRPC server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return server, slowpb.NewSlowServerClient(conn)
+}
+
+func TestGRPCGetUsersAndData(t *testing.T) {
+	_, client := startGRPCServer(t, setupTestConfig(), fakeClock)
+
+	var header metadata.MD
+	users, err := client.GetUsers(context.Background(), &slowpb.ListRequest{Limit: 3}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("GetUsers failed: %v", err)
+	}
+	if users.Count != 3 || users.Total != 10 || len(users.Users) != 3 {
+		t.Errorf("Expected 3 of 10 users, got %d of %d", users.Count, users.Total)
+	}
+	if len(header.Get("x-slow-seed")) != 1 || len(header.Get("x-request-id")) != 1 {
+		t.Errorf("Expected the seed and request ID in the header metadata, got %v", header)
+	}
+
+	data, err := client.GetData(context.Background(), &slowpb.ListRequest{Filters: map[string]string{"min_value": "50"}})
+	if err != nil {
+		t.Fatalf("GetData failed: %v", err)
+	}
+	for _, item := range data.Data {
+		if item.Value < 50 {
+			t.Errorf("Filter not applied, got item %d with value %v", item.Id, item.Value)
+		}
+	}
+
+	_, err = client.GetData(context.Background(), &slowpb.ListRequest{Filters: map[string]string{"color": "red"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an unknown filter, got %v", err)
+	}
+}
+
+func TestGRPCStatusCodes(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.MaxItems = 2
+	testCfg.MaxProcessBytes = 100
+	_, client := startGRPCServer(t, testCfg, fakeClock)
+
+	tests := []struct {
+		args     map[string]string
+		items    []string
+		expected codes.Code
+	}{
+		{map[string]string{"fail_step": "external:503"}, []string{"a"}, codes.Unavailable},
+		{map[string]string{"fail_step": "db:504"}, []string{"a"}, codes.DeadlineExceeded},
+		{nil, []string{"a", "b", "c"}, codes.ResourceExhausted},
+		{nil, []string{strings.Repeat("x", 101)}, codes.ResourceExhausted},
+		{map[string]string{"fail_step": "process"}, []string{"a"}, codes.Internal},
+	}
+	for _, tt := range tests {
+		_, err := client.ProcessData(context.Background(), &slowpb.ProcessRequest{Items: tt.items, Args: tt.args})
+		if got := status.Code(err); got != tt.expected {
+			t.Errorf("ProcessData(%v, %d items): got %v want %v", tt.args, len(tt.items), got, tt.expected)
+		}
+	}
+}
+
+func TestGRPCSeedMetadataAuthorization(t *testing.T) {
+	seedOf := func(client slowpb.SlowServerClient, pairs ...string) (string, codes.Code) {
+		var header metadata.MD
+		ctx := metadata.AppendToOutgoingContext(context.Background(), pairs...)
+		_, err := client.GetUsers(ctx, &slowpb.ListRequest{Limit: 1}, grpc.Header(&header))
+		return strings.Join(header.Get("x-slow-seed"), ","), status.Code(err)
+	}
+
+	// Disabled: the seed is drawn as usual
+	_, client := startGRPCServer(t, setupTestConfig(), fakeClock)
+	if seed, code := seedOf(client, "x-slow-seed", "42"); code != codes.OK || seed == "42" {
+		t.Errorf("Expected x-slow-seed to be ignored while disabled, got seed %q and %v", seed, code)
+	}
+
+	testCfg := setupTestConfig()
+	testCfg.FaultHeadersEnabled = true
+	testCfg.FaultHeadersSecret = "s3cret"
+	server, client := startGRPCServer(t, testCfg, fakeClock)
+
+	if _, code := seedOf(client, "x-slow-seed", "42"); code != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied without the token, got %v", code)
+	}
+	if _, code := seedOf(client, "x-slow-seed", "42", "x-slow-token", "wrong"); code != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied with a wrong token, got %v", code)
+	}
+	if _, code := seedOf(client, "x-slow-seed", "soon", "x-slow-token", "s3cret"); code != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a bad seed, got %v", code)
+	}
+	if seed, code := seedOf(client, "x-slow-seed", "42", "x-slow-token", "s3cret"); code != codes.OK || seed != "42" {
+		t.Errorf("Expected the forced seed 42, got seed %q and %v", seed, code)
+	}
+	if got := testutil.ToFloat64(server.Metrics().FaultOverridesRejected.WithLabelValues("forbidden")); got != 2 {
+		t.Errorf("Expected 2 refused calls to be counted, got %v", got)
+	}
+
+	// Allow-listed peers need no token
+	testCfg = setupTestConfig()
+	testCfg.FaultHeadersEnabled = true
+	testCfg.FaultHeadersAllow = []string{"127.0.0.1"}
+	_, client = startGRPCServer(t, testCfg, fakeClock)
+	if seed, code := seedOf(client, "x-slow-seed", "42"); code != codes.OK || seed != "42" {
+		t.Errorf("Expected the allow-listed peer's seed 42, got seed %q and %v", seed, code)
+	}
+}
+
+func TestGRPCStreamProcessData(t *testing.T) {
+	_, client := startGRPCServer(t, setupTestConfig(), fakeClock)
+
+	stream, err := client.StreamProcessData(context.Background(), &slowpb.ProcessRequest{
+		Items: []string{"a", "bb", "ccc"},
+		Args:  map[string]string{"fail_items": "1", "item_error_rate": "0"},
+	})
+	if err != nil {
+		t.Fatalf("StreamProcessData failed: %v", err)
+	}
+
+	var statuses []string
+	for {
+		result, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		if int(result.Index) != len(statuses) {
+			t.Errorf("Expected item %d, got %d", len(statuses), result.Index)
+		}
+		statuses = append(statuses, result.Status)
+	}
+	if len(statuses) != 3 || statuses[0] != "ok" || statuses[1] != "failed" || statuses[2] != "ok" {
+		t.Errorf("Unexpected item results: %v", statuses)
+	}
+}
+
+func TestGRPCHonorsTimeout(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.DBQueryDelay = 10000
+	server, client := startGRPCServer(t, testCfg, clock.Real)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := client.GetData(ctx, &slowpb.ListRequest{})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("Expected DeadlineExceeded, got %v", err)
+	}
+
+	// The server gives up on the 5-10s DB query once the grpc-timeout passes
+	m := server.Metrics()
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(m.DBQueriesTotal) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(m.DBQueriesTotal); got != 1 {
+		t.Fatalf("Expected the DB query to be cut short, got %v finished", got)
+	}
+	if got := histogramSampleSum(t, m.DBQueryDuration); got > 1000 {
+		t.Errorf("Expected the DB query to stop at the deadline, took %vms", got)
+	}
+}
+
+func TestGRPCServerMetrics(t *testing.T) {
+	server, client := startGRPCServer(t, setupTestConfig(), fakeClock)
+
+	if _, err := client.GetUsers(context.Background(), &slowpb.ListRequest{}); err != nil {
+		t.Fatalf("GetUsers failed: %v", err)
+	}
+
+	if n, err := testutil.GatherAndCount(server.Registry(), "grpc_server_handled_total"); err != nil || n == 0 {
+		t.Errorf("Expected grpc_server_handled_total on the registry, got %d series: %v", n, err)
+	}
+	if n := testutil.CollectAndCount(server.Metrics().GRPC, "grpc_server_handling_seconds"); n == 0 {
+		t.Error("Expected the gRPC handling time histogram")
+	}
+}
@@ -224,9 +224,9 @@ func TestIntegrationProcessData(t *testing.T) {
 		t.Error("Expected success to be true, got false")
 	}
 
-	// Check process ID
-	if processResp.ProcessID == 0 {
-		t.Error("Expected non-zero process ID, got 0")
+	// Check per-item results
+	if len(processResp.Results) != len(requestBody.Items) {
+		t.Errorf("Expected %d item results, got %d", len(requestBody.Items), len(processResp.Results))
 	}
 }
 
@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/api"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAsyncProcessJob(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.CallbackHosts = []string{"127.0.0.1"}
+
+	callbacks := make(chan models.Job, 1)
+	callbackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var job models.Job
+		if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+			t.Errorf("Failed to decode callback body: %v", err)
+		}
+		callbacks <- job
+	}))
+	defer callbackServer.Close()
+
+	router := http.NewServeMux()
+	router.HandleFunc("/api/process", testServer.ProcessDataHandler)
+	router.HandleFunc("GET /api/jobs/{id}", testServer.GetJobHandler)
+
+	requestBody := `{"items": ["item1"], "callback_url": "` + callbackServer.URL + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/process?async=true", strings.NewReader(requestBody))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("async process returned wrong status code: got %v want %v", rr.Code, http.StatusAccepted)
+	}
+	var queued models.Job
+	if err := json.Unmarshal(rr.Body.Bytes(), &queued); err != nil {
+		t.Fatalf("Failed to parse job: %v", err)
+	}
+	location := rr.Header().Get("Location")
+	if location != "/api/jobs/"+queued.ID {
+		t.Errorf("Unexpected Location header: %q", location)
+	}
+
+	var job models.Job
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != models.JobSucceeded {
+		if time.Now().After(deadline) {
+			t.Fatalf("Job did not finish in time, last status %q", job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, location, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("job status returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+			t.Fatalf("Failed to parse job: %v", err)
+		}
+	}
+
+	if job.Progress != 1 || job.Result == nil {
+		t.Errorf("Finished job should report full progress and a result: %+v", job)
+	}
+
+	select {
+	case notified := <-callbacks:
+		if notified.ID != queued.ID || notified.Status != models.JobSucceeded {
+			t.Errorf("Unexpected callback payload: %+v", notified)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Callback was not delivered")
+	}
+}
+
+func TestUnknownJob(t *testing.T) {
+	setupTestConfig()
+
+	router := http.NewServeMux()
+	router.HandleFunc("GET /api/jobs/{id}", testServer.GetJobHandler)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/jobs/does-not-exist", nil))
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("unknown job returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestCloseDropsQueuedJobs(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.JobWorkers = 0 // Nothing takes the jobs off the queue
+	server := api.NewServer(cfg, api.WithClock(fakeClock))
+	router := http.NewServeMux()
+	server.Routes(router)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/process?async=true", strings.NewReader(`{"items": ["item1"]}`)))
+		var queued models.Job
+		if rr.Code != http.StatusAccepted || json.Unmarshal(rr.Body.Bytes(), &queued) != nil {
+			t.Fatalf("async process returned %d: %s", rr.Code, rr.Body.String())
+		}
+		ids = append(ids, queued.ID)
+	}
+	if got := testutil.ToFloat64(server.Metrics().JobQueueDepth); got != 3 {
+		t.Fatalf("Expected 3 queued jobs, got %v", got)
+	}
+
+	server.Close()
+	if got := testutil.ToFloat64(server.Metrics().JobQueueDepth); got != 0 {
+		t.Errorf("Expected the dropped jobs to leave the queue depth, got %v", got)
+	}
+	for _, id := range ids {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/jobs/"+id, nil))
+		var job models.Job
+		if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil || job.Status != models.JobFailed || job.FinishedAt == nil {
+			t.Errorf("Expected job %s failed on shutdown, got %+v", id, job)
+		}
+	}
+}
+
+func TestAsyncProcessRejectsCallbackURL(t *testing.T) {
+	testCfg := setupTestConfig()
+
+	tests := []struct {
+		hosts    []string
+		url      string
+		expected int
+	}{
+		{nil, "http://127.0.0.1:8080/hook", http.StatusBadRequest},
+		{[]string{"127.0.0.1"}, "ftp://127.0.0.1/hook", http.StatusBadRequest},
+		{[]string{"127.0.0.1"}, "http://169.254.169.254/latest", http.StatusBadRequest},
+		{[]string{"localhost:8443"}, "https://localhost/hook", http.StatusBadRequest},
+		{[]string{"localhost:8443"}, "https://localhost:8443/hook", http.StatusAccepted},
+		{[]string{"127.0.0.1"}, "http://127.0.0.1:8080/hook", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		testCfg.CallbackHosts = tt.hosts
+		requestBody := `{"items": ["item1"], "callback_url": "` + tt.url + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/process?async=true", strings.NewReader(requestBody))
+		rr := httptest.NewRecorder()
+		testServer.ProcessDataHandler(rr, req)
+		if rr.Code != tt.expected {
+			t.Errorf("callback_url %s with hosts %v: got %v want %v", tt.url, tt.hosts, rr.Code, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/live"
+	"github.com/Unic-X/slow-server/models"
+)
+
+func TestLiveStatsPercentiles(t *testing.T) {
+	clk := newFakeClock()
+	stats := live.New(clk)
+
+	for i := 1; i <= 100; i++ {
+		status := http.StatusOK
+		if i%10 == 0 {
+			status = http.StatusInternalServerError
+		}
+		stats.RecordRoute("GET /api/data", status, time.Duration(i)*time.Millisecond)
+		if i%20 == 0 {
+			clk.Advance(time.Second)
+		}
+	}
+	stats.RecordDependency("db", true, 5*time.Millisecond)
+
+	routes, deps := stats.Snapshot()
+	if len(routes) != 1 || len(deps) != 1 {
+		t.Fatalf("Expected one route and one dependency, got %+v and %+v", routes, deps)
+	}
+	r := routes[0]
+	if r.Requests != 100 || r.Errors != 10 || r.ErrorRate != 0.1 {
+		t.Errorf("Expected 100 requests with 10 errors, got %+v", r)
+	}
+	if math.Abs(r.Rate-100/live.Window.Seconds()) > 1e-9 {
+		t.Errorf("Expected %v requests per second, got %v", 100/live.Window.Seconds(), r.Rate)
+	}
+	for _, q := range []struct{ got, want float64 }{{r.P50Ms, 50}, {r.P95Ms, 95}, {r.P99Ms, 99}} {
+		if math.Abs(q.got-q.want) > 1e-9 {
+			t.Errorf("Expected percentile %vms, got %vms", q.want, q.got)
+		}
+	}
+	if deps[0].Name != "db" || deps[0].ErrorRate != 1 {
+		t.Errorf("Expected the failed db call, got %+v", deps[0])
+	}
+
+	// Everything slides out of the window
+	clk.Advance(live.Window)
+	routes, deps = stats.Snapshot()
+	if len(routes) != 0 || len(deps) != 0 {
+		t.Errorf("Expected an empty window, got %+v and %+v", routes, deps)
+	}
+}
+
+func TestLiveStatsEndpoint(t *testing.T) {
+	ts := newSlowTestServer(t, setupTestConfig())
+	for _, path := range []string{"/api/users/1", "/api/users/2", "/api/users/999", "/api/users"} {
+		getStatus(t, ts.URL+path, nil)
+	}
+
+	resp, err := http.Get(ts.AdminURL + "/admin/stats")
+	if err != nil {
+		t.Fatalf("GET /admin/stats failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var stats models.LiveStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to parse stats: %v", err)
+	}
+
+	byName := map[string]models.LiveStat{}
+	for _, s := range stats.Routes {
+		byName[s.Name] = s
+	}
+	if got := byName["GET /api/users/{id}"]; got.Requests != 3 || got.Errors != 1 {
+		t.Errorf("Expected 3 user lookups with one 404, got %+v", got)
+	}
+	if got := byName["GET /api/users"]; got.Requests != 1 {
+		t.Errorf("Expected the user list under its method, got %+v", got)
+	}
+	for name := range byName {
+		if strings.Contains(name, "/admin/") {
+			t.Errorf("Expected admin requests left out, got %s", name)
+		}
+	}
+	if len(stats.Dependencies) == 0 || stats.Faults.Degrade.Memory {
+		t.Errorf("Expected dependency stats and no degradation, got %+v", stats)
+	}
+
+	// The page is self-contained
+	resp, err = http.Get(ts.AdminURL + "/admin/ui")
+	if err != nil {
+		t.Fatalf("GET /admin/ui failed: %v", err)
+	}
+	defer resp.Body.Close()
+	page, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(page), `fetch("stats")`) || strings.Contains(string(page), "src=") || strings.Contains(string(page), "href=") {
+		t.Errorf("Expected a page polling stats without external assets")
+	}
+}
+
+func TestLiveStatsShowScenarioPhase(t *testing.T) {
+	ts := newSlowTestServer(t, setupTestConfig())
+	if code, _ := sendScenario(t, ts.AdminURL, http.MethodPost, `{"name":"db-outage","phases":[
+		{"name":"outage","duration_ms":60000,"faults":[{"kind":"fail","step":"db","rate":1,"status":503}]}]}`); code != http.StatusCreated {
+		t.Fatalf("Expected the scenario to start, got %d", code)
+	}
+
+	resp, err := http.Get(ts.AdminURL + "/admin/stats")
+	if err != nil {
+		t.Fatalf("GET /admin/stats failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var stats models.LiveStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to parse stats: %v", err)
+	}
+	if sc := stats.Faults.Scenario; sc == nil || sc.Name != "db-outage" || sc.PhaseName != "outage" || len(sc.Faults) != 1 {
+		t.Errorf("Expected the outage phase in the active faults, got %+v", sc)
+	}
+
+	resp, err = http.Get(ts.AdminURL + "/admin/ui")
+	if err != nil {
+		t.Fatalf("GET /admin/ui failed: %v", err)
+	}
+	defer resp.Body.Close()
+	page, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(page), "f.scenario") || !strings.Contains(string(page), "post('scenario', 'DELETE')") {
+		t.Errorf("Expected the page to show the scenario phase and a stop button")
+	}
+}
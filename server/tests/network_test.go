@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/slowserver"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newNetworkTestServer(t *testing.T, testCfg *config.Config) *slowserver.TestServer {
+	testCfg.FaultHeadersEnabled = true
+	testCfg.FaultHeadersSecret = "s3cret"
+	return newSlowTestServer(t, testCfg)
+}
+
+// getWithNetworkFault requests url forcing the given network fault. Every
+// request gets a fresh connection so a broken one is never reused.
+func getWithNetworkFault(ctx context.Context, url, kind string) (*http.Response, error) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req.Header.Set("X-Slow-Token", "s3cret")
+	if kind != "" {
+		req.Header.Set("X-Slow-Network", kind)
+	}
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	return client.Do(req)
+}
+
+func TestNetworkFaultReset(t *testing.T) {
+	ts := newNetworkTestServer(t, setupTestConfig())
+
+	resp, err := getWithNetworkFault(context.Background(), ts.URL+"/api/users", "reset")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("Expected the connection to be reset, got status %v", resp.StatusCode)
+	}
+	if got := testutil.ToFloat64(ts.Server.Metrics().NetworkFaults.WithLabelValues("reset")); got != 1 {
+		t.Errorf("Expected 1 reset to be counted, got %v", got)
+	}
+}
+
+func TestNetworkFaultHang(t *testing.T) {
+	ts := newNetworkTestServer(t, setupTestConfig())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	resp, err := getWithNetworkFault(ctx, ts.URL+"/api/users", "hang")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("Expected the request to hang, got status %v", resp.StatusCode)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the client to give up waiting, got %v", err)
+	}
+}
+
+func TestNetworkFaultBrokenBodies(t *testing.T) {
+	ts := newNetworkTestServer(t, setupTestConfig())
+
+	for _, kind := range []string{"truncate", "bad_length"} {
+		resp, err := getWithNetworkFault(context.Background(), ts.URL+"/api/users", kind)
+		if err != nil {
+			t.Fatalf("%s: expected the headers to arrive, got %v", kind, err)
+		}
+		_, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: expected the body to end early, got %v", kind, err)
+		}
+	}
+
+	resp, err := getWithNetworkFault(context.Background(), ts.URL+"/api/users", "invalid_json")
+	if err != nil {
+		t.Fatalf("invalid_json: expected a response, got %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("invalid_json returned wrong status code: got %v want %v", resp.StatusCode, http.StatusOK)
+	}
+	var body map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil {
+		t.Error("Expected the body to be invalid JSON")
+	}
+}
+
+func TestNetworkFaultBrokenStream(t *testing.T) {
+	ts := newNetworkTestServer(t, setupTestConfig())
+
+	for _, kind := range []string{"truncate", "invalid_json", "bad_length"} {
+		resp, err := getWithNetworkFault(context.Background(), ts.URL+"/api/stream?count=3", kind)
+		if err != nil {
+			t.Fatalf("%s: expected the stream to start, got %v", kind, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s: expected a 200 stream ending early, got %v and %v", kind, resp.StatusCode, err)
+		}
+		if !strings.HasPrefix(string(body), "id: 1") || strings.Contains(string(body), "\n\n") {
+			t.Errorf("%s: expected the stream cut inside its first event, got %q", kind, body)
+		}
+	}
+}
+
+func TestNetworkFaultRates(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.NetInvalidJSONRate = 1
+	ts := newNetworkTestServer(t, testCfg)
+
+	for i := 0; i < 3; i++ {
+		resp, err := getWithNetworkFault(context.Background(), ts.URL+"/api/users", "")
+		if err != nil {
+			t.Fatalf("GET /api/users failed: %v", err)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&body); err == nil {
+			t.Error("Expected every API response to be invalid JSON")
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/metrics returned wrong status code: got %v want %v", resp.StatusCode, http.StatusOK)
+	}
+	if got := testutil.ToFloat64(ts.Server.Metrics().NetworkFaults.WithLabelValues("invalid_json")); got != 3 {
+		t.Errorf("Expected 3 invalid JSON responses to be counted, got %v", got)
+	}
+}
+
+func TestNetworkFaultInvalidHeader(t *testing.T) {
+	ts := newNetworkTestServer(t, setupTestConfig())
+
+	resp, err := getWithNetworkFault(context.Background(), ts.URL+"/api/users", "unplug")
+	if err != nil {
+		t.Fatalf("GET /api/users failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid network fault accepted: got %v want %v", resp.StatusCode, http.StatusBadRequest)
+	}
+}
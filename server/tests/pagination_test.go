@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/store"
+)
+
+func TestCursorPaginationVisitsEveryRowOnce(t *testing.T) {
+	s := store.New(95, 7)
+
+	seen := map[int]bool{}
+	query := store.Query{Sort: "-value", Limit: 10}
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("Cursor pagination did not terminate")
+		}
+		page, err := s.QueryData(query)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		for _, item := range page.Items {
+			if seen[item.ID] {
+				t.Fatalf("Item %d returned twice", item.ID)
+			}
+			seen[item.ID] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	if len(seen) != 95 {
+		t.Errorf("Expected to visit 95 items, visited %d", len(seen))
+	}
+}
+
+func TestOffsetScansMoreThanCursor(t *testing.T) {
+	s := store.New(200, 1)
+
+	first, err := s.QueryUsers(store.Query{Limit: 150})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	byOffset, err := s.QueryUsers(store.Query{Offset: 150, Limit: 10})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	byCursor, err := s.QueryUsers(store.Query{Cursor: first.NextCursor, Limit: 10})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	if byOffset.Items[0].ID != byCursor.Items[0].ID {
+		t.Errorf("Offset and cursor pages differ: %d vs %d", byOffset.Items[0].ID, byCursor.Items[0].ID)
+	}
+	if byOffset.Scanned <= byCursor.Scanned {
+		t.Errorf("Expected deep offset to scan more rows than cursor: %d vs %d", byOffset.Scanned, byCursor.Scanned)
+	}
+}
+
+func TestListFilterAndSort(t *testing.T) {
+	setupTestConfig()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/data?min_value=50&sort=-value&limit=3", nil)
+	rr := httptest.NewRecorder()
+	testServer.GetDataHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var response models.DataResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	if response.Count > 3 {
+		t.Errorf("Expected at most 3 items, got %d", response.Count)
+	}
+	for i, item := range response.Data {
+		if item.Value < 50 {
+			t.Errorf("Item %d with value %v escaped min_value filter", item.ID, item.Value)
+		}
+		if i > 0 && item.Value > response.Data[i-1].Value {
+			t.Errorf("Items not sorted by descending value")
+		}
+	}
+}
+
+func TestListRejectsUnknownSort(t *testing.T) {
+	setupTestConfig()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users?sort=password", nil)
+	rr := httptest.NewRecorder()
+	testServer.GetUsersHandler(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown sort accepted: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(rr.Body.String(), "password") {
+		t.Errorf("Expected error to name the field, got %q", rr.Body.String())
+	}
+}
+
+func TestCursorRejectsDifferentSort(t *testing.T) {
+	setupTestConfig()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/data?sort=-value&limit=3", nil)
+	rr := httptest.NewRecorder()
+	testServer.GetDataHandler(rr, req)
+
+	var response models.DataResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	if response.NextCursor == "" {
+		t.Fatal("Expected a next cursor")
+	}
+
+	tests := []struct {
+		sort     string
+		expected int
+	}{
+		{"-value", http.StatusOK},
+		{"value", http.StatusBadRequest},
+		{"name", http.StatusBadRequest},
+		{"", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/data?limit=3&sort="+tt.sort+"&cursor="+response.NextCursor, nil)
+		rr := httptest.NewRecorder()
+		testServer.GetDataHandler(rr, req)
+		if rr.Code != tt.expected {
+			t.Errorf("Cursor from sort=-value with sort=%q: got %v want %v", tt.sort, rr.Code, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func postProcess(t *testing.T, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/process", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	testServer.ProcessDataHandler(rr, req)
+	return rr
+}
+
+func TestProcessPartialSuccess(t *testing.T) {
+	setupTestConfig()
+
+	rr := postProcess(t, `{"items": ["a", "b", "c"], "args": {"fail_items": "1"}}`)
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("partial failure returned wrong status code: got %v want %v", rr.Code, http.StatusMultiStatus)
+	}
+
+	var response models.ProcessResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response body: %v", err)
+	}
+	if response.Success || response.Processed != 2 || response.Failed != 1 {
+		t.Errorf("Unexpected totals: %+v", response)
+	}
+	if response.Results[1].Status != models.ItemFailed || response.Results[0].Status != models.ItemOK {
+		t.Errorf("Wrong item failed: %+v", response.Results)
+	}
+}
+
+func TestProcessAllItemsFail(t *testing.T) {
+	setupTestConfig()
+
+	rr := postProcess(t, `{"items": ["a", "b"], "args": {"item_error_rate": "1"}}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("total failure returned wrong status code: got %v want %v", rr.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestProcessFailStep(t *testing.T) {
+	setupTestConfig()
+
+	rr := postProcess(t, `{"items": ["a"], "args": {"fail_step": "external:503"}}`)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("fail_step returned wrong status code: got %v want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+
+	rr = postProcess(t, `{"items": ["a"], "args": {"fail_step": "db"}}`)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("fail_step=db returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+
+	// Body args are not fault headers
+	for _, step := range []string{"external", "db"} {
+		if got := testutil.ToFloat64(testServer.Metrics().FaultOverrides.WithLabelValues("fail", step)); got != 0 {
+			t.Errorf("Expected fail_step on %s not to count as a header override, got %v", step, got)
+		}
+	}
+}
+
+func TestProcessInvalidArgs(t *testing.T) {
+	setupTestConfig()
+
+	for _, args := range []string{
+		`{"fail_step": "cache"}`,
+		`{"fail_items": "7"}`,
+		`{"item_error_rate": "2"}`,
+	} {
+		rr := postProcess(t, `{"items": ["a"], "args": `+args+`}`)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("args %s accepted: got %v want %v", args, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestProcessCostScalesWithItems(t *testing.T) {
+	setupTestConfig()
+
+	start := fakeClock.Now()
+	rr := postProcess(t, `{"items": ["a", "b", "c", "d", "e"], "args": {"item_delay_ms": "20"}}`)
+	duration := fakeClock.Since(start)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if minExpected := 100 * time.Millisecond; duration < minExpected {
+		t.Errorf("5 items at 20ms each finished too fast: %v, expected at least %v", duration, minExpected)
+	}
+}
+
+func TestProcessRunsBothProcessingSteps(t *testing.T) {
+	setupTestConfig()
+
+	rr := postProcess(t, `{"items": ["a"]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if got := histogramSampleCount(t, testServer.Metrics().ProcessingDuration); got != 2 {
+		t.Errorf("Expected the processing and secondary processing steps, got %d", got)
+	}
+}
+
+func TestProcessRejectsLargeRequests(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.MaxItems = 3
+	testCfg.MaxProcessBytes = 100
+
+	for name, body := range map[string]string{
+		"too many items": `{"items": ["a", "b", "c", "d"]}`,
+		"body too large": `{"items": ["` + strings.Repeat("x", 200) + `"]}`,
+	} {
+		if rr := postProcess(t, body); rr.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: got %v want %v", name, rr.Code, http.StatusRequestEntityTooLarge)
+		}
+	}
+	if rr := postProcess(t, `{"items": ["a", "b", "c"]}`); rr.Code != http.StatusOK {
+		t.Errorf("Expected a request within the limits to pass, got %v", rr.Code)
+	}
+}
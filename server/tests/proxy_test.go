@@ -0,0 +1,238 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/trace"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestProxyForwardsWithInjectedDelay(t *testing.T) {
+	var got *http.Request
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Clone(r.Context())
+		io.WriteString(w, "from upstream "+r.URL.RequestURI())
+	}))
+	defer upstream.Close()
+
+	testCfg := setupTestConfig()
+	testCfg.ProxyUpstream = upstream.URL
+	testCfg.ProxyRules, _ = config.ParseProxyRules("GET /slow*=delay:300")
+	p := newHopTestServer(t, testCfg, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, p.URL+"/slow/orders?id=7", nil)
+	req.Header.Set(trace.Header, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("X-Slow-Token", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET through the proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || string(body) != "from upstream /slow/orders?id=7" {
+		t.Fatalf("Expected the upstream's answer, got %d %q", resp.StatusCode, body)
+	}
+	if got.Header.Get("X-Slow-Token") != "" || got.Header.Get("X-Forwarded-For") == "" {
+		t.Errorf("Expected the override headers dropped and X-Forwarded-For set, got %v", got.Header)
+	}
+	if tc, ok := trace.Parse(got.Header.Get(trace.Header)); !ok || tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the trace carried on upstream, got %q", got.Header.Get(trace.Header))
+	}
+
+	m := p.Server.Metrics()
+	if got := histogramSampleSum(t, m.ProxyInjectedDelay.WithLabelValues("GET /slow*").(prometheus.Histogram)); got != 300 {
+		t.Errorf("Expected 300ms injected by the rule, got %vms", got)
+	}
+	if got := histogramSampleCount(t, m.ProxyUpstreamDuration.WithLabelValues("GET /slow*").(prometheus.Histogram)); got != 1 {
+		t.Errorf("Expected the upstream call to be timed once, got %d", got)
+	}
+	if got := testutil.ToFloat64(m.ProxyRequests.WithLabelValues("GET /slow*", "forwarded")); got != 1 {
+		t.Errorf("Expected 1 forwarded request, got %v", got)
+	}
+
+	// Unmatched requests get MIN_DELAY to MAX_DELAY
+	if status := getStatus(t, p.URL+"/fast", nil); status != http.StatusOK {
+		t.Errorf("Expected 200 for an unmatched path, got %d", status)
+	}
+	if got := histogramSampleSum(t, m.ProxyInjectedDelay.WithLabelValues("default").(prometheus.Histogram)); got < 10 || got > 50 {
+		t.Errorf("Expected the default delay between 10 and 50ms, got %vms", got)
+	}
+
+	// Request metrics are labelled by rule, not by every upstream path
+	getStatus(t, p.URL+"/slow/orders?id=8", nil)
+	getStatus(t, p.URL+"/faster", nil)
+	if got := testutil.ToFloat64(m.RequestsTotal.WithLabelValues("GET /slow*", "GET", "200")); got != 2 {
+		t.Errorf("Expected both /slow requests under their rule, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.RequestsTotal.WithLabelValues("default", "GET", "200")); got != 2 {
+		t.Errorf("Expected both unmatched requests under default, got %v", got)
+	}
+	for _, h := range []*prometheus.HistogramVec{m.RequestDuration, m.ResponseTTFB, m.ResponseTransfer} {
+		if got := testutil.CollectAndCount(h); got != 2 {
+			t.Errorf("Expected one series per rule, got %d", got)
+		}
+	}
+}
+
+func TestProxyInjectsErrors(t *testing.T) {
+	hits := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer upstream.Close()
+
+	testCfg := setupTestConfig()
+	testCfg.ProxyUpstream = upstream.URL
+	testCfg.ProxyRules, _ = config.ParseProxyRules("POST /orders=error:1;status:429")
+	p := newHopTestServer(t, testCfg, nil)
+
+	resp, err := http.Post(p.URL+"/orders", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("POST through the proxy failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || hits != 0 {
+		t.Errorf("Expected a 429 without reaching the upstream, got %d after %d upstream hits", resp.StatusCode, hits)
+	}
+
+	// The rule is for POST only, and the server's own endpoints are not proxied
+	if status := getStatus(t, p.URL+"/orders", nil); status != http.StatusOK || hits != 1 {
+		t.Errorf("Expected GET /orders forwarded, got %d", status)
+	}
+	if status := getStatus(t, p.URL+"/metrics", nil); status != http.StatusOK || hits != 1 {
+		t.Errorf("Expected /metrics served by the proxy itself, got %d", status)
+	}
+	if got := testutil.ToFloat64(p.Server.Metrics().ProxyRequests.WithLabelValues("POST /orders", "injected_error")); got != 1 {
+		t.Errorf("Expected 1 injected error, got %v", got)
+	}
+}
+
+func TestProxyOneOffFaultsAndScenarios(t *testing.T) {
+	hits := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer upstream.Close()
+
+	testCfg := setupTestConfig()
+	testCfg.ProxyUpstream = upstream.URL
+	testCfg.ProxyRules, _ = config.ParseProxyRules("*=delay:0")
+	p := newHopTestServer(t, testCfg, nil)
+	m := p.Server.Metrics()
+	inject := func(body string) {
+		resp, err := http.Post(p.AdminURL+"/admin/faults", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST /admin/faults failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected the fault injected, got %d", resp.StatusCode)
+		}
+	}
+
+	// A one-off delay of the upstream step is added before forwarding
+	inject(`{"id":"slow","kind":"delay","step":"upstream","rate":1,"delay_ms":400,"duration_ms":60000}`)
+	if status := getStatus(t, p.URL+"/orders", nil); status != http.StatusOK || hits != 1 {
+		t.Errorf("Expected the delayed request forwarded, got %d after %d upstream hits", status, hits)
+	}
+	if got := histogramSampleSum(t, m.ProxyInjectedDelay.WithLabelValues("*").(prometheus.Histogram)); got != 400 {
+		t.Errorf("Expected the injected 400ms, got %vms", got)
+	}
+
+	// A one-off failure answers without reaching the upstream
+	inject(`{"id":"down","kind":"fail","step":"upstream","rate":1,"status":503,"duration_ms":60000}`)
+	if status := getStatus(t, p.URL+"/orders", nil); status != http.StatusServiceUnavailable || hits != 1 {
+		t.Errorf("Expected the injected 503 without reaching the upstream, got %d after %d upstream hits", status, hits)
+	}
+	req, _ := http.NewRequest(http.MethodDelete, p.AdminURL+"/admin/faults", nil)
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+	}
+
+	// So does a scenario phase failing the upstream step
+	if code, _ := sendScenario(t, p.AdminURL, http.MethodPost,
+		`{"name":"upstream-outage","phases":[{"duration_ms":60000,"faults":[{"kind":"fail","step":"upstream","rate":1,"status":429}]}]}`); code != http.StatusCreated {
+		t.Fatalf("Expected the scenario started, got %d", code)
+	}
+	if status := getStatus(t, p.URL+"/orders", nil); status != http.StatusTooManyRequests || hits != 1 {
+		t.Errorf("Expected the scenario's 429 without reaching the upstream, got %d after %d upstream hits", status, hits)
+	}
+	if got := testutil.ToFloat64(m.ProxyRequests.WithLabelValues("*", "injected_error")); got != 2 {
+		t.Errorf("Expected 2 injected errors, got %v", got)
+	}
+}
+
+func TestProxyUpstreamDown(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close()
+
+	testCfg := setupTestConfig()
+	testCfg.ProxyUpstream = upstream.URL
+	p := newHopTestServer(t, testCfg, nil)
+
+	if status := getStatus(t, p.URL+"/anything", nil); status != http.StatusBadGateway {
+		t.Errorf("Expected 502 with the upstream down, got %d", status)
+	}
+	if got := testutil.ToFloat64(p.Server.Metrics().ProxyRequests.WithLabelValues("default", "upstream_error")); got != 1 {
+		t.Errorf("Expected 1 upstream error, got %v", got)
+	}
+}
+
+func TestParseProxyRules(t *testing.T) {
+	rules, err := config.ParseProxyRules("get /api/*=delay:200-800;error:0.1;status:500;bps:1000, /health=delay:0, *=delay:50")
+	if err != nil {
+		t.Fatalf("ParseProxyRules failed: %v", err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("Expected 3 rules, got %d", len(rules))
+	}
+	first := rules[0]
+	if first.Method != "GET" || first.MinDelay != 200 || first.MaxDelay != 800 || first.ErrorRate != 0.1 ||
+		first.ErrorStatus != 500 || first.Throttle.BytesPerSec != 1000 {
+		t.Errorf("Unexpected first rule: %+v", first)
+	}
+	if !first.Matches("GET", "/api/users") || first.Matches("POST", "/api/users") || first.Matches("GET", "/apix") {
+		t.Errorf("Unexpected matches for %s", first)
+	}
+	if rules[1].ErrorStatus != 503 || !rules[1].Matches("DELETE", "/health") || rules[1].Matches("GET", "/health/x") {
+		t.Errorf("Unexpected second rule: %+v", rules[1])
+	}
+	if !rules[2].Matches("PUT", "/anything") {
+		t.Error("Expected * to match every request")
+	}
+
+	for _, invalid := range []string{"api=delay:1", "/x=delay:9-3", "/x=status:200", "/x=error:2", "/x=speed:1"} {
+		if _, err := config.ParseProxyRules(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestProxyKeepsAdminLocal(t *testing.T) {
+	hits := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer upstream.Close()
+
+	testCfg := setupTestConfig()
+	testCfg.ProxyUpstream = upstream.URL
+	p := newHopTestServer(t, testCfg, nil)
+
+	if got := getStatus(t, p.URL+"/admin/config", nil); got != http.StatusNotFound {
+		t.Errorf("Expected the admin API missing from the public port, got %d", got)
+	}
+	if got := getStatus(t, p.URL+"/admin/slo", nil); got != http.StatusOK {
+		t.Errorf("Expected the SLO report served locally, got %d", got)
+	}
+	if hits != 0 {
+		t.Errorf("Expected no admin request forwarded upstream, got %d", hits)
+	}
+}
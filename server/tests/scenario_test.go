@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/slowserver"
+)
+
+// sendScenario sends body to /admin/scenario and returns the status code
+// and the scenario status in the response
+func sendScenario(t *testing.T, adminURL, method, body string) (int, *models.ScenarioStatus) {
+	req, _ := http.NewRequest(method, adminURL+"/admin/scenario", strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s /admin/scenario failed: %v", method, err)
+	}
+	defer resp.Body.Close()
+
+	var status *models.ScenarioStatus
+	if resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatalf("Failed to parse the scenario status: %v", err)
+		}
+	}
+	return resp.StatusCode, status
+}
+
+func TestScenarioPhases(t *testing.T) {
+	clk := newFakeClock()
+	ts := newSlowTestServer(t, setupTestConfig(), slowserver.WithClock(clk))
+
+	code, status := sendScenario(t, ts.AdminURL, http.MethodPost, `{"name":"db-outage","phases":[
+		{"name":"outage","duration_ms":60000,"faults":[{"kind":"fail","step":"db","rate":1,"status":503}]},
+		{"name":"recovery","duration_ms":30000}
+	]}`)
+	if code != http.StatusCreated || status == nil || status.Phase != 1 || status.Phases != 2 || len(status.Faults) != 1 {
+		t.Fatalf("Expected the scenario in its first phase, got %d %+v", code, status)
+	}
+	if got := getStatus(t, ts.URL+"/api/users/1", nil); got != http.StatusServiceUnavailable {
+		t.Errorf("Expected the outage phase to fail the DB step with 503, got %d", got)
+	}
+
+	clk.Advance(time.Minute)
+	if _, status = sendScenario(t, ts.AdminURL, http.MethodGet, ""); status == nil || status.Phase != 2 || status.PhaseName != "recovery" {
+		t.Errorf("Expected the recovery phase, got %+v", status)
+	}
+	if got := getStatus(t, ts.URL+"/api/users/1", nil); got != http.StatusOK {
+		t.Errorf("Expected the recovery phase to inject nothing, got %d", got)
+	}
+
+	// The scenario ends after its last phase
+	clk.Advance(30 * time.Second)
+	if _, status = sendScenario(t, ts.AdminURL, http.MethodGet, ""); status != nil {
+		t.Errorf("Expected the scenario to be over, got %+v", status)
+	}
+}
+
+func TestScenarioRepeatAndStop(t *testing.T) {
+	clk := newFakeClock()
+	ts := newSlowTestServer(t, setupTestConfig(), slowserver.WithClock(clk))
+
+	sendScenario(t, ts.AdminURL, http.MethodPost, `{"name":"flap","repeat":true,"phases":[
+		{"duration_ms":10000,"faults":[{"kind":"fail","step":"db","rate":1}]},
+		{"duration_ms":10000}
+	]}`)
+	clk.Advance(25 * time.Second)
+	if _, status := sendScenario(t, ts.AdminURL, http.MethodGet, ""); status == nil || status.Phase != 1 ||
+		!status.PhaseUntil.Equal(clk.Now().Add(5*time.Second)) {
+		t.Errorf("Expected the first phase again 5s before its end, got %+v", status)
+	}
+	if got := getStatus(t, ts.URL+"/api/users/1", nil); got != http.StatusInternalServerError {
+		t.Errorf("Expected the repeated phase to fail the DB step, got %d", got)
+	}
+
+	// A one-off fault takes precedence over the scenario
+	resp, err := http.Post(ts.AdminURL+"/admin/faults", "application/json",
+		strings.NewReader(`{"kind":"fail","step":"db","rate":1,"status":429,"duration_ms":1000}`))
+	if err != nil {
+		t.Fatalf("POST /admin/faults failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := getStatus(t, ts.URL+"/api/users/1", nil); got != http.StatusTooManyRequests {
+		t.Errorf("Expected the one-off fault's 429, got %d", got)
+	}
+	clk.Advance(time.Second)
+
+	if code, status := sendScenario(t, ts.AdminURL, http.MethodDelete, ""); code != http.StatusOK || status != nil {
+		t.Errorf("Expected the scenario stopped, got %d %+v", code, status)
+	}
+	if got := getStatus(t, ts.URL+"/api/users/1", nil); got != http.StatusOK {
+		t.Errorf("Expected no fault after stopping the scenario, got %d", got)
+	}
+}
+
+func TestScenarioValidation(t *testing.T) {
+	ts := newSlowTestServer(t, setupTestConfig())
+	for _, invalid := range []string{
+		`{"phases":[{"duration_ms":1000}]}`,
+		`{"name":"empty"}`,
+		`{"name":"no-duration","phases":[{"faults":[{"kind":"fail","step":"db","rate":1}]}]}`,
+		`{"name":"bad-fault","phases":[{"duration_ms":1000,"faults":[{"kind":"fail","step":"nope","rate":1}]}]}`,
+		`{"name":"bad-rate","phases":[{"duration_ms":1000,"faults":[{"kind":"delay","step":"db","rate":2,"delay_ms":10}]}]}`,
+		`not json`,
+	} {
+		if code, _ := sendScenario(t, ts.AdminURL, http.MethodPost, invalid); code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected with 400, got %d", invalid, code)
+		}
+	}
+	if _, status := sendScenario(t, ts.AdminURL, http.MethodGet, ""); status != nil {
+		t.Errorf("Expected no scenario running, got %+v", status)
+	}
+}
+
+func TestOneOffFaultIDs(t *testing.T) {
+	ts := newSlowTestServer(t, setupTestConfig())
+	send := func(method, path, body string) int {
+		req, _ := http.NewRequest(method, ts.AdminURL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	fault := `{"id":"drill-1","kind":"fail","step":"db","rate":1,"duration_ms":60000}`
+	if got := send(http.MethodPost, "/admin/faults", fault); got != http.StatusCreated {
+		t.Fatalf("Expected the named fault injected, got %d", got)
+	}
+	if got := send(http.MethodPost, "/admin/faults", fault); got != http.StatusConflict {
+		t.Errorf("Expected a second fault with the same id to be refused with 409, got %d", got)
+	}
+	if got := send(http.MethodPost, "/admin/faults", `{"id":"no spaces","kind":"fail","step":"db","rate":1,"duration_ms":60000}`); got != http.StatusBadRequest {
+		t.Errorf("Expected an invalid id to be rejected with 400, got %d", got)
+	}
+	if got := send(http.MethodDelete, "/admin/faults?id=drill-2", ""); got != http.StatusNotFound {
+		t.Errorf("Expected an unknown id to answer 404, got %d", got)
+	}
+	if got := send(http.MethodDelete, "/admin/faults?id=drill-1", ""); got != http.StatusOK {
+		t.Errorf("Expected the fault removed, got %d", got)
+	}
+	if got := send(http.MethodDelete, "/admin/faults?id=drill-1", ""); got != http.StatusNotFound {
+		t.Errorf("Expected the removed fault to be gone, got %d", got)
+	}
+}
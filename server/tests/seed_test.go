@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/faults"
+	"github.com/Unic-X/slow-server/middleware"
+	"github.com/Unic-X/slow-server/random"
+)
+
+// seededRun sends n requests through a fresh seed middleware and returns the
+// status codes and echoed seeds
+func seededRun(seed int64, n int) (statuses []int, seeds []string) {
+	handler := middleware.ApplySeedMiddleware(http.HandlerFunc(testServer.GetDataHandler), random.New(seed))
+
+	for i := 0; i < n; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/data", nil))
+		statuses = append(statuses, rr.Code)
+		seeds = append(seeds, rr.Header().Get(faults.SeedHeader))
+	}
+	return statuses, seeds
+}
+
+func TestSameSeedReproducesRun(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.SimulateErrors = true
+	testCfg.ErrorRate = 0.5
+
+	firstStatuses, firstSeeds := seededRun(42, 12)
+	secondStatuses, secondSeeds := seededRun(42, 12)
+
+	if !reflect.DeepEqual(firstSeeds, secondSeeds) {
+		t.Errorf("Same seed derived different request seeds: %v vs %v", firstSeeds, secondSeeds)
+	}
+	if !reflect.DeepEqual(firstStatuses, secondStatuses) {
+		t.Errorf("Same seed produced different outcomes: %v vs %v", firstStatuses, secondStatuses)
+	}
+
+	_, otherSeeds := seededRun(43, 12)
+	if reflect.DeepEqual(firstSeeds, otherSeeds) {
+		t.Error("Different seeds derived the same request seeds")
+	}
+}
+
+func TestEchoedSeedReplaysRequest(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.SimulateErrors = true
+	testCfg.ErrorRate = 0.5
+	testCfg.FaultHeadersEnabled = true
+	testCfg.FaultHeadersAllow = []string{"192.0.2.1"}
+
+	statuses, seeds := seededRun(7, 12)
+
+	handler := middleware.ApplyFaultHeadersMiddleware(
+		middleware.ApplySeedMiddleware(http.HandlerFunc(testServer.GetDataHandler), random.New(99)), config.NewCurrent(testCfg), testServer.Metrics())
+
+	for i, seed := range seeds {
+		req := httptest.NewRequest(http.MethodGet, "/api/data", nil)
+		req.Header.Set(faults.SeedHeader, seed)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != statuses[i] {
+			t.Errorf("Replaying seed %s gave %d, original request got %d", seed, rr.Code, statuses[i])
+		}
+		if got := rr.Header().Get(faults.SeedHeader); got != seed {
+			t.Errorf("Replay echoed seed %s, want %s", got, seed)
+		}
+	}
+}
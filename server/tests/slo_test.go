@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/metrics"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/slo"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func testSLOs(t *testing.T) []config.SLO {
+	slos, err := config.ParseSLOs("data-latency=path:/api/data;latency:1000;objective:0.99," +
+		"data-availability=method:get;path:/api/*;objective:0.995")
+	if err != nil {
+		t.Fatalf("ParseSLOs failed: %v", err)
+	}
+	return slos
+}
+
+func window(t *testing.T, status models.SLOStatus, name string) models.SLOWindow {
+	for _, w := range status.Windows {
+		if w.Window == name {
+			return w
+		}
+	}
+	t.Fatalf("No %s window for %s", name, status.Name)
+	return models.SLOWindow{}
+}
+
+func TestSLOTrackerBurnRate(t *testing.T) {
+	clk := newFakeClock()
+	m := metrics.New(nil)
+	tracker := slo.NewTracker(testSLOs(t), m, clk)
+
+	for i := 0; i < 98; i++ {
+		tracker.Record(http.MethodGet, "/api/data", http.StatusOK, 200*time.Millisecond)
+	}
+	tracker.Record(http.MethodGet, "/api/data", http.StatusOK, 1500*time.Millisecond)
+	tracker.Record(http.MethodGet, "/api/data", http.StatusServiceUnavailable, 10*time.Millisecond)
+	tracker.Record(http.MethodPost, "/api/data", http.StatusOK, time.Millisecond)
+	tracker.Record(http.MethodGet, "/health", http.StatusInternalServerError, time.Millisecond)
+
+	statuses := tracker.Status()
+	latency := window(t, statuses[0], "5m")
+	if latency.Good != 99 || latency.Total != 101 {
+		t.Errorf("Expected 99 of 101 fast successes, got %d of %d", latency.Good, latency.Total)
+	}
+	availability := window(t, statuses[1], "5m")
+	if availability.Good != 99 || availability.Total != 100 {
+		t.Errorf("Expected 99 of 100 GETs to succeed, got %d of %d", availability.Good, availability.Total)
+	}
+	if burn := *availability.BurnRate; burn < 1.99 || burn > 2.01 {
+		t.Errorf("Expected 1%% errors against a 0.5%% budget to burn at 2, got %v", burn)
+	}
+	if got := testutil.ToFloat64(m.SLOGoodEvents.WithLabelValues("data-latency")); got != 99 {
+		t.Errorf("Expected 99 good events counted, got %v", got)
+	}
+
+	// The events age out of the short windows first
+	clk.Advance(10 * time.Minute)
+	statuses = tracker.Status()
+	if w := window(t, statuses[1], "5m"); w.Total != 0 || w.SLI != nil {
+		t.Errorf("Expected an empty 5m window, got %+v", w)
+	}
+	if w := window(t, statuses[1], "30m"); w.Total != 100 {
+		t.Errorf("Expected the 30m window to keep 100 events, got %d", w.Total)
+	}
+
+	if n := testutil.CollectAndCount(tracker, "slo_burn_rate"); n != 12 {
+		t.Errorf("Expected burn rates for the 6 non-empty windows of both SLOs, got %d", n)
+	}
+}
+
+func TestSLOAdminEndpoints(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.SLOs = testSLOs(t)
+	ts := newSlowTestServer(t, testCfg)
+
+	for i := 0; i < 3; i++ {
+		getStatus(t, ts.URL+"/api/data", nil)
+	}
+
+	resp, err := http.Get(ts.URL + "/admin/slo")
+	if err != nil {
+		t.Fatalf("GET /admin/slo failed: %v", err)
+	}
+	var statuses []models.SLOStatus
+	json.NewDecoder(resp.Body).Decode(&statuses)
+	resp.Body.Close()
+	if len(statuses) != 2 || window(t, statuses[0], "1h").Total != 3 {
+		t.Fatalf("Expected 3 requests counted towards data-latency, got %+v", statuses)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/admin/slo", nil)
+	req.Header.Set("Accept", "text/html")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /admin/slo failed: %v", err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(page), "<h2>data-availability</h2>") {
+		t.Errorf("Expected an HTML status page, got %s", page)
+	}
+
+	if n, err := testutil.GatherAndCount(ts.Server.Registry(), "slo_sli_ratio", "slo_objective"); err != nil || n == 0 {
+		t.Errorf("Expected the SLIs on the registry, got %d series: %v", n, err)
+	}
+}
+
+func TestSLORules(t *testing.T) {
+	rules := slo.Rules(testSLOs(t))
+
+	for _, want := range []string{
+		"record: slo:sli_error:ratio_rate5m",
+		"record: slo:sli_error:ratio_rate3d",
+		`slo:sli_error:ratio_rate1h{slo="data-latency"} > 0.144`,
+		`slo:sli_error:ratio_rate5m{slo="data-availability"} > 0.072`,
+		"severity: ticket",
+	} {
+		if !strings.Contains(rules, want) {
+			t.Errorf("Expected the rules to contain %q:\n%s", want, rules)
+		}
+	}
+	if got := strings.Count(rules, "alert: SLOErrorBudgetBurn"); got != 8 {
+		t.Errorf("Expected 4 burn rate alerts per SLO, got %d", got)
+	}
+}
+
+func TestParseSLOsInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		"a=path:/x",
+		"a=objective:0.99",
+		"a=path:x;objective:0.99",
+		"a=path:/x;objective:1",
+		"a=path:/x;objective:0.9;latency:-1",
+		"a=path:/x;objective:0.9,a=path:/y;objective:0.9",
+		"a=path:/x;objective:0.9;color:red",
+	} {
+		if _, err := config.ParseSLOs(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
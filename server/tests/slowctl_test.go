@@ -0,0 +1,283 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/slowctl"
+	"github.com/Unic-X/slow-server/slowserver"
+)
+
+// patchConfig sends patch to /admin/config and returns the status code
+func patchConfig(t *testing.T, adminURL, patch string) int {
+	req, _ := http.NewRequest(http.MethodPatch, adminURL+"/admin/config", strings.NewReader(patch))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("PATCH /admin/config failed: %v", err)
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func runSlowctl(t *testing.T, args ...string) (string, error) {
+	var out bytes.Buffer
+	err := slowctl.Run(args, &out)
+	return out.String(), err
+}
+
+func TestParseFault(t *testing.T) {
+	tests := []struct {
+		args     string
+		expected models.FaultRequest
+	}{
+		{"fail 50% of db for 2m", models.FaultRequest{Kind: "fail", Step: "db", Rate: 0.5, DurationMs: 120000}},
+		{"fail external for 30s with 503", models.FaultRequest{Kind: "fail", Step: "external", Rate: 1, Status: 503, DurationMs: 30000}},
+		{"delay db by 2s for 1m at 25%", models.FaultRequest{Kind: "delay", Step: "db", Rate: 0.25, DelayMs: 2000, DurationMs: 60000}},
+		{"delay 10% of cache by 500ms for 10s", models.FaultRequest{Kind: "delay", Step: "cache", Rate: 0.1, DelayMs: 500, DurationMs: 10000}},
+	}
+	for _, tt := range tests {
+		got, err := slowctl.ParseFault(strings.Fields(tt.args))
+		if err != nil || got != tt.expected {
+			t.Errorf("%q: got %+v, %v want %+v", tt.args, got, err, tt.expected)
+		}
+	}
+
+	for _, invalid := range []string{"", "explode db for 1m", "fail 50% of db", "delay db for 1m", "fail 150% of db for 1m", "fail db for soon", "fail db for 1m with"} {
+		if _, err := slowctl.ParseFault(strings.Fields(invalid)); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestParseScenario(t *testing.T) {
+	got, err := slowctl.ParseScenario([]string{"drill", "-repeat", "1m fail 20% of db", "30s", "2m fail db with 503 + delay external by 1s at 50%"})
+	if err != nil {
+		t.Fatalf("ParseScenario failed: %v", err)
+	}
+	expected := models.Scenario{Name: "drill", Repeat: true, Phases: []models.ScenarioPhase{
+		{DurationMs: 60000, Faults: []models.FaultRequest{{Kind: "fail", Step: "db", Rate: 0.2, DurationMs: 60000}}},
+		{DurationMs: 30000},
+		{DurationMs: 120000, Faults: []models.FaultRequest{
+			{Kind: "fail", Step: "db", Rate: 1, Status: 503, DurationMs: 120000},
+			{Kind: "delay", Step: "external", Rate: 0.5, DelayMs: 1000, DurationMs: 120000},
+		}},
+	}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %+v want %+v", got, expected)
+	}
+
+	for _, invalid := range [][]string{{}, {"drill"}, {"drill", "-repeat"}, {"drill", ""}, {"drill", "soon fail db"},
+		{"drill", "1m fail db for 2m"}, {"drill", "1m fail db +"}, {"drill", "1m explode db"}} {
+		if _, err := slowctl.ParseScenario(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestSlowctlFaultOnEveryTarget(t *testing.T) {
+	clocks := []*clock.Fake{}
+	var urls, admins []string
+	for i := 0; i < 2; i++ {
+		clk := newFakeClock()
+		ts := newSlowTestServer(t, setupTestConfig(), slowserver.WithClock(clk))
+		clocks, urls, admins = append(clocks, clk), append(urls, ts.URL), append(admins, ts.AdminURL)
+	}
+
+	out, err := runSlowctl(t, "-t", admins[0], "-t", admins[1], "fault", "fail", "100%", "of", "db", "for", "2m", "with", "503")
+	if err != nil {
+		t.Fatalf("fault failed: %v\n%s", err, out)
+	}
+	// Every target gets the fault under the same ID
+	id := regexp.MustCompile(`Injected fault (\S+): `).FindStringSubmatch(out)
+	if id == nil {
+		t.Fatalf("Expected the fault ID, got:\n%s", out)
+	}
+	for i, url := range urls {
+		if !strings.Contains(out, "== "+admins[i]+"\nInjected fault "+id[1]+": fail 100% of db with 503") {
+			t.Errorf("Expected the fault injected into %s, got:\n%s", admins[i], out)
+		}
+		if got := getStatus(t, url+"/api/users/1", nil); got != http.StatusServiceUnavailable {
+			t.Errorf("Expected %s to fail the DB step with 503, got %d", url, got)
+		}
+	}
+
+	// Stats show the failures under the route pattern and the fault
+	out, err = runSlowctl(t, "-t", admins[0], "stats")
+	if err != nil || !strings.Contains(out, "GET /api/users/{id}") || !strings.Contains(out, "100.0%") ||
+		!strings.Contains(out, "Faults: fail 100% of db with 503") {
+		t.Errorf("Expected the failing route and the fault, got %v:\n%s", err, out)
+	}
+
+	// The ID removes it from every target, an unknown one from none
+	out, err = runSlowctl(t, "-t", admins[0], "-t", admins[1], "faults", "clear", "nope")
+	if err == nil || !strings.Contains(out, "No one-off fault with id nope") {
+		t.Errorf("Expected an unknown ID to fail, got %v:\n%s", err, out)
+	}
+	out, err = runSlowctl(t, "-t", admins[0], "-t", admins[1], "faults", "clear", id[1])
+	if err != nil || strings.Count(out, "No one-off faults") != 2 {
+		t.Errorf("Expected the fault removed from both targets, got %v:\n%s", err, out)
+	}
+	out, err = runSlowctl(t, "-t", admins[0], "-t", admins[1], "fault", "fail", "100%", "of", "db", "for", "2m", "with", "503")
+	if err != nil {
+		t.Fatalf("fault failed: %v\n%s", err, out)
+	}
+
+	// The fault expires on its own
+	for _, clk := range clocks {
+		clk.Advance(2 * time.Minute)
+	}
+	for _, url := range urls {
+		if got := getStatus(t, url+"/api/users/1", nil); got != http.StatusOK {
+			t.Errorf("Expected %s to recover once the fault expired, got %d", url, got)
+		}
+	}
+	out, _ = runSlowctl(t, "-t", admins[0], "faults")
+	if out != "No one-off faults\n" {
+		t.Errorf("Expected no faults left, got %q", out)
+	}
+
+	// One unreachable target fails the command but not the others
+	out, err = runSlowctl(t, "-t", admins[0], "-t", "http://127.0.0.1:1", "faults", "clear")
+	if err == nil || !strings.Contains(out, "== "+admins[0]+"\nNo one-off faults") {
+		t.Errorf("Expected a partial failure, got %v:\n%s", err, out)
+	}
+}
+
+func TestSlowctlScenarioAndTraffic(t *testing.T) {
+	ts := newSlowTestServer(t, setupTestConfig())
+
+	out, err := runSlowctl(t, "-t", ts.AdminURL, "scenario", "start", "drill", "1m fail 100% of db with 503", "1m")
+	if err != nil || !strings.HasPrefix(out, "Scenario drill: phase 1 of 2 until ") ||
+		!strings.Contains(out, "\ndrill/1.1: fail 100% of db with 503 until ") {
+		t.Fatalf("Expected the scenario started, got %v:\n%s", err, out)
+	}
+	if got := getStatus(t, ts.URL+"/api/users/1", nil); got != http.StatusServiceUnavailable {
+		t.Errorf("Expected the scenario to fail the DB step with 503, got %d", got)
+	}
+	out, err = runSlowctl(t, "-t", ts.AdminURL, "stats")
+	if err != nil || !strings.Contains(out, "scenario drill phase 1 of 2") {
+		t.Errorf("Expected the scenario phase in the stats, got %v:\n%s", err, out)
+	}
+
+	out, err = runSlowctl(t, "-t", ts.AdminURL, "scenario", "stop")
+	if err != nil || out != "No scenario running\n" {
+		t.Errorf("Expected the scenario stopped, got %v: %q", err, out)
+	}
+	getStatus(t, ts.URL+"/api/data", nil)
+
+	out, err = runSlowctl(t, "-t", ts.AdminURL, "traffic", "-limit", "2")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if err != nil || len(lines) != 2 {
+		t.Fatalf("Expected two JSON lines, got %v:\n%s", err, out)
+	}
+	var recorded [2]models.RecordedRequest
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &recorded[i]); err != nil {
+			t.Fatalf("Invalid JSON line %q: %v", line, err)
+		}
+	}
+	if recorded[0].Path != "/api/users/1" || recorded[0].Status != http.StatusServiceUnavailable || recorded[1].Route != "GET /api/data" {
+		t.Errorf("Expected the failed user request and the data request, got %+v", recorded)
+	}
+}
+
+func TestSlowctlConfig(t *testing.T) {
+	ts := newSlowTestServer(t, setupTestConfig())
+
+	out, err := runSlowctl(t, "-t", ts.AdminURL, "config", "ErrorRate=0.5", "SimulateErrors=true", "GraphQLMode=dataloader")
+	if err != nil {
+		t.Fatalf("config patch failed: %v\n%s", err, out)
+	}
+	if out != "ErrorRate = 0.5\nSimulateErrors = true\nGraphQLMode = \"dataloader\"\n" {
+		t.Errorf("Unexpected output:\n%s", out)
+	}
+
+	out, err = runSlowctl(t, "-t", ts.AdminURL, "config")
+	var cfg config.Config
+	if err != nil || json.Unmarshal([]byte(out), &cfg) != nil {
+		t.Fatalf("config failed: %v\n%s", err, out)
+	}
+	if cfg.ErrorRate != 0.5 || !cfg.SimulateErrors || cfg.GraphQLMode != "dataloader" {
+		t.Errorf("Expected the patch applied, got %+v", cfg)
+	}
+
+	for _, invalid := range []string{"Port=9000", "ErrorRate=2", "DBQueryDelay=-1", "Nope=1", "ErrorRate=high"} {
+		if out, err := runSlowctl(t, "-t", ts.AdminURL, "config", invalid); err == nil {
+			t.Errorf("Expected %s to be rejected, got:\n%s", invalid, out)
+		}
+	}
+}
+
+// TestConfigPatchRejectsNestedValues checks values inside throttles, proxy
+// rules and resolvers are validated like the top-level ones
+func TestConfigPatchRejectsNestedValues(t *testing.T) {
+	ts := newSlowTestServer(t, setupTestConfig())
+	for _, patch := range []string{
+		`{"ProxyRules":[{"Path":"*","ErrorRate":2,"ErrorStatus":503}]}`,
+		`{"ProxyRules":[{"Path":"*","MinDelay":50,"MaxDelay":10,"ErrorStatus":503}]}`,
+		`{"ProxyRules":[{"Path":"*","ErrorStatus":200}]}`,
+		`{"Throttles":{"*":{"BytesPerSec":-1}}}`,
+		`{"GraphQLFields":{"User.orders":{"Delay":-5}}}`,
+		`{"WSCloseCode":99}`,
+		`{"Downstreams":{"db":"not a url"}}`,
+	} {
+		if got := patchConfig(t, ts.AdminURL, patch); got != http.StatusBadRequest {
+			t.Errorf("PATCH %s: expected 400, got %d", patch, got)
+		}
+	}
+	if cfg := ts.Server.Config(); len(cfg.ProxyRules) != 0 || len(cfg.Throttles) != 0 || cfg.WSCloseCode != 1011 {
+		t.Errorf("Expected rejected patches to leave the config alone, got %+v", cfg)
+	}
+}
+
+// TestConfigPatchUnderTraffic patches the config while requests read it;
+// run with -race to catch requests seeing a config being written
+func TestConfigPatchUnderTraffic(t *testing.T) {
+	ts := newSlowTestServer(t, setupTestConfig())
+	patches := []string{
+		`{"ErrorRate":0.5,"SimulateErrors":true}`,
+		`{"Throttles":{"*":{"ChunkSize":64}}}`,
+		`{"GraphQLFields":{"*":{"Delay":5,"ErrorRate":0.1}}}`,
+		`{"ProxyRules":[{"Path":"*","MaxDelay":5,"ErrorStatus":503}],"NetTruncateRate":0}`,
+		`{"SimulateErrors":false,"Throttles":{}}`,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				for _, path := range []string{"/api/users/1", "/api/data", "/graphql?query=%7Busers%7Bname%7D%7D"} {
+					getStatus(t, ts.URL+path, nil)
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 20; j++ {
+			patch := patches[j%len(patches)]
+			if got := patchConfig(t, ts.AdminURL, patch); got != http.StatusOK {
+				t.Errorf("PATCH %s returned %d", patch, got)
+			}
+		}
+	}()
+	wg.Wait()
+
+	if cfg := ts.Server.Config(); cfg.SimulateErrors || len(cfg.Throttles) != 0 || cfg.ErrorRate != 0.5 {
+		t.Errorf("Expected the patches applied in order, got %+v", cfg)
+	}
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/slowserver"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newFakeClock is the clock the test servers start on
+func newFakeClock() *clock.Fake {
+	return clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// newSlowTestServer serves testCfg on a fake clock with seed 1 until the
+// test ends. opts are applied last, e.g. to pass the test's own clock.
+func newSlowTestServer(t *testing.T, testCfg *config.Config, opts ...slowserver.Option) *slowserver.TestServer {
+	ts := slowserver.NewTestServer(append([]slowserver.Option{
+		slowserver.WithConfig(testCfg),
+		slowserver.WithClock(newFakeClock()),
+		slowserver.WithSeed(1),
+	}, opts...)...)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func countUsers(t *testing.T, url string) int {
+	resp, err := http.Get(url + "/api/users")
+	if err != nil {
+		t.Fatalf("GET /api/users failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var users models.UsersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		t.Fatalf("Failed to parse users: %v", err)
+	}
+	return users.Total
+}
+
+func TestTestServersAreIsolated(t *testing.T) {
+	a := newSlowTestServer(t, setupTestConfig())
+	b := newSlowTestServer(t, setupTestConfig())
+
+	resp, err := http.Post(a.URL+"/api/users", "application/json",
+		strings.NewReader(`{"name": "Only In A", "email": "a@example.com"}`))
+	if err != nil {
+		t.Fatalf("POST /api/users failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create returned wrong status code: got %v want %v", resp.StatusCode, http.StatusCreated)
+	}
+
+	if got := countUsers(t, a.URL); got != 11 {
+		t.Errorf("Expected 11 users on the first server, got %d", got)
+	}
+	if got := countUsers(t, b.URL); got != 10 {
+		t.Errorf("Expected the second server to keep its 10 users, got %d", got)
+	}
+}
+
+func TestTestServerServesOwnMetrics(t *testing.T) {
+	ts := newSlowTestServer(t, setupTestConfig())
+	countUsers(t, ts.URL)
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if !strings.Contains(string(body), `http_requests_total{method="GET",path="/api/users",status="200"}`) {
+		t.Error("Expected /metrics to report the users request")
+	}
+	if strings.Contains(string(body), "go_goroutines") {
+		t.Error("Expected /metrics to be served from the instance registry, not the default one")
+	}
+}
+
+func TestServerStartAndShutdown(t *testing.T) {
+	server := slowserver.New(
+		slowserver.WithConfig(setupTestConfig()),
+		slowserver.WithClock(fakeClock),
+		slowserver.WithAddr("127.0.0.1:0"),
+	)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := server.Start(); err == nil {
+		t.Error("Expected a second Start to fail")
+	}
+
+	if got := countUsers(t, "http://"+server.Addr()); got != 10 {
+		t.Errorf("Expected 10 users, got %d", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if _, err := http.Get("http://" + server.Addr() + "/api/users"); err == nil {
+		t.Error("Expected requests to fail after Shutdown")
+	}
+}
+
+func TestMetricsAreIsolatedPerInstance(t *testing.T) {
+	a := newSlowTestServer(t, setupTestConfig())
+	b := newSlowTestServer(t, setupTestConfig())
+
+	countUsers(t, a.URL)
+	countUsers(t, a.URL)
+
+	requests := func(ts *slowserver.TestServer) float64 {
+		return testutil.ToFloat64(ts.Server.Metrics().RequestsTotal.WithLabelValues("/api/users", "GET", "200"))
+	}
+	if got := requests(a); got != 2 {
+		t.Errorf("Expected 2 requests on the first server, got %v", got)
+	}
+	if got := requests(b); got != 0 {
+		t.Errorf("Expected no requests on the second server, got %v", got)
+	}
+
+	expected := `
+# HELP db_queries_total Total number of database queries
+# TYPE db_queries_total counter
+db_queries_total 2
+`
+	if err := testutil.GatherAndCompare(a.Server.Registry(), strings.NewReader(expected), "db_queries_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRequestMetricsByRoutePattern(t *testing.T) {
+	ts := newSlowTestServer(t, setupTestConfig())
+	for _, path := range []string{"/api/users/1", "/api/users/2", "/api/users/999", "/nope/1", "/nope/2"} {
+		getStatus(t, ts.URL+path, nil)
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := http.Post(ts.URL+"/api/process?async=true", "application/json", strings.NewReader(`{"items":["a"]}`))
+		if err != nil {
+			t.Fatalf("POST /api/process failed: %v", err)
+		}
+		resp.Body.Close()
+		getStatus(t, ts.URL+resp.Header.Get("Location"), nil)
+	}
+
+	m := ts.Server.Metrics()
+	if got := testutil.ToFloat64(m.RequestsTotal.WithLabelValues("/api/users/{id}", "GET", "200")); got != 2 {
+		t.Errorf("Expected both users counted under /api/users/{id}, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.RequestErrors.WithLabelValues("/api/users/{id}", "GET", "404")); got != 1 {
+		t.Errorf("Expected the unknown user as an error of /api/users/{id}, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.RequestErrors.WithLabelValues("(unmatched)", "GET", "404")); got != 2 {
+		t.Errorf("Expected unknown paths to share one series, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.RequestsTotal.WithLabelValues("/api/jobs/{id}", "GET", "200")); got != 2 {
+		t.Errorf("Expected both jobs counted under /api/jobs/{id}, got %v", got)
+	}
+	// Whatever the ids, one duration series per route pattern
+	if got := testutil.CollectAndCount(m.RequestDuration); got != 4 {
+		t.Errorf("Expected one duration series per route pattern, got %d", got)
+	}
+	if got := histogramSampleCount(t, m.ResponseTTFB.WithLabelValues("/api/users/{id}").(prometheus.Histogram)); got != 3 {
+		t.Errorf("Expected every user in one time to first byte series, got %d", got)
+	}
+	if got := testutil.CollectAndCount(m.ResponseTransfer); got != 4 {
+		t.Errorf("Expected one transfer series per route pattern, got %d", got)
+	}
+}
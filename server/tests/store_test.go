@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/store"
+)
+
+func usersRouter() *http.ServeMux {
+	router := http.NewServeMux()
+	router.HandleFunc("/api/users", testServer.GetUsersHandler)
+	router.HandleFunc("POST /api/users", testServer.CreateUserHandler)
+	router.HandleFunc("GET /api/users/{id}", testServer.GetUserHandler)
+	router.HandleFunc("PUT /api/users/{id}", testServer.UpdateUserHandler)
+	router.HandleFunc("DELETE /api/users/{id}", testServer.DeleteUserHandler)
+	return router
+}
+
+func TestStoreIsDeterministic(t *testing.T) {
+	a := store.New(25, 42)
+	b := store.New(25, 42)
+
+	if !reflect.DeepEqual(a.ListUsers(), b.ListUsers()) {
+		t.Error("Same seed produced different users")
+	}
+	if !reflect.DeepEqual(a.ListData(), b.ListData()) {
+		t.Error("Same seed produced different data items")
+	}
+	if len(a.ListUsers()) != 25 || len(a.ListData()) != 25 {
+		t.Errorf("Expected 25 records, got %d users and %d items", len(a.ListUsers()), len(a.ListData()))
+	}
+
+	c := store.New(25, 43)
+	if reflect.DeepEqual(a.ListUsers(), c.ListUsers()) {
+		t.Error("Different seeds produced identical users")
+	}
+}
+
+func TestUserCRUD(t *testing.T) {
+	testCfg := setupTestConfig()
+	router := usersRouter()
+
+	// Create
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/users",
+		strings.NewReader(`{"name": "New User", "email": "new@example.com"}`))
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	var created models.User
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse create response: %v", err)
+	}
+	if created.ID != testCfg.StoreSize+1 {
+		t.Errorf("Expected new user id %d, got %d", testCfg.StoreSize+1, created.ID)
+	}
+	location := rr.Header().Get("Location")
+
+	// Update
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPut, location,
+		strings.NewReader(`{"name": "Renamed User", "email": "new@example.com"}`))
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	// Read back
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, location, nil))
+	var fetched models.User
+	if err := json.Unmarshal(rr.Body.Bytes(), &fetched); err != nil {
+		t.Fatalf("Failed to parse get response: %v", err)
+	}
+	if fetched.Name != "Renamed User" {
+		t.Errorf("Expected updated name, got %q", fetched.Name)
+	}
+
+	// List includes the new user
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	var list models.UsersResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to parse list response: %v", err)
+	}
+	if list.Count != testCfg.StoreSize+1 {
+		t.Errorf("Expected %d users after create, got %d", testCfg.StoreSize+1, list.Count)
+	}
+
+	// Delete, then the user is gone
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, location, nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("delete returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, location, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("deleted user still readable: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestCreateUserValidation(t *testing.T) {
+	setupTestConfig()
+	router := usersRouter()
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(`{"name": ""}`))
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid user accepted: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
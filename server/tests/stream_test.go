@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStreamEvents(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.StreamInterval = 1000
+	testCfg.StreamJitter = 200
+	testCfg.StreamHeartbeat = 0
+
+	rr := httptest.NewRecorder()
+	start := fakeClock.Now()
+	testServer.StreamHandler(rr, httptest.NewRequest(http.MethodGet, "/api/stream?count=3", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected an event stream, got Content-Type %q", ct)
+	}
+	body := rr.Body.String()
+	for _, id := range []string{"id: 1\n", "id: 2\n", "id: 3\n"} {
+		if !strings.Contains(body, id) {
+			t.Errorf("Expected event %q in the stream, got %q", id, body)
+		}
+	}
+	if got := strings.Count(body, "event: update\ndata: {\"id\":"); got != 3 {
+		t.Errorf("Expected 3 DataItem updates, got %d", got)
+	}
+
+	// Three intervals of 1000ms, each moved by at most 200ms
+	if elapsed := fakeClock.Since(start).Milliseconds(); elapsed < 2400 || elapsed > 3600 {
+		t.Errorf("Expected about 3s of jittered intervals, took %dms", elapsed)
+	}
+
+	m := testServer.Metrics()
+	if got := testutil.ToFloat64(m.StreamEvents); got != 3 {
+		t.Errorf("Expected 3 events to be counted, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.ActiveStreams); got != 0 {
+		t.Errorf("Expected no active streams once it ended, got %v", got)
+	}
+	if got := histogramSampleSum(t, m.StreamEventLag); got != 0 {
+		t.Errorf("Expected events on the fake clock to be on time, lagged %vms", got)
+	}
+}
+
+func TestStreamHeartbeats(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.StreamInterval = 1000
+	testCfg.StreamJitter = 0
+	testCfg.StreamHeartbeat = 400
+
+	rr := httptest.NewRecorder()
+	testServer.StreamHandler(rr, httptest.NewRequest(http.MethodGet, "/api/stream?count=2", nil))
+
+	// Heartbeats at 400ms and 800ms into each 1000ms gap
+	if got := strings.Count(rr.Body.String(), ": heartbeat\n\n"); got != 4 {
+		t.Errorf("Expected 4 heartbeats, got %d", got)
+	}
+}
+
+func TestStreamResume(t *testing.T) {
+	setupTestConfig()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stream?count=2", nil)
+	req.Header.Set("Last-Event-ID", "7")
+	rr := httptest.NewRecorder()
+	testServer.StreamHandler(rr, req)
+
+	body := rr.Body.String()
+	if strings.Contains(body, "id: 7\n") || !strings.Contains(body, "id: 8\n") || !strings.Contains(body, "id: 9\n") {
+		t.Errorf("Expected the stream to resume at event 8, got %q", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/stream", nil)
+	req.Header.Set("Last-Event-ID", "latest")
+	rr = httptest.NewRecorder()
+	testServer.StreamHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid Last-Event-ID accepted: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestStreamDisconnect(t *testing.T) {
+	ts := newSlowTestServer(t, setupTestConfig())
+
+	resp, err := http.Get(ts.URL + "/api/stream?count=5&disconnect_after=2")
+	if err != nil {
+		t.Fatalf("GET /api/stream failed: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected the stream to be cut off, got %v", err)
+	}
+	if !strings.Contains(string(body), "id: 2\n") || strings.Contains(string(body), "id: 3\n") {
+		t.Errorf("Expected the stream to end after event 2, got %q", body)
+	}
+	if got := testutil.ToFloat64(ts.Server.Metrics().StreamDisconnects); got != 1 {
+		t.Errorf("Expected 1 injected disconnect to be counted, got %v", got)
+	}
+}
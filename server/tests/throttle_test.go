@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/clock"
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestThrottledResponse(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.Throttles = map[string]config.Throttle{
+		"/slow": {BytesPerSec: 1000, ChunkSize: 250, FlushDelay: 10},
+	}
+	m := testServer.Metrics()
+
+	body := strings.Repeat("x", 1000)
+	routes := http.NewServeMux()
+	routes.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+	handler := middleware.ApplyThrottleMiddleware(routes, config.NewCurrent(testCfg), m, routes, fakeClock)
+
+	rr := httptest.NewRecorder()
+	start := fakeClock.Now()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	duration := fakeClock.Since(start)
+
+	if rr.Body.String() != body {
+		t.Errorf("Throttled body was altered: got %d bytes", rr.Body.Len())
+	}
+	if !rr.Flushed {
+		t.Error("Expected the chunks to be flushed")
+	}
+	// 4 chunks of 250 bytes at 1000 B/s, each flush held for 10ms
+	if expected := 4*250*time.Millisecond + 4*10*time.Millisecond; duration != expected {
+		t.Errorf("Unexpected transfer time: %v, expected exactly %v", duration, expected)
+	}
+	if got := histogramSampleSum(t, m.ResponseTTFB.WithLabelValues("/slow").(prometheus.Histogram)); got != 250 {
+		t.Errorf("TTFB histogram observed %vms, expected 250ms", got)
+	}
+	if got := histogramSampleSum(t, m.ResponseTransfer.WithLabelValues("/slow").(prometheus.Histogram)); got != 1040 {
+		t.Errorf("Transfer histogram observed %vms, expected 1040ms", got)
+	}
+}
+
+func TestThrottleMatchesRoutePattern(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.Throttles = map[string]config.Throttle{
+		"/users/{id}": {BytesPerSec: 100},
+		"/users/7":    {BytesPerSec: 50},
+	}
+	m := testServer.Metrics()
+
+	routes := http.NewServeMux()
+	routes.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	})
+	handler := middleware.ApplyThrottleMiddleware(routes, config.NewCurrent(testCfg), m, routes, fakeClock)
+
+	for path, expected := range map[string]time.Duration{
+		"/users/1": time.Second,     // The pattern's throttle
+		"/users/7": 2 * time.Second, // An exact path wins over the pattern
+	} {
+		rr := httptest.NewRecorder()
+		start := fakeClock.Now()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if duration := fakeClock.Since(start); duration != expected {
+			t.Errorf("%s took %v, expected %v", path, duration, expected)
+		}
+	}
+}
+
+func TestUnthrottledRouteIsTimedOnly(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.Throttles = map[string]config.Throttle{"/slow": {BytesPerSec: 1}}
+	m := testServer.Metrics()
+
+	routes := http.NewServeMux()
+	routes.HandleFunc("/api/data", testServer.GetDataHandler)
+	handler := middleware.ApplyThrottleMiddleware(routes, config.NewCurrent(testCfg), m, routes, fakeClock)
+	rr := httptest.NewRecorder()
+	start := fakeClock.Now()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/data", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	elapsed := float64(fakeClock.Since(start).Milliseconds())
+	if got := histogramSampleSum(t, m.ResponseTransfer.WithLabelValues("/api/data").(prometheus.Histogram)); got != elapsed {
+		t.Errorf("Transfer histogram observed %vms, expected the handler's %vms", got, elapsed)
+	}
+}
+
+func TestThrottleStopsWhenClientLeaves(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.Throttles = map[string]config.Throttle{"*": {BytesPerSec: 1, FlushDelay: 10000}}
+
+	routes := http.NewServeMux()
+	var writeErr error
+	routes.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		_, writeErr = w.Write([]byte("ten bytes!"))
+		http.NewResponseController(w).Flush()
+	})
+	// On the real clock the body alone would take 10s
+	handler := middleware.ApplyThrottleMiddleware(routes, config.NewCurrent(testCfg), testServer.Metrics(), routes, clock.Real)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the throttle to give up on a cancelled request, took %v", elapsed)
+	}
+	if !errors.Is(writeErr, context.Canceled) || rr.Body.Len() != 0 || rr.Flushed {
+		t.Errorf("Expected nothing written or flushed, got %v, %q, flushed %v", writeErr, rr.Body.String(), rr.Flushed)
+	}
+}
+
+func TestParseThrottles(t *testing.T) {
+	throttles, err := config.ParseThrottles("/api/data=bps:1024;chunk:256;flush:100, *=bps:65536")
+	if err != nil {
+		t.Fatalf("ParseThrottles failed: %v", err)
+	}
+	expected := map[string]config.Throttle{
+		"/api/data": {BytesPerSec: 1024, ChunkSize: 256, FlushDelay: 100},
+		"*":         {BytesPerSec: 65536},
+	}
+	if !reflect.DeepEqual(throttles, expected) {
+		t.Errorf("Unexpected throttles: %+v", throttles)
+	}
+
+	cfg := &config.Config{Throttles: throttles}
+	if got, _ := cfg.ThrottleFor("/api/users"); got.BytesPerSec != 65536 {
+		t.Errorf("Expected the * throttle for other routes, got %+v", got)
+	}
+
+	for _, invalid := range []string{"/api/data", "/api/data=speed:1", "/api/data=bps:fast"} {
+		if _, err := config.ParseThrottles(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/traffic"
+)
+
+func TestTrafficRecorderKeepsTheLatest(t *testing.T) {
+	rec := traffic.New(3)
+	for i := 1; i <= 5; i++ {
+		rec.Record(models.RecordedRequest{Path: "/" + strconv.Itoa(i)})
+	}
+
+	var paths []string
+	for _, r := range rec.Snapshot(0) {
+		paths = append(paths, r.Path)
+	}
+	if len(paths) != 3 || paths[0] != "/3" || paths[2] != "/5" {
+		t.Errorf("Expected the last 3 requests oldest first, got %v", paths)
+	}
+	if got := rec.Snapshot(1); len(got) != 1 || got[0].Path != "/5" {
+		t.Errorf("Expected only the latest request, got %+v", got)
+	}
+
+	traffic.New(0).Record(models.RecordedRequest{Path: "/"})
+	if got := traffic.New(0).Snapshot(0); len(got) != 0 {
+		t.Errorf("Expected a zero-size recorder to keep nothing, got %+v", got)
+	}
+}
+
+func TestTrafficEndpoint(t *testing.T) {
+	ts := newSlowTestServer(t, setupTestConfig())
+	header := http.Header{"X-Request-ID": {"traffic-1"}}
+	getStatus(t, ts.URL+"/api/users/1?verbose=1", header)
+	getStatus(t, ts.URL+"/api/users/999", nil)
+	getStatus(t, ts.URL+"/admin/slo", nil)
+
+	get := func(query string) []models.RecordedRequest {
+		resp, err := http.Get(ts.AdminURL + "/admin/traffic" + query)
+		if err != nil {
+			t.Fatalf("GET /admin/traffic failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var recorded []models.RecordedRequest
+		if err := json.NewDecoder(resp.Body).Decode(&recorded); err != nil {
+			t.Fatalf("Failed to parse traffic: %v", err)
+		}
+		return recorded
+	}
+
+	recorded := get("")
+	if len(recorded) != 2 {
+		t.Fatalf("Expected the two API requests without the admin one, got %+v", recorded)
+	}
+	first := recorded[0]
+	if first.Method != "GET" || first.Path != "/api/users/1?verbose=1" || first.Route != "GET /api/users/{id}" ||
+		first.Status != http.StatusOK || first.RequestID != "traffic-1" || first.Seed == 0 {
+		t.Errorf("Unexpected first request %+v", first)
+	}
+	if recorded[1].Status != http.StatusNotFound || recorded[1].Route != "GET /api/users/{id}" {
+		t.Errorf("Unexpected second request %+v", recorded[1])
+	}
+
+	if latest := get("?limit=1"); len(latest) != 1 || latest[0].Path != "/api/users/999" {
+		t.Errorf("Expected only the latest request, got %+v", latest)
+	}
+
+	resp, err := http.Get(ts.AdminURL + "/admin/traffic?limit=-1")
+	if err != nil {
+		t.Fatalf("GET /admin/traffic failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a negative limit to be rejected, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Unic-X/slow-server/config"
+	"github.com/Unic-X/slow-server/models"
+	"github.com/Unic-X/slow-server/slowserver"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newWSTestServer(t *testing.T, testCfg *config.Config) *slowserver.TestServer {
+	testCfg.WSDelay = 100
+	testCfg.WSJitter = 0
+	return newSlowTestServer(t, testCfg)
+}
+
+func dialWS(t *testing.T, ts *slowserver.TestServer, query string) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial %s failed: %v", url, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func readWS(t *testing.T, conn *websocket.Conn) string {
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	return string(data)
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	ts := newWSTestServer(t, setupTestConfig())
+	conn := dialWS(t, ts, "")
+
+	for _, msg := range []string{"one", "two", "three"} {
+		conn.WriteMessage(websocket.TextMessage, []byte(msg))
+		if got := readWS(t, conn); got != msg {
+			t.Errorf("Expected %q echoed back, got %q", msg, got)
+		}
+	}
+
+	m := ts.Server.Metrics()
+	if got := testutil.ToFloat64(m.WSMessages.WithLabelValues("sent")); got != 3 {
+		t.Errorf("Expected 3 sent messages to be counted, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.WSActiveConnections); got != 1 {
+		t.Errorf("Expected 1 active connection, got %v", got)
+	}
+	if got := histogramSampleSum(t, m.WSMessageLatency); got != 300 {
+		t.Errorf("Expected 3 messages held back 100ms each, latency summed to %vms", got)
+	}
+}
+
+func TestWebSocketReorder(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.WSReorderRate = 1
+	ts := newWSTestServer(t, testCfg)
+	conn := dialWS(t, ts, "")
+
+	// The first message is held back until the second one is sent
+	conn.WriteMessage(websocket.TextMessage, []byte("first"))
+	conn.WriteMessage(websocket.TextMessage, []byte("second"))
+	if got := readWS(t, conn) + "," + readWS(t, conn); got != "second,first" {
+		t.Errorf("Expected the messages swapped, got %s", got)
+	}
+}
+
+func TestWebSocketLoss(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.WSLossRate = 1
+	ts := newWSTestServer(t, testCfg)
+	conn := dialWS(t, ts, "")
+
+	conn.WriteMessage(websocket.TextMessage, []byte("gone"))
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, data, err := conn.ReadMessage(); err == nil {
+		t.Errorf("Expected the message to be lost, got %q", data)
+	}
+	if got := testutil.ToFloat64(ts.Server.Metrics().WSMessages.WithLabelValues("lost")); got != 1 {
+		t.Errorf("Expected 1 lost message to be counted, got %v", got)
+	}
+}
+
+func TestWebSocketPushAndClose(t *testing.T) {
+	ts := newWSTestServer(t, setupTestConfig())
+	conn := dialWS(t, ts, "?mode=push&close_after=2&close_code=4000")
+
+	for i := 1; i <= 2; i++ {
+		var item models.DataItem
+		if err := json.Unmarshal([]byte(readWS(t, conn)), &item); err != nil {
+			t.Fatalf("Failed to parse pushed item: %v", err)
+		}
+		if item.ID != i {
+			t.Errorf("Expected item %d pushed, got %d", i, item.ID)
+		}
+	}
+
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != 4000 {
+		t.Errorf("Expected a close with code 4000, got %v", err)
+	}
+	if got := testutil.ToFloat64(ts.Server.Metrics().WSCloses.WithLabelValues("4000")); got != 1 {
+		t.Errorf("Expected 1 injected close to be counted, got %v", got)
+	}
+}
+
+func TestWebSocketAbnormalClose(t *testing.T) {
+	ts := newWSTestServer(t, setupTestConfig())
+	conn := dialWS(t, ts, "?close_after=1&close_code=1006")
+
+	conn.WriteMessage(websocket.TextMessage, []byte("bye"))
+	readWS(t, conn)
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseAbnormalClosure) {
+		t.Errorf("Expected the connection dropped without a close frame, got %v", err)
+	}
+}
+
+func TestWebSocketDelayEndsWithConnection(t *testing.T) {
+	testCfg := setupTestConfig()
+	testCfg.WSDelay = 10000
+	testCfg.WSPushInterval = 1
+	// On the real clock every message would be held back for 10s
+	ts := slowserver.NewTestServer(slowserver.WithConfig(testCfg), slowserver.WithSeed(1))
+	t.Cleanup(ts.Close)
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?mode=push"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial %s failed: %v", url, err)
+	}
+	time.Sleep(50 * time.Millisecond) // The first message is being held back
+	conn.Close()
+
+	active := ts.Server.Metrics().WSActiveConnections
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(active) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the delayed write to give up once the client left")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebSocketInvalidArgs(t *testing.T) {
+	ts := newWSTestServer(t, setupTestConfig())
+
+	for _, query := range []string{"?mode=broadcast", "?close_code=99", "?close_after=-1"} {
+		resp, err := http.Get(ts.URL + "/ws" + query)
+		if err != nil {
+			t.Fatalf("GET /ws%s failed: %v", query, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s accepted: got %v want %v", query, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// Header is the W3C Trace Context header carrying a Context between hops
+const Header = "traceparent"
+
+// Context is the W3C trace context of one hop, plus the request ID that
+// travels with it in X-Request-ID
+type Context struct {
+	TraceID   string // 32 hex digits shared by every hop of a request
+	SpanID    string // 16 hex digits identifying this hop
+	Flags     string // 2 hex digits, "01" when sampled
+	RequestID string
+}
+
+// Parse reads a traceparent header. Only version 00 is understood, and
+// all-zero IDs are rejected as the spec requires.
+func Parse(traceparent string) (Context, bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) != 4 || parts[0] != "00" ||
+		!isHex(parts[1], 32) || !isHex(parts[2], 16) || !isHex(parts[3], 2) {
+		return Context{}, false
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return Context{}, false
+	}
+	return Context{TraceID: parts[1], SpanID: parts[2], Flags: parts[3]}, true
+}
+
+// FromHeaders continues the trace of an incoming traceparent with a new
+// span for this hop, or starts a new trace without one
+func FromHeaders(traceparent, requestID string) Context {
+	c, ok := Parse(traceparent)
+	if !ok {
+		c = Context{TraceID: newID(16), Flags: "01"}
+	}
+	c = c.Child()
+	c.RequestID = requestID
+	return c
+}
+
+// Child returns a new span in the same trace, e.g. for a downstream call
+func (c Context) Child() Context {
+	c.SpanID = newID(8)
+	return c
+}
+
+// Traceparent formats c as a traceparent header value
+func (c Context) Traceparent() string {
+	return "00-" + c.TraceID + "-" + c.SpanID + "-" + c.Flags
+}
+
+type contextKey struct{}
+
+// WithContext attaches the trace context of a request to ctx
+func WithContext(ctx context.Context, c Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the trace context attached to ctx, if any
+func FromContext(ctx context.Context) (Context, bool) {
+	c, ok := ctx.Value(contextKey{}).(Context)
+	return c, ok
+}
+
+// newID returns n random bytes as lowercase hex
+func newID(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+// Package traffic keeps the most recent requests in process, so a drill
+// can see what clients sent and got back, and replay a single request with
+// its seed.
+package traffic
+
+import (
+	"sync"
+
+	"github.com/Unic-X/slow-server/models"
+)
+
+// Recorder is a ring of the most recent requests
+type Recorder struct {
+	mu   sync.Mutex
+	ring []models.RecordedRequest
+	next int
+	full bool
+}
+
+// New returns a recorder keeping the last size requests; with size 0 it
+// records nothing
+func New(size int) *Recorder {
+	return &Recorder{ring: make([]models.RecordedRequest, size)}
+}
+
+// Record adds a finished request, dropping the oldest once full
+func (r *Recorder) Record(req models.RecordedRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.ring) == 0 {
+		return
+	}
+	r.ring[r.next] = req
+	r.next = (r.next + 1) % len(r.ring)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Snapshot returns up to the last limit requests, oldest first; limit 0
+// returns all of them
+func (r *Recorder) Snapshot(limit int) []models.RecordedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	recorded := append([]models.RecordedRequest{}, r.ring[:r.next]...)
+	if r.full {
+		recorded = append(append([]models.RecordedRequest{}, r.ring[r.next:]...), recorded...)
+	}
+	if limit > 0 && len(recorded) > limit {
+		recorded = recorded[len(recorded)-limit:]
+	}
+	return recorded
+}